@@ -4,6 +4,8 @@ import uuid
 import random
 
 admin_id = "115a612f-c6bc-42b7-9622-cba5a711a609"
+# the world the cities are founded in, the classic one unless WORLD_ID is set
+world_id = config("WORLD_ID", default="00000000-0000-0000-0000-000000000001")
 
 fake_cities = {
     str(uuid.uuid4()): {
@@ -136,6 +138,7 @@ def serialize_data(city, keyword, city_id):
         return (
             city_id,
             city["player_id"],
+            world_id,
             city["name"],
             city["q"],
             city["r"],
@@ -181,7 +184,7 @@ def serialize_data(city, keyword, city_id):
 
 def insert_city(cursor, city, city_id):
     cursor.execute(
-        "INSERT INTO city (id, player_id, name, q, r, biome, points) VALUES (%s, %s, %s, %s, %s, %s, %s)",
+        "INSERT INTO city (id, player_id, world_id, name, q, r, biome, points) VALUES (%s, %s, %s, %s, %s, %s, %s, %s)",
         serialize_data(city, "city", city_id),
     )
 
@@ -227,7 +230,7 @@ def write_to_db(data):
         conn.close()
         raise ValueError("⛔ Table 'city' does not exist. Please create it first.")
 
-    cursor.execute("SELECT q, r, biome FROM world WHERE settleable = 'true';")
+    cursor.execute("SELECT q, r, biome FROM world WHERE world_id = %s AND settleable = 'true';", (world_id,))
     settleable_tiles = cursor.fetchall()
 
 
@@ -4,11 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/luisferreira32/stickian/server/internal/dummy"
+	"github.com/luisferreira32/stickian/server/internal/game"
+	"github.com/luisferreira32/stickian/server/internal/user"
+	"github.com/luisferreira32/stickian/server/internal/worldgen"
 )
 
 func runMigrations(migrationsURL, databaseURL string) error {
@@ -30,10 +37,84 @@ func runMigrations(migrationsURL, databaseURL string) error {
 	return nil
 }
 
-func newDatabaseConnection(ctx context.Context, databaseURL string) (*pgx.Conn, error) {
-	conn, err := pgx.Connect(ctx, databaseURL)
+// poolConfig defines the size and timeouts of the database connection pool.
+type poolConfig struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	ConnectTimeout    time.Duration
+}
+
+func newDatabasePool(ctx context.Context, databaseURL string, cfg poolConfig) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database url: %w", err)
+	}
+	config.MaxConns = cfg.MaxConns
+	config.MinConns = cfg.MinConns
+	config.MaxConnLifetime = cfg.MaxConnLifetime
+	config.MaxConnIdleTime = cfg.MaxConnIdleTime
+	config.HealthCheckPeriod = cfg.HealthCheckPeriod
+	config.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	// the pool connects lazily, so make sure the database is reachable before serving
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	return conn, nil
+	return pool, nil
+}
+
+// databases are the databases of all services, on the configured storage.
+type databases struct {
+	game  game.GameDatabase
+	user  user.UserDatabase
+	dummy dummy.DummyDatabase
+	close func()
+}
+
+func newDatabases(ctx context.Context, cfg config) (*databases, error) {
+	if cfg.storage == storageMemory {
+		tiles, err := loadWorld(cfg.worldData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load world: %w", err)
+		}
+		log.Printf("running in memory with %d world tiles, nothing is persisted", len(tiles))
+		return &databases{
+			game:  game.NewMemoryDatabase(tiles),
+			user:  user.NewMemoryDatabase(),
+			dummy: &dummy.MemoryDatabase{},
+			close: func() {},
+		}, nil
+	}
+
+	err := runMigrations(cfg.migrationsURL, cfg.databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	db, err := newDatabasePool(ctx, cfg.databaseURL, cfg.pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	return &databases{
+		game:  &game.PostgresDatabase{DB: db},
+		user:  &user.PostgresDatabase{DB: db},
+		dummy: &dummy.PosgresDatabase{DB: db},
+		close: db.Close,
+	}, nil
+}
+
+// loadWorld reads the tiles of a world from the files of the world generator in the directory. Without a directory
+// the world is empty.
+func loadWorld(dir string) ([]*game.MapTile, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	return worldgen.ReadFiles(dir)
 }
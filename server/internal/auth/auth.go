@@ -0,0 +1,104 @@
+// Package auth defines who is making a request, i.e., the principal authenticated by the access token, and the
+// helpers to authorize it, shared by all services.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+const (
+	// RoleAdmin manages the game, e.g., its worlds
+	RoleAdmin = "admin"
+	// RoleModerator moderates the players, e.g., their city names
+	RoleModerator = "moderator"
+
+	// AudienceStream is the audience of the stream tokens, the short-lived tokens that only open the stream of the
+	// player, since browsers cannot set the Authorization header of a stream (i.e., an EventSource). The access
+	// tokens have no audience.
+	AudienceStream = "stream"
+)
+
+// Claims defines the claims of the access tokens.
+type Claims struct {
+	Name string `json:"name"`
+	// SessionID is the session family the token was issued for
+	SessionID     string   `json:"sid"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Principal defines the authenticated user of a request. Since it comes from the access token, it only changes
+// when the token is renewed (e.g., a new role is only granted after refreshing the token).
+type Principal struct {
+	UserID        string
+	Username      string
+	SessionID     string
+	EmailVerified bool
+	Roles         []string
+}
+
+func (c *Claims) Principal() *Principal {
+	return &Principal{
+		UserID:        c.Subject,
+		Username:      c.Name,
+		SessionID:     c.SessionID,
+		EmailVerified: c.EmailVerified,
+		Roles:         c.Roles,
+	}
+}
+
+// Owns reports whether the principal is the player that owns a resource, e.g., a city.
+func (p *Principal) Owns(playerID string) bool {
+	return p != nil && p.UserID != "" && p.UserID == playerID
+}
+
+// HasAnyRole reports whether the principal has at least one of the roles.
+func (p *Principal) HasAnyRole(roles ...string) bool {
+	if p == nil {
+		return false
+	}
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the request, if it is authenticated.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil && p.UserID != ""
+}
+
+// RequireRoles only lets through the requests of principals with at least one of the roles, so it must be chained
+// after the authentication. Endpoints are still responsible for any finer grained authorization.
+func RequireRoles(roles ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := FromContext(r.Context())
+			if !ok {
+				utils.WithError(w, utils.ErrUnauthorized)
+				return
+			}
+			if !principal.HasAnyRole(roles...) {
+				utils.WithError(w, utils.ErrForbidden)
+				return
+			}
+			f(w, r)
+		}
+	}
+}
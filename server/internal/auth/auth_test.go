@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_RequireRoles(t *testing.T) {
+	testcases := []struct {
+		name       string
+		principal  *Principal
+		roles      []string
+		wantStatus int
+	}{
+		{
+			name:       "unauthenticated",
+			roles:      []string{RoleAdmin},
+			wantStatus: 401,
+		},
+		{
+			name:       "without roles",
+			principal:  &Principal{UserID: "test-user"},
+			roles:      []string{RoleAdmin},
+			wantStatus: 403,
+		},
+		{
+			name:       "another role",
+			principal:  &Principal{UserID: "test-user", Roles: []string{RoleModerator}},
+			roles:      []string{RoleAdmin},
+			wantStatus: 403,
+		},
+		{
+			name:       "any of the roles",
+			principal:  &Principal{UserID: "test-user", Roles: []string{RoleModerator}},
+			roles:      []string{RoleAdmin, RoleModerator},
+			wantStatus: 200,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			handler := RequireRoles(testcase.roles...)(func(w http.ResponseWriter, r *http.Request) {})
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/admin", http.NoBody)
+			if testcase.principal != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), testcase.principal))
+			}
+
+			// when
+			handler(rec, req)
+
+			// then
+			if rec.Code != testcase.wantStatus {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func Test_Owns(t *testing.T) {
+	var nobody *Principal
+	if nobody.Owns("") || (&Principal{}).Owns("") {
+		t.Errorf("expected an unauthenticated principal to own nothing")
+	}
+	if !(&Principal{UserID: "test-user"}).Owns("test-user") || (&Principal{UserID: "test-user"}).Owns("another-user") {
+		t.Errorf("expected a principal to own only their resources")
+	}
+}
@@ -0,0 +1,10 @@
+package dummy
+
+import "context"
+
+// MemoryDatabase is the in-memory counterpart of the PosgresDatabase, which is always up.
+type MemoryDatabase struct{}
+
+func (db *MemoryDatabase) Select1(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,295 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+const (
+	// cityDefense is the defense of a city on its own, i.e., of its population
+	cityDefense = 10
+	// wallsDefense is the defense bonus of each Walls level, in percent
+	wallsDefense = 5
+	// maxLuck is the maximum deviation of the attack power due to luck, in percent
+	maxLuck = 10
+	// lossExponent shapes the losses of the winner, the higher the exponent the lower the losses of
+	// a winner that heavily outnumbers the loser
+	lossExponent = 1.5
+)
+
+// battleBiomeModifiers are the defense modifiers of each biome, in percent
+var battleBiomeModifiers = map[int]int{
+	BiomeBeach:    100,
+	BiomePlains:   90,
+	BiomeMountain: 125,
+}
+
+// BattleSide defines the units of one of the sides of a battle, and how many of them were lost.
+type BattleSide struct {
+	Units  map[string]int `json:"units"`
+	Losses map[string]int `json:"losses"`
+}
+
+// BattleReport defines the outcome of an attack on a city, readable by both the attacker and the defender.
+//
+// The report ID is the ID of the attacking movement.
+type BattleReport struct {
+	ID             string     `json:"id"`
+	Tick           int64      `json:"tick"`
+	Q              int        `json:"q"`
+	R              int        `json:"r"`
+	Biome          int        `json:"biome"`
+	Walls          int        `json:"walls"`
+	AttackerID     string     `json:"attackerID"`
+	AttackerCityID string     `json:"attackerCityID"`
+	DefenderID     string     `json:"defenderID"`
+	DefenderCityID string     `json:"defenderCityID"`
+	Luck           int        `json:"luck"`
+	AttackPower    int        `json:"attackPower"`
+	DefensePower   int        `json:"defensePower"`
+	AttackerWon    bool       `json:"attackerWon"`
+	Attacker       BattleSide `json:"attacker"`
+	Defender       BattleSide `json:"defender"`
+	Plunder        Resources  `json:"plunder"`
+	// Loyalty is the loyalty of the city after a won attack, and Conquered whether it changed hands
+	Loyalty   int  `json:"loyalty,omitempty"`
+	Conquered bool `json:"conquered,omitempty"`
+}
+
+// battleRand returns a random generator seeded by the key, such that re-processing a battle yields
+// the exact same outcome.
+func battleRand(key string) *rand.Rand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	seed := h.Sum64()
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+// attackPower returns the sum of the attack of the units.
+func attackPower(units map[string]int) int {
+	power := 0
+	for unit, count := range units {
+		if spec, ok := unitSpecs[unit]; ok {
+			power += spec.Attack * count
+		}
+	}
+	return power
+}
+
+// defensePower returns the defense of a city, given by its units, Walls and biome.
+func defensePower(c *City) int {
+	power := cityDefense
+	for unit, count := range c.Units {
+		if spec, ok := unitSpecs[unit]; ok {
+			power += spec.Defense * count
+		}
+	}
+	power = power * (100 + wallsDefense*c.Buildings.Walls) / 100
+	if modifier, ok := battleBiomeModifiers[c.Biome]; ok {
+		power = power * modifier / 100
+	}
+	return power
+}
+
+// carryCapacity returns the amount of resources the units can carry.
+func carryCapacity(units map[string]int) int {
+	capacity := 0
+	for unit, count := range units {
+		if spec, ok := unitSpecs[unit]; ok {
+			capacity += spec.Carry * count
+		}
+	}
+	return capacity
+}
+
+// casualties returns the units lost by a side, given the ratio of its losses.
+func casualties(units map[string]int, ratio float64) map[string]int {
+	losses := make(map[string]int, len(units))
+	for unit, count := range units {
+		losses[unit] = min(count, int(math.Round(float64(count)*ratio)))
+	}
+	return losses
+}
+
+// plunder splits the carry capacity evenly over the available resources, re-distributing the share of
+// the resources that run out over the remaining ones.
+func plunder(available Resources, capacity int) Resources {
+	var loot Resources
+	stocks := []struct{ available, loot *int }{
+		{&available.Food, &loot.Food},
+		{&available.Sticks, &loot.Sticks},
+		{&available.Stones, &loot.Stones},
+		{&available.Gems, &loot.Gems},
+	}
+	for capacity > 0 {
+		left := 0
+		for _, s := range stocks {
+			if *s.available > 0 {
+				left++
+			}
+		}
+		if left == 0 {
+			break
+		}
+		share := max(1, capacity/left)
+		for _, s := range stocks {
+			take := min(share, *s.available, capacity)
+			*s.available -= take
+			*s.loot += take
+			capacity -= take
+		}
+	}
+	return loot
+}
+
+// resolveBattle resolves the attack of a movement on a city, without changing either of them.
+//
+// The side with the highest power wins and wipes out the other side, while losing a share of its own units
+// given by (loser power / winner power) ^ lossExponent. A surviving attacker reduces the loyalty of the city
+// with its units with loyalty, conquering it once it drops to zero, or otherwise plunders the resources of
+// the city above the Treasury protection, up to its carry capacity.
+func resolveBattle(m *Movement, defender *City, tick int64) *BattleReport {
+	rng := battleRand(m.ID)
+	luck := rng.IntN(2*maxLuck+1) - maxLuck
+	report := &BattleReport{
+		ID:             m.ID,
+		Tick:           tick,
+		Q:              defender.Q,
+		R:              defender.R,
+		Biome:          defender.Biome,
+		Walls:          defender.Buildings.Walls,
+		AttackerID:     m.PlayerID,
+		AttackerCityID: m.CityID,
+		DefenderID:     defender.PlayerID,
+		DefenderCityID: defender.ID,
+		Luck:           luck,
+		AttackPower:    attackPower(m.Units) * (100 + luck) / 100,
+		DefensePower:   defensePower(defender),
+	}
+	report.Attacker.Units = maps.Clone(m.Units)
+	report.Defender.Units = maps.Clone(defender.Units)
+
+	if report.AttackPower > report.DefensePower {
+		report.AttackerWon = true
+		ratio := math.Pow(float64(report.DefensePower)/float64(report.AttackPower), lossExponent)
+		report.Attacker.Losses = casualties(m.Units, ratio)
+		report.Defender.Losses = casualties(defender.Units, 1)
+
+		survivors := make(map[string]int, len(m.Units))
+		for unit, count := range m.Units {
+			survivors[unit] = count - report.Attacker.Losses[unit]
+		}
+		hit := loyaltyHit(rng, survivors, defender)
+		report.Loyalty = max(0, defender.Loyalty-hit)
+		report.Conquered = hit > 0 && report.Loyalty == 0
+		if !report.Conquered {
+			report.Plunder = plunder(plunderable(defender), carryCapacity(survivors))
+		}
+	} else {
+		ratio := 1.0
+		if report.DefensePower > 0 {
+			ratio = math.Pow(float64(report.AttackPower)/float64(report.DefensePower), lossExponent)
+		}
+		report.Attacker.Losses = casualties(m.Units, 1)
+		report.Defender.Losses = casualties(defender.Units, ratio)
+	}
+	return report
+}
+
+// battle resolves the attack of a movement on a city, applying the losses of both sides and sending the
+// survivors back home with the plunder, or stationing them in the city if it was conquered.
+func (e *TickEngine) battle(state *TickState, m *Movement, defender *City) {
+	report := resolveBattle(m, defender, state.Tick)
+
+	units := make(map[string]int, len(m.Units))
+	for unit, count := range m.Units {
+		if survivors := count - report.Attacker.Losses[unit]; survivors > 0 {
+			units[unit] = survivors
+		}
+	}
+	defender.removeUnits(report.Defender.Losses)
+	defender.Resources.spend(&report.Plunder)
+	if report.AttackerWon {
+		defender.Loyalty = report.Loyalty
+	}
+	state.Reports = append(state.Reports, report)
+	notification := battleReportPayload{ReportID: report.ID, AttackerWon: report.AttackerWon, Conquered: report.Conquered}
+	state.Notify(report.AttackerID, NotificationBattleReport, report.AttackerCityID, notification)
+	state.Notify(report.DefenderID, NotificationBattleReport, report.DefenderCityID, notification)
+
+	if report.Conquered {
+		conquer(m, defender, units)
+		delete(state.Movements, m.ID)
+		return
+	}
+	if len(units) == 0 {
+		delete(state.Movements, m.ID)
+		return
+	}
+	m.Units = units
+	m.Resources.add(&report.Plunder)
+	m.turnBack(state.Tick)
+}
+
+type GetBattleReportsResponse struct {
+	Reports []*BattleReport `json:"reports"`
+}
+
+// GetBattleReports lists the latest battle reports of the player, either as attacker or defender.
+func (g *GameService) GetBattleReports(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	reports, err := g.Database.GetBattleReports(r.Context(), principal.UserID)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("failed to get battle reports: %w", err))
+		return
+	}
+	if reports == nil {
+		reports = []*BattleReport{}
+	}
+
+	utils.WithDefaultOKHeaders(w)
+	if err := json.NewEncoder(w).Encode(GetBattleReportsResponse{Reports: reports}); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode battle reports: %w", err))
+		return
+	}
+}
+
+// GetBattleReport gets a battle report by its ID, if the player was either the attacker or the defender.
+func (g *GameService) GetBattleReport(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	report, err := g.Database.GetBattleReport(r.Context(), id)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if !principal.Owns(report.AttackerID) && !principal.Owns(report.DefenderID) {
+		utils.WithError(w, utils.ErrForbidden)
+		return
+	}
+
+	utils.WithDefaultOKHeaders(w)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode battle report: %w", err))
+		return
+	}
+}
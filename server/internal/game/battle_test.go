@@ -0,0 +1,176 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Plunder(t *testing.T) {
+	testcases := []struct {
+		name      string
+		available Resources
+		capacity  int
+		want      Resources
+	}{
+		{
+			name:      "split evenly",
+			available: Resources{Food: 500, Sticks: 500, Stones: 500, Gems: 500},
+			capacity:  400,
+			want:      Resources{Food: 100, Sticks: 100, Stones: 100, Gems: 100},
+		},
+		{
+			name:      "re-distribute the share of scarce resources",
+			available: Resources{Food: 500, Sticks: 500, Stones: 10, Gems: 0},
+			capacity:  400,
+			want:      Resources{Food: 195, Sticks: 195, Stones: 10},
+		},
+		{
+			name:      "everything fits",
+			available: Resources{Food: 10, Sticks: 20, Stones: 30, Gems: 5},
+			capacity:  1000,
+			want:      Resources{Food: 10, Sticks: 20, Stones: 30, Gems: 5},
+		},
+		{
+			name:      "nothing to carry",
+			available: Resources{Food: 10},
+			capacity:  0,
+			want:      Resources{},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			got := plunder(testcase.available, testcase.capacity)
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Errorf("unexpected plunder diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ResolveBattleIsDeterministic(t *testing.T) {
+	// given
+	movement := &Movement{ID: "attack-1", Units: map[string]int{"spearman": 100, "swordsman": 50}}
+	defender := &City{
+		Biome:     BiomePlains,
+		Buildings: &Buildings{Walls: 3},
+		Resources: &Resources{Food: 1000, Sticks: 1000},
+		Units:     map[string]int{"spearman": 120},
+	}
+
+	// when
+	first := resolveBattle(movement, defender, 10)
+	second := resolveBattle(movement, defender, 10)
+
+	// then
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Errorf("unexpected battle diff (-first, +second): %v", diff)
+	}
+}
+
+func Test_Battle(t *testing.T) {
+	testcases := []struct {
+		name          string
+		movement      *Movement
+		defender      *City
+		wantDefender  *City
+		wantMovements map[string]*Movement
+		wantReport    *BattleReport
+	}{
+		{
+			name: "attacker wins and plunders",
+			movement: &Movement{
+				ID: "attack-1", CityID: "home", PlayerID: "attacker", Mission: MissionAttack, Q: 3, R: 0, TargetCityID: "target",
+				Units: map[string]int{"horseman": 20}, StartTick: 4, ArrivalTick: 10,
+			},
+			defender: &City{
+				ID: "target", PlayerID: "defender", Q: 3, R: 0, Biome: BiomePlains, Loyalty: 100,
+				Buildings: &Buildings{Walls: 2},
+				Resources: &Resources{Food: 500, Sticks: 500, Stones: 150, Gems: 10},
+				Units:     map[string]int{"spearman": 10},
+			},
+			wantDefender: &City{
+				ID: "target", PlayerID: "defender", Q: 3, R: 0, Biome: BiomePlains, Loyalty: 100,
+				Buildings: &Buildings{Walls: 2},
+				Resources: &Resources{Food: 100, Sticks: 100, Stones: 100, Gems: 10},
+				Units:     map[string]int{},
+			},
+			wantMovements: map[string]*Movement{
+				"attack-1": {
+					ID: "attack-1", CityID: "home", PlayerID: "attacker", Mission: MissionAttack, Q: 3, R: 0, TargetCityID: "target",
+					Units: map[string]int{"horseman": 17}, Resources: Resources{Food: 400, Sticks: 400, Stones: 50},
+					StartTick: 10, ArrivalTick: 16, Returning: true,
+				},
+			},
+			// attack: 20 * 50 with -2% luck, defense: (10 + 10 * 25) with 10% from walls and -10% from plains
+			wantReport: &BattleReport{
+				ID: "attack-1", Tick: 10, Q: 3, R: 0, Biome: BiomePlains, Walls: 2,
+				AttackerID: "attacker", AttackerCityID: "home", DefenderID: "defender", DefenderCityID: "target",
+				Luck: -2, AttackPower: 980, DefensePower: 257, AttackerWon: true,
+				Attacker: BattleSide{Units: map[string]int{"horseman": 20}, Losses: map[string]int{"horseman": 3}},
+				Defender: BattleSide{Units: map[string]int{"spearman": 10}, Losses: map[string]int{"spearman": 10}},
+				Plunder:  Resources{Food: 400, Sticks: 400, Stones: 50},
+				Loyalty:  100,
+			},
+		},
+		{
+			name: "defender wins on the mountains",
+			movement: &Movement{
+				ID: "attack-2", CityID: "home", PlayerID: "attacker", Mission: MissionAttack, Q: 3, R: 0, TargetCityID: "target",
+				Units: map[string]int{"spearman": 5}, StartTick: 4, ArrivalTick: 10,
+			},
+			defender: &City{
+				ID: "target", PlayerID: "defender", Q: 3, R: 0, Biome: BiomeMountain,
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 500},
+				Units:     map[string]int{"spearman": 10},
+			},
+			wantDefender: &City{
+				ID: "target", PlayerID: "defender", Q: 3, R: 0, Biome: BiomeMountain,
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 500},
+				Units:     map[string]int{"spearman": 9},
+			},
+			wantMovements: map[string]*Movement{},
+			// attack: 5 * 10 with -1% luck, defense: (10 + 10 * 25) with 25% from mountains
+			wantReport: &BattleReport{
+				ID: "attack-2", Tick: 10, Q: 3, R: 0, Biome: BiomeMountain,
+				AttackerID: "attacker", AttackerCityID: "home", DefenderID: "defender", DefenderCityID: "target",
+				Luck: -1, AttackPower: 49, DefensePower: 325,
+				Attacker: BattleSide{Units: map[string]int{"spearman": 5}, Losses: map[string]int{"spearman": 5}},
+				Defender: BattleSide{Units: map[string]int{"spearman": 10}, Losses: map[string]int{"spearman": 1}},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			engine := &TickEngine{TickDuration: time.Minute}
+			state := &TickState{
+				Tick: 10,
+				Cities: map[string]*City{
+					"home":   {ID: "home", PlayerID: "attacker", Resources: &Resources{}},
+					"target": testcase.defender,
+				},
+				Movements: map[string]*Movement{testcase.movement.ID: testcase.movement},
+			}
+
+			// when
+			engine.move(state)
+
+			// then
+			if diff := cmp.Diff(testcase.wantDefender, state.Cities["target"]); diff != "" {
+				t.Errorf("unexpected defender diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantMovements, state.Movements); diff != "" {
+				t.Errorf("unexpected movements diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff([]*BattleReport{testcase.wantReport}, state.Reports); diff != "" {
+				t.Errorf("unexpected reports diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+const (
+	// maxConstructionQueue is the maximum number of building upgrades a city can have queued, including
+	// the one under construction
+	maxConstructionQueue = 3
+)
+
+// buildingSpec defines the cost and duration of upgrading a building.
+//
+// The cost and duration of an upgrade to level L are the base ones scaled by Factor^(L-1).
+type buildingSpec struct {
+	MaxLevel       int
+	Cost           Resources
+	CostFactor     float64
+	Duration       time.Duration
+	DurationFactor float64
+
+	level func(*Buildings) *int
+}
+
+// buildingSpecs is the cost table of all buildings, keyed by the building name in the API.
+var buildingSpecs = map[string]*buildingSpec{
+	"cityHall": {
+		MaxLevel: 30, Cost: Resources{Food: 60, Sticks: 80, Stones: 80}, CostFactor: 1.5,
+		Duration: 2 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.CityHall },
+	},
+	"embassy": {
+		MaxLevel: 20, Cost: Resources{Food: 100, Sticks: 150, Stones: 150, Gems: 20}, CostFactor: 1.6,
+		Duration: 5 * time.Minute, DurationFactor: 1.35,
+		level: func(b *Buildings) *int { return &b.Embassy },
+	},
+	"treasury": {
+		MaxLevel: 20, Cost: Resources{Food: 50, Sticks: 100, Stones: 150, Gems: 20}, CostFactor: 1.5,
+		Duration: 4 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.Treasury },
+	},
+	"tavern": {
+		MaxLevel: 25, Cost: Resources{Food: 80, Sticks: 100, Stones: 60}, CostFactor: 1.5,
+		Duration: 3 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.Tavern },
+	},
+	"farm": {
+		MaxLevel: 30, Cost: Resources{Food: 20, Sticks: 40, Stones: 20}, CostFactor: 1.4,
+		Duration: time.Minute, DurationFactor: 1.25,
+		level: func(b *Buildings) *int { return &b.Farm },
+	},
+	"lumbermill": {
+		MaxLevel: 30, Cost: Resources{Food: 40, Sticks: 20, Stones: 30}, CostFactor: 1.4,
+		Duration: time.Minute, DurationFactor: 1.25,
+		level: func(b *Buildings) *int { return &b.Lumbermill },
+	},
+	"quarry": {
+		MaxLevel: 30, Cost: Resources{Food: 40, Sticks: 40, Stones: 10}, CostFactor: 1.4,
+		Duration: time.Minute, DurationFactor: 1.25,
+		level: func(b *Buildings) *int { return &b.Quarry },
+	},
+	"crystalMine": {
+		MaxLevel: 30, Cost: Resources{Food: 60, Sticks: 80, Stones: 100}, CostFactor: 1.5,
+		Duration: 3 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.CrystalMine },
+	},
+	"warehouse": {
+		MaxLevel: 30, Cost: Resources{Food: 30, Sticks: 60, Stones: 40}, CostFactor: 1.4,
+		Duration: 90 * time.Second, DurationFactor: 1.25,
+		level: func(b *Buildings) *int { return &b.Warehouse },
+	},
+	"market": {
+		MaxLevel: 25, Cost: Resources{Food: 80, Sticks: 120, Stones: 80, Gems: 10}, CostFactor: 1.5,
+		Duration: 4 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.Market },
+	},
+	"harbor": {
+		MaxLevel: 20, Cost: Resources{Food: 60, Sticks: 150, Stones: 80}, CostFactor: 1.5,
+		Duration: 4 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.Harbor },
+	},
+	"walls": {
+		MaxLevel: 20, Cost: Resources{Food: 20, Sticks: 60, Stones: 120}, CostFactor: 1.5,
+		Duration: 3 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.Walls },
+	},
+	"barracks": {
+		MaxLevel: 25, Cost: Resources{Food: 60, Sticks: 100, Stones: 80}, CostFactor: 1.5,
+		Duration: 3 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.Barracks },
+	},
+	"docks": {
+		MaxLevel: 25, Cost: Resources{Food: 80, Sticks: 160, Stones: 60}, CostFactor: 1.5,
+		Duration: 4 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.Docks },
+	},
+	"spyGuild": {
+		MaxLevel: 15, Cost: Resources{Food: 60, Sticks: 80, Stones: 60, Gems: 30}, CostFactor: 1.6,
+		Duration: 5 * time.Minute, DurationFactor: 1.35,
+		level: func(b *Buildings) *int { return &b.SpyGuild },
+	},
+	"library": {
+		MaxLevel: 20, Cost: Resources{Food: 60, Sticks: 120, Stones: 80, Gems: 20}, CostFactor: 1.6,
+		Duration: 5 * time.Minute, DurationFactor: 1.35,
+		level: func(b *Buildings) *int { return &b.Library },
+	},
+	"workshop": {
+		MaxLevel: 20, Cost: Resources{Food: 60, Sticks: 140, Stones: 120, Gems: 10}, CostFactor: 1.6,
+		Duration: 5 * time.Minute, DurationFactor: 1.35,
+		level: func(b *Buildings) *int { return &b.Workshop },
+	},
+	"observatory": {
+		MaxLevel: 15, Cost: Resources{Food: 40, Sticks: 80, Stones: 120, Gems: 40}, CostFactor: 1.6,
+		Duration: 6 * time.Minute, DurationFactor: 1.35,
+		level: func(b *Buildings) *int { return &b.Observatory },
+	},
+	"temple": {
+		MaxLevel: 20, Cost: Resources{Food: 80, Sticks: 100, Stones: 140, Gems: 20}, CostFactor: 1.5,
+		Duration: 5 * time.Minute, DurationFactor: 1.3,
+		level: func(b *Buildings) *int { return &b.Temple },
+	},
+	"shrine": {
+		MaxLevel: 15, Cost: Resources{Food: 60, Sticks: 60, Stones: 100, Gems: 40}, CostFactor: 1.6,
+		Duration: 6 * time.Minute, DurationFactor: 1.35,
+		level: func(b *Buildings) *int { return &b.Shrine },
+	},
+	"cathedral": {
+		MaxLevel: 10, Cost: Resources{Food: 200, Sticks: 300, Stones: 400, Gems: 100}, CostFactor: 1.7,
+		Duration: 15 * time.Minute, DurationFactor: 1.4,
+		level: func(b *Buildings) *int { return &b.Cathedral },
+	},
+}
+
+// upgradeCost returns the resources required to upgrade the building to the given level.
+func (s *buildingSpec) upgradeCost(level int) Resources {
+	factor := math.Pow(s.CostFactor, float64(level-1))
+	scale := func(v int) int { return int(math.Round(float64(v) * factor)) }
+	return Resources{
+		Food:   scale(s.Cost.Food),
+		Sticks: scale(s.Cost.Sticks),
+		Stones: scale(s.Cost.Stones),
+		Gems:   scale(s.Cost.Gems),
+	}
+}
+
+// upgradeDuration returns the time it takes to upgrade the building to the given level.
+func (s *buildingSpec) upgradeDuration(level int) time.Duration {
+	return time.Duration(float64(s.Duration) * math.Pow(s.DurationFactor, float64(level-1))).Round(time.Second)
+}
+
+// Construction defines a building upgrade in the construction queue of a city.
+type Construction struct {
+	Building  string    `json:"building"`
+	Level     int       `json:"level"`
+	StartTick int64     `json:"startTick"`
+	EndTick   int64     `json:"endTick"`
+	EndsAt    time.Time `json:"endsAt,omitzero"`
+}
+
+// upgradeOrderedPayload is the payload of an EventUpgradeOrdered.
+type upgradeOrderedPayload struct {
+	Building string `json:"building"`
+}
+
+// constructionPayload is the payload of an EventConstructionCompleted.
+type constructionPayload struct {
+	Building  string `json:"building"`
+	Level     int    `json:"level"`
+	StartTick int64  `json:"startTick"`
+	// PlayerID is the owner of the city who ordered the upgrade
+	PlayerID string `json:"playerID,omitempty"`
+}
+
+// constructionFromEvent reads the construction scheduled by an EventConstructionCompleted.
+func constructionFromEvent(e *Event) (*Construction, error) {
+	var p constructionPayload
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return nil, fmt.Errorf("invalid construction payload: %w", err)
+	}
+	return &Construction{Building: p.Building, Level: p.Level, StartTick: p.StartTick, EndTick: e.Tick}, nil
+}
+
+// nextLevel returns the level the building will have after all queued upgrades of the city.
+func (c *City) nextLevel(building string, spec *buildingSpec) int {
+	level := *spec.level(c.Buildings)
+	for _, construction := range c.Constructions {
+		if construction.Building == building {
+			level = max(level, construction.Level)
+		}
+	}
+	return level + 1
+}
+
+// validUpgrade checks if the city can order the upgrade of a building, returning the reason if not.
+func validUpgrade(c *City, building string, spec *buildingSpec) string {
+	if len(c.Constructions) >= maxConstructionQueue {
+		return "construction queue is full"
+	}
+	level := c.nextLevel(building, spec)
+	if level > spec.MaxLevel {
+		return "building is already at max level"
+	}
+	cost := spec.upgradeCost(level)
+	if !c.Resources.covers(&cost) {
+		return "not enough resources"
+	}
+	return ""
+}
+
+type UpgradeBuildingResponse struct {
+	Building string    `json:"building"`
+	Level    int       `json:"level"`
+	Cost     Resources `json:"cost"`
+}
+
+// UpgradeBuilding orders the upgrade of a building of a city to its next level.
+//
+// The endpoint does a non-binding validation and submits the order to the event queue, the resources are
+// only spent once the order is processed, and the upgrade is appended to the construction queue of the city.
+func (g *GameService) UpgradeBuilding(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	building := r.PathValue("building")
+
+	spec, ok := buildingSpecs[building]
+	if !ok {
+		utils.WithError(w, fmt.Errorf("%w: unknown building: %s", utils.ErrUserError, building))
+		return
+	}
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	city, err := g.Database.GetCity(r.Context(), id)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if !principal.Owns(city.PlayerID) {
+		utils.WithError(w, utils.ErrForbidden)
+		return
+	}
+
+	if errReason := validUpgrade(city, building, spec); errReason != "" {
+		utils.WithError(w, fmt.Errorf("%w: %s", utils.ErrUserError, errReason))
+		return
+	}
+
+	event, err := newEvent(EventUpgradeOrdered, city.ID, upgradeOrderedPayload{Building: building})
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if err := g.Database.AddEvent(r.Context(), event); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to order upgrade: %w", err))
+		return
+	}
+
+	level := city.nextLevel(building, spec)
+	rsp := UpgradeBuildingResponse{Building: building, Level: level, Cost: spec.upgradeCost(level)}
+	utils.WithDefaultAcceptedHeaders(w)
+	if err := json.NewEncoder(w).Encode(rsp); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
+
+// processUpgradeOrdered spends the resources of an upgrade order and appends it to the construction queue,
+// scheduling its completion at the end of the queue.
+func (e *TickEngine) processUpgradeOrdered(state *TickState, event *Event) error {
+	city, ok := state.Cities[event.CityID]
+	if !ok {
+		return nil
+	}
+	var p upgradeOrderedPayload
+	if err := json.Unmarshal(event.Payload, &p); err != nil {
+		log.Printf("skipping upgrade order %s: invalid payload: %v", event.Key, err)
+		return nil
+	}
+	spec, ok := buildingSpecs[p.Building]
+	if !ok {
+		log.Printf("skipping upgrade order %s: unknown building %q", event.Key, p.Building)
+		return nil
+	}
+	if validUpgrade(city, p.Building, spec) != "" {
+		return nil
+	}
+
+	level := city.nextLevel(p.Building, spec)
+	cost := spec.upgradeCost(level)
+	city.Resources.spend(&cost)
+
+	start := state.Tick
+	if n := len(city.Constructions); n > 0 {
+		start = max(start, city.Constructions[n-1].EndTick)
+	}
+	end := start + e.ticks(state.world(city.WorldID).duration(spec.upgradeDuration(level)))
+
+	completion, err := event.followUp(EventConstructionCompleted, end, constructionPayload{
+		Building:  p.Building,
+		Level:     level,
+		StartTick: start,
+		PlayerID:  city.PlayerID,
+	})
+	if err != nil {
+		return err
+	}
+	state.Schedule(completion)
+	city.Constructions = append(city.Constructions, &Construction{
+		Building:  p.Building,
+		Level:     level,
+		StartTick: start,
+		EndTick:   end,
+	})
+	return nil
+}
+
+// processConstructionCompleted raises the level of the building and removes it from the construction queue.
+func (e *TickEngine) processConstructionCompleted(state *TickState, event *Event) error {
+	city, ok := state.Cities[event.CityID]
+	if !ok || !city.queued(event) {
+		return nil
+	}
+	construction, err := constructionFromEvent(event)
+	if err != nil {
+		log.Printf("skipping construction %s: %v", event.Key, err)
+		return nil
+	}
+	spec, ok := buildingSpecs[construction.Building]
+	if !ok {
+		log.Printf("skipping construction %s: unknown building %q", event.Key, construction.Building)
+		return nil
+	}
+
+	level := spec.level(city.Buildings)
+	*level = max(*level, construction.Level)
+	state.Notify(city.PlayerID, NotificationConstructionCompleted, city.ID, construction)
+
+	for i, queued := range city.Constructions {
+		if queued.Building == construction.Building && queued.Level == construction.Level {
+			city.Constructions = append(city.Constructions[:i], city.Constructions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+func Test_UpgradeBuilding(t *testing.T) {
+	testcases := []struct {
+		name       string
+		building   string
+		city       *City
+		wantEvent  bool
+		wantStatus int
+		wantBody   []byte
+	}{
+		{
+			name:     "success",
+			building: "farm",
+			city: makeCity(func(c *City) {
+				c.Buildings = &Buildings{Farm: 1}
+				c.Resources = &Resources{Food: 100, Sticks: 100, Stones: 100}
+			}),
+			wantEvent:  true,
+			wantStatus: 202,
+			wantBody: unsafeToResponseBody(UpgradeBuildingResponse{
+				Building: "farm", Level: 2, Cost: Resources{Food: 28, Sticks: 56, Stones: 28},
+			}),
+		},
+		{
+			name:       "unknown building",
+			building:   "castle",
+			wantStatus: 400,
+			wantBody:   []byte("user error: unknown building: castle\n"),
+		},
+		{
+			name:       "forbidden",
+			building:   "farm",
+			city:       makeCity(func(c *City) { c.PlayerID = "another-user" }),
+			wantStatus: 403,
+			wantBody:   []byte("forbidden\n"),
+		},
+		{
+			name:     "not enough resources",
+			building: "cathedral",
+			city: makeCity(func(c *City) {
+				c.Buildings = &Buildings{}
+				c.Resources = &Resources{Food: 100, Sticks: 100, Stones: 100}
+			}),
+			wantStatus: 400,
+			wantBody:   []byte("user error: not enough resources\n"),
+		},
+		{
+			name:     "full construction queue",
+			building: "farm",
+			city: makeCity(func(c *City) {
+				c.Buildings = &Buildings{}
+				c.Resources = &Resources{Food: 1000, Sticks: 1000, Stones: 1000}
+				c.Constructions = []*Construction{{Building: "quarry", Level: 1}, {Building: "quarry", Level: 2}, {Building: "quarry", Level: 3}}
+			}),
+			wantStatus: 400,
+			wantBody:   []byte("user error: construction queue is full\n"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			var gotEvent *Event
+			// given
+			mockDB := &mockDatabase{
+				GetCityFunc: func(id string) (*City, error) {
+					return testcase.city, nil
+				},
+				AddEventFunc: func(e *Event) error {
+					gotEvent = e
+					return nil
+				},
+			}
+			service := &GameService{Database: mockDB}
+
+			// when
+			req := httptest.NewRequest("POST", "/api/cities/123/buildings/"+testcase.building+"/upgrade", http.NoBody)
+			req.SetPathValue("id", "123")
+			req.SetPathValue("building", testcase.building)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+			service.UpgradeBuilding(rec, req)
+
+			// then
+			if testcase.wantEvent != (gotEvent != nil) {
+				t.Errorf("unexpected event: want %v, got %+v", testcase.wantEvent, gotEvent)
+			}
+			if gotEvent != nil && (gotEvent.Type != EventUpgradeOrdered || gotEvent.Tick != 0) {
+				t.Errorf("unexpected event: %+v", gotEvent)
+			}
+			if testcase.wantStatus != rec.Code {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+			if diff := cmp.Diff(testcase.wantBody, rec.Body.Bytes()); diff != "" {
+				t.Errorf("unexpected body diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ProcessUpgrades(t *testing.T) {
+	order := func(key, building string) *Event {
+		payload, _ := json.Marshal(upgradeOrderedPayload{Building: building})
+		return &Event{Key: key, Tick: 10, Type: EventUpgradeOrdered, CityID: "city", Payload: payload}
+	}
+	completion := func(key, building string, level int, start, end int64) *Event {
+		payload, _ := json.Marshal(constructionPayload{Building: building, Level: level, StartTick: start})
+		return &Event{Key: key + "/" + EventConstructionCompleted, Tick: end, Type: EventConstructionCompleted, CityID: "city", Payload: payload}
+	}
+
+	testcases := []struct {
+		name          string
+		events        []*Event
+		city          *City
+		wantCity      *City
+		wantNewEvents []*Event
+	}{
+		{
+			name:   "upgrades are queued and paid",
+			events: []*Event{order("a", "farm"), order("b", "farm")},
+			city: &City{
+				ID:        "city",
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 100, Sticks: 100, Stones: 100},
+			},
+			wantCity: &City{
+				ID:        "city",
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 52, Sticks: 4, Stones: 52},
+				Constructions: []*Construction{
+					{Building: "farm", Level: 1, StartTick: 10, EndTick: 11},
+					{Building: "farm", Level: 2, StartTick: 11, EndTick: 13},
+				},
+			},
+			wantNewEvents: []*Event{completion("a", "farm", 1, 10, 11), completion("b", "farm", 2, 11, 13)},
+		},
+		{
+			name:   "upgrades without resources are skipped",
+			events: []*Event{order("a", "cathedral")},
+			city: &City{
+				ID:        "city",
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 100, Sticks: 100, Stones: 100},
+			},
+			wantCity: &City{
+				ID:        "city",
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 100, Sticks: 100, Stones: 100},
+			},
+		},
+		{
+			name:   "completed constructions raise the building level",
+			events: []*Event{completion("a", "farm", 1, 9, 10)},
+			city: &City{
+				ID:            "city",
+				Buildings:     &Buildings{},
+				Resources:     &Resources{},
+				Constructions: []*Construction{{Building: "farm", Level: 1, StartTick: 9, EndTick: 10}},
+			},
+			wantCity: &City{
+				ID:            "city",
+				Buildings:     &Buildings{Farm: 1},
+				Resources:     &Resources{},
+				Constructions: []*Construction{},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			engine := &TickEngine{TickDuration: time.Minute}
+			state := &TickState{Tick: 10, Events: testcase.events, Cities: map[string]*City{"city": testcase.city}}
+
+			// when
+			for _, event := range state.Events {
+				if err := engine.processEvent(state, event); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			// then
+			if diff := cmp.Diff(testcase.wantCity, state.Cities["city"]); diff != "" {
+				t.Errorf("unexpected city diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantNewEvents, state.NewEvents); diff != "" {
+				t.Errorf("unexpected new events diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/luisferreira32/stickian/server/internal/auth"
 	"github.com/luisferreira32/stickian/server/internal/utils"
 )
 
@@ -14,15 +18,26 @@ import (
 // TODO: define this in open api spec and generate it from there for
 // both server and client, with the additional benefit of api docs.
 type City struct {
-	ID        string     `json:"id"`
-	PlayerID  string     `json:"playerID"`
-	Name      string     `json:"cityName"`
-	Q         int        `json:"q"`
-	R         int        `json:"r"`
-	Biome     int        `json:"biome"`
-	Points    int        `json:"points"`
-	Buildings *Buildings `json:"buildings,omitempty"`
-	Resources *Resources `json:"resources,omitempty"`
+	ID        string         `json:"id"`
+	PlayerID  string         `json:"playerID"`
+	WorldID   string         `json:"worldID"`
+	Name      string         `json:"cityName"`
+	Q         int            `json:"q"`
+	R         int            `json:"r"`
+	Biome     int            `json:"biome"`
+	Points    int            `json:"points"`
+	Loyalty   int            `json:"loyalty,omitempty"`
+	Buildings *Buildings     `json:"buildings,omitempty"`
+	Resources *Resources     `json:"resources,omitempty"`
+	Units     map[string]int `json:"units,omitempty"`
+
+	Constructions []*Construction `json:"constructions,omitempty"`
+	Training      []*Training     `json:"training,omitempty"`
+	Production    *Resources      `json:"production,omitempty"`
+	Storage       *Storage        `json:"storage,omitempty"`
+
+	// LastSeen is when a player last saw a city that is out of their vision, which is as it was back then
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
 }
 
 type Buildings struct {
@@ -58,12 +73,91 @@ type Resources struct {
 	Faith      int `json:"faith"`
 }
 
+// covers checks if there are enough resources to pay for the cost.
+func (r *Resources) covers(cost *Resources) bool {
+	return r.Food >= cost.Food &&
+		r.Sticks >= cost.Sticks &&
+		r.Stones >= cost.Stones &&
+		r.Gems >= cost.Gems &&
+		r.Population >= cost.Population &&
+		r.Faith >= cost.Faith
+}
+
+// spend subtracts the cost from the resources, it must be checked with covers beforehand.
+func (r *Resources) spend(cost *Resources) {
+	r.Food -= cost.Food
+	r.Sticks -= cost.Sticks
+	r.Stones -= cost.Stones
+	r.Gems -= cost.Gems
+	r.Population -= cost.Population
+	r.Faith -= cost.Faith
+}
+
+// multiply multiplies the resources by the factor.
+func (r *Resources) multiply(factor int) {
+	r.Food *= factor
+	r.Sticks *= factor
+	r.Stones *= factor
+	r.Gems *= factor
+	r.Population *= factor
+	r.Faith *= factor
+}
+
+// add adds the amount to the resources.
+func (r *Resources) add(amount *Resources) {
+	r.Food += amount.Food
+	r.Sticks += amount.Sticks
+	r.Stones += amount.Stones
+	r.Gems += amount.Gems
+	r.Population += amount.Population
+	r.Faith += amount.Faith
+}
+
+// queued returns whether the event of a queue was ordered by the owner of the city, since the queues of a
+// city are lost when it is conquered. The events that do not tell who ordered them belong to the owner.
+func (c *City) queued(e *Event) bool {
+	var p struct {
+		PlayerID string `json:"playerID"`
+	}
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return true
+	}
+	return p.PlayerID == "" || p.PlayerID == c.PlayerID
+}
+
+// addPending adds an event scheduled for the future to the matching queue of the city, unless it was ordered
+// by a previous owner.
+func (c *City) addPending(e *Event) error {
+	if !c.queued(e) {
+		return nil
+	}
+	switch e.Type {
+	case EventConstructionCompleted:
+		construction, err := constructionFromEvent(e)
+		if err != nil {
+			return err
+		}
+		c.Constructions = append(c.Constructions, construction)
+	case EventUnitsTrained:
+		training, err := trainingFromEvent(e)
+		if err != nil {
+			return err
+		}
+		c.Training = append(c.Training, training)
+	}
+	return nil
+}
+
 // GetCity gets the details of a city by its ID.
+//
+// The resources are the ones accrued up to the last processed tick, and the production is the hourly
+// net rate (i.e., minus the food upkeep, and including the population growth or starvation) at the speed
+// of the world, such that clients can interpolate the resources in between ticks up to the storage capacity.
 func (g *GameService) GetCity(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	userID, ok := r.Context().Value("sub").(string)
-	if !ok || userID == "" {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
 		utils.WithError(w, utils.ErrUnauthorized)
 		return
 	}
@@ -74,11 +168,36 @@ func (g *GameService) GetCity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if city.PlayerID != userID {
+	if !principal.Owns(city.PlayerID) {
 		utils.WithError(w, utils.ErrForbidden)
 		return
 	}
 
+	if city.Buildings != nil {
+		world, err := g.Database.GetWorld(r.Context(), city.WorldID)
+		if err != nil {
+			utils.WithError(w, fmt.Errorf("failed to get world: %w", err))
+			return
+		}
+		rates := cityRates(city, world.speed())
+		city.Production = &rates
+		city.Storage = cityStorage(city)
+	}
+
+	if len(city.Constructions) > 0 || len(city.Training) > 0 {
+		clock, err := g.Database.GetClock(r.Context())
+		if err != nil {
+			utils.WithError(w, fmt.Errorf("failed to get game clock: %w", err))
+			return
+		}
+		for _, c := range city.Constructions {
+			c.EndsAt = clock.processedAt(c.EndTick, g.TickDuration)
+		}
+		for _, t := range city.Training {
+			t.EndsAt = clock.processedAt(t.EndTick, g.TickDuration)
+		}
+	}
+
 	utils.WithDefaultOKHeaders(w)
 	if err := json.NewEncoder(w).Encode(city); err != nil {
 		utils.WithError(w, fmt.Errorf("failed to encode city: %w", err))
@@ -86,10 +205,19 @@ func (g *GameService) GetCity(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// GetCities returns the city table rows for all cities whose coordinates lie
+// GetCities returns the city table rows for all cities of a world whose coordinates lie
 // within the bounding box defined by vertices (q1, r1) and (q2, r2).
 // Buildings and Resources are not included in the response.
+//
+// Only the cities within the vision of the player are returned as they are, while the ones they saw before
+// are returned as they were when last seen (see vision.go).
 func (g *GameService) GetCities(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
 	parseIntParam := func(name string) (int, error) {
 		v := r.URL.Query().Get(name)
 		if v == "" {
@@ -119,12 +247,104 @@ func (g *GameService) GetCities(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cities, err := g.Database.GetCities(r.Context(), q1, r1, q2, r2)
+	world, err := g.worldParam(r)
 	if err != nil {
 		utils.WithError(w, err)
 		return
 	}
 
+	v, err := g.playerVision(r.Context(), world, principal)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+
+	all, err := g.Database.GetCities(r.Context(), world.ID, q1, r1, q2, r2)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	var cities []*City
+	for _, c := range all {
+		if v.sees(c.Q, c.R) {
+			cities = append(cities, c)
+		}
+	}
+
+	if !v.all {
+		sightings, err := g.Database.GetSightings(r.Context(), world.ID, principal.UserID, q1, r1, q2, r2)
+		if err != nil {
+			utils.WithError(w, err)
+			return
+		}
+		seen := cities
+		for _, c := range sightings {
+			// the tiles in vision show what is there now, e.g., nothing once the city is gone
+			if !v.sees(c.Q, c.R) {
+				cities = append(cities, c)
+			}
+		}
+		if _, err := g.exploreVision(r.Context(), v, principal.UserID, seen, sightings); err != nil {
+			utils.WithError(w, err)
+			return
+		}
+		slices.SortStableFunc(cities, func(a, b *City) int {
+			return strings.Compare(a.ID, b.ID)
+		})
+	}
+
+	utils.WithDefaultOKHeaders(w)
+	if err := json.NewEncoder(w).Encode(cities); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode cities: %w", err))
+		return
+	}
+}
+
+// GetPlayerCities returns the city table rows for all cities owned by a player.
+// Buildings and Resources are not included in the response. The cities of another player are only the ones
+// in the vision of the caller, unless they are an admin.
+func (g *GameService) GetPlayerCities(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	cities, err := g.Database.GetPlayerCities(r.Context(), id)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+
+	if !principal.Owns(id) && !principal.HasAnyRole(auth.RoleAdmin) {
+		visions := map[string]*vision{}
+		var visible []*City
+		for _, c := range cities {
+			v, ok := visions[c.WorldID]
+			if !ok {
+				world, err := g.Database.GetWorld(r.Context(), c.WorldID)
+				if err != nil {
+					utils.WithError(w, err)
+					return
+				}
+				if v, err = g.playerVision(r.Context(), world, principal); err != nil {
+					utils.WithError(w, err)
+					return
+				}
+				visions[c.WorldID] = v
+			}
+			if v.sees(c.Q, c.R) {
+				visible = append(visible, c)
+			}
+		}
+		cities = visible
+	}
+	if cities == nil {
+		cities = []*City{}
+	}
+
 	utils.WithDefaultOKHeaders(w)
 	if err := json.NewEncoder(w).Encode(cities); err != nil {
 		utils.WithError(w, fmt.Errorf("failed to encode cities: %w", err))
@@ -8,36 +8,123 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
 )
 
 type mockDatabase struct {
-	GetCityFunc         func(id string) (*City, error)
-	GetCitiesFunc       func(q1, r1, q2, r2 int) ([]*City, error)
-	CreateCityFunc      func(c *City) error
-	GetMapFunc          func(minQ, maxQ, minR, maxR int) ([]*MapTile, error)
-	GetNextCitySpotFunc func() (*MapTile, error)
+	// GetWorldFunc defaults to the classic world, which most handlers need to get
+	GetWorldFunc              func(id string) (*World, error)
+	GetWorldsFunc             func() ([]*World, error)
+	CreateWorldFunc           func(w *World, tiles []*MapTile) error
+	GetCityFunc               func(id string) (*City, error)
+	GetCitiesFunc             func(q1, r1, q2, r2 int) ([]*City, error)
+	GetPlayerCitiesFunc       func(playerID string) ([]*City, error)
+	CreateCityFunc            func(c *City) error
+	GetMapFunc                func(minQ, maxQ, minR, maxR int) ([]*MapTile, error)
+	GetExploredFunc           func(playerID string) ([]byte, error)
+	ExploreFunc               func(playerID string, tiles []byte, sightings []*City) error
+	GetSightingsFunc          func(playerID string, q1, r1, q2, r2 int) ([]*City, error)
+	SettleCityFunc            func(c *City, placement PlacementStrategy) error
+	GetMovementFunc           func(id string) (*Movement, error)
+	GetCityMovementsFunc      func(cityID string) ([]*Movement, error)
+	GetBattleReportFunc       func(id string) (*BattleReport, error)
+	GetBattleReportsFunc      func(playerID string) ([]*BattleReport, error)
+	GetNotificationsFunc      func(playerID string, after int64, limit int) ([]*Notification, error)
+	GetNotificationCursorFunc func(playerID string) (int64, error)
+	AddEventFunc              func(e *Event) error
+	GetClockFunc              func() (*Clock, error)
+	ProcessTickFunc           func(tick int64, process func(*TickState) error) error
+}
+
+func (db *mockDatabase) GetWorld(_ context.Context, id string) (*World, error) {
+	if db.GetWorldFunc == nil {
+		return DefaultWorld, nil
+	}
+	return db.GetWorldFunc(id)
+}
+
+func (db *mockDatabase) GetWorlds(_ context.Context) ([]*World, error) {
+	return db.GetWorldsFunc()
+}
+
+func (db *mockDatabase) CreateWorld(_ context.Context, w *World, tiles []*MapTile) error {
+	return db.CreateWorldFunc(w, tiles)
 }
 
 func (db *mockDatabase) GetCity(_ context.Context, id string) (*City, error) {
 	return db.GetCityFunc(id)
 }
 
-func (db *mockDatabase) GetCities(_ context.Context, q1, r1, q2, r2 int) ([]*City, error) {
+func (db *mockDatabase) GetCities(_ context.Context, _ string, q1, r1, q2, r2 int) ([]*City, error) {
 	return db.GetCitiesFunc(q1, r1, q2, r2)
 }
 
+func (db *mockDatabase) GetPlayerCities(_ context.Context, playerID string) ([]*City, error) {
+	return db.GetPlayerCitiesFunc(playerID)
+}
+
 func (db *mockDatabase) CreateCity(_ context.Context, c *City) error {
 	return db.CreateCityFunc(c)
 }
 
-func (db *mockDatabase) GetMap(_ context.Context, minQ, maxQ, minR, maxR int) ([]*MapTile, error) {
+func (db *mockDatabase) GetMap(_ context.Context, _ string, minQ, maxQ, minR, maxR int) ([]*MapTile, error) {
 	return db.GetMapFunc(minQ, maxQ, minR, maxR)
 }
 
-func (db *mockDatabase) GetNextCitySpot(_ context.Context) (*MapTile, error) {
-	return db.GetNextCitySpotFunc()
+func (db *mockDatabase) GetExplored(_ context.Context, _, playerID string) ([]byte, error) {
+	return db.GetExploredFunc(playerID)
+}
+
+func (db *mockDatabase) Explore(_ context.Context, _, playerID string, tiles []byte, sightings []*City) error {
+	return db.ExploreFunc(playerID, tiles, sightings)
+}
+
+func (db *mockDatabase) GetSightings(_ context.Context, _, playerID string, q1, r1, q2, r2 int) ([]*City, error) {
+	return db.GetSightingsFunc(playerID, q1, r1, q2, r2)
+}
+
+func (db *mockDatabase) SettleCity(_ context.Context, c *City, placement PlacementStrategy) error {
+	return db.SettleCityFunc(c, placement)
+}
+
+func (db *mockDatabase) GetMovement(_ context.Context, id string) (*Movement, error) {
+	return db.GetMovementFunc(id)
+}
+
+func (db *mockDatabase) GetCityMovements(_ context.Context, cityID string) ([]*Movement, error) {
+	return db.GetCityMovementsFunc(cityID)
+}
+
+func (db *mockDatabase) GetBattleReport(_ context.Context, id string) (*BattleReport, error) {
+	return db.GetBattleReportFunc(id)
+}
+
+func (db *mockDatabase) GetBattleReports(_ context.Context, playerID string) ([]*BattleReport, error) {
+	return db.GetBattleReportsFunc(playerID)
+}
+
+func (db *mockDatabase) GetNotifications(_ context.Context, playerID string, after int64, limit int) ([]*Notification, error) {
+	return db.GetNotificationsFunc(playerID, after, limit)
+}
+
+func (db *mockDatabase) GetNotificationCursor(_ context.Context, playerID string) (int64, error) {
+	return db.GetNotificationCursorFunc(playerID)
+}
+
+func (db *mockDatabase) AddEvent(_ context.Context, e *Event) error {
+	return db.AddEventFunc(e)
+}
+
+func (db *mockDatabase) GetClock(_ context.Context) (*Clock, error) {
+	return db.GetClockFunc()
+}
+
+func (db *mockDatabase) ProcessTick(_ context.Context, tick int64, process func(*TickState) error) error {
+	return db.ProcessTickFunc(tick, process)
 }
 
 func makeCity(opts ...func(*City)) *City {
@@ -107,7 +194,7 @@ func Test_GetCity(t *testing.T) {
 			service := &GameService{Database: mockDB}
 
 			// when
-			req := testcase.request.WithContext(context.WithValue(testcase.request.Context(), "sub", "test-user"))
+			req := testcase.request.WithContext(auth.WithPrincipal(testcase.request.Context(), &auth.Principal{UserID: "test-user"}))
 			service.GetCity(rec, req)
 
 			// then
@@ -125,46 +212,124 @@ func Test_GetCity(t *testing.T) {
 }
 
 func Test_GetCities(t *testing.T) {
-	city1 := makeCity(func(c *City) { c.Q = 5; c.R = 5 })
-	city2 := makeCity(func(c *City) { c.Q = 8; c.R = 3 })
+	city1 := makeCity(func(c *City) { c.ID, c.WorldID, c.Q, c.R = "a", DefaultWorldID, 5, 5 })
+	city2 := makeCity(func(c *City) { c.ID, c.WorldID, c.Q, c.R = "b", DefaultWorldID, 8, 3 })
+	// out of the vision of the cities of the player
+	far := makeCity(func(c *City) { c.ID, c.PlayerID, c.Q, c.R = "c", "another-user", 20, 20 })
+	seenAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	sighting := makeCity(func(c *City) { c.ID, c.PlayerID, c.Q, c.R, c.LastSeen = "d", "another-user", 30, 30, &seenAt })
+	// in the vision of the cities of the player, and sighted now
+	neighbour := makeCity(func(c *City) { c.ID, c.PlayerID, c.Q, c.R = "n", "another-user", 6, 6 })
+	now := time.Now()
+	sightedNow := makeCity(func(c *City) { c.ID, c.PlayerID, c.Q, c.R, c.LastSeen = "n", "another-user", 6, 6, &now })
 
 	testcases := []struct {
-		name       string
-		query      string
-		mockRes    []*City
-		mockErr    error
-		wantQ1     int
-		wantR1     int
-		wantQ2     int
-		wantR2     int
-		wantStatus int
-		wantBody   []byte
+		name          string
+		query         string
+		principal     *auth.Principal
+		mockRes       []*City
+		mockSightings []*City
+		mockErr       error
+		wantQ1        int
+		wantR1        int
+		wantQ2        int
+		wantR2        int
+		wantSightings []string
+		wantStatus    int
+		wantBody      []byte
 	}{
 		{
-			name:    "success",
-			query:   "q1=0&r1=0&q2=10&r2=10",
-			mockRes: []*City{city1, city2},
-			wantQ1:  0, wantR1: 0, wantQ2: 10, wantR2: 10,
+			name:      "success",
+			query:     "q1=0&r1=0&q2=10&r2=10",
+			principal: &auth.Principal{UserID: "test-user"},
+			mockRes:   []*City{neighbour, city2, city1},
+			wantQ1:    0, wantR1: 0, wantQ2: 10, wantR2: 10,
+			wantSightings: []string{"n"},
+			wantStatus:    200,
+			wantBody:      unsafeToResponseBody([]*City{city1, city2, neighbour}),
+		},
+		{
+			name:          "unchanged sightings are not recorded again",
+			query:         "q1=0&r1=0&q2=10&r2=10",
+			principal:     &auth.Principal{UserID: "test-user"},
+			mockRes:       []*City{city1, neighbour},
+			mockSightings: []*City{sightedNow},
+			wantQ1:        0, wantR1: 0, wantQ2: 10, wantR2: 10,
+			wantStatus: 200,
+			wantBody:   unsafeToResponseBody([]*City{city1, neighbour}),
+		},
+		{
+			name:      "changed sightings are recorded again",
+			query:     "q1=0&r1=0&q2=10&r2=10",
+			principal: &auth.Principal{UserID: "test-user"},
+			mockRes:   []*City{city1, neighbour},
+			mockSightings: []*City{
+				makeCity(func(c *City) { c.ID, c.PlayerID, c.Q, c.R, c.Points, c.LastSeen = "n", "another-user", 6, 6, 10, &now }),
+			},
+			wantQ1: 0, wantR1: 0, wantQ2: 10, wantR2: 10,
+			wantSightings: []string{"n"},
+			wantStatus:    200,
+			wantBody:      unsafeToResponseBody([]*City{city1, neighbour}),
+		},
+		{
+			name:          "last seen cities out of vision",
+			query:         "q1=0&r1=0&q2=40&r2=40",
+			principal:     &auth.Principal{UserID: "test-user"},
+			mockRes:       []*City{city1, city2, far},
+			mockSightings: []*City{sighting},
+			wantQ1:        0, wantR1: 0, wantQ2: 40, wantR2: 40,
+			wantStatus: 200,
+			wantBody:   unsafeToResponseBody([]*City{city1, city2, sighting}),
+		},
+		{
+			name:      "sightings in vision are gone",
+			query:     "q1=0&r1=0&q2=10&r2=10",
+			principal: &auth.Principal{UserID: "test-user"},
+			mockRes:   []*City{city1},
+			mockSightings: []*City{
+				makeCity(func(c *City) { c.ID, c.PlayerID, c.Q, c.R, c.LastSeen = "e", "another-user", 6, 6, &seenAt }),
+			},
+			wantQ1: 0, wantR1: 0, wantQ2: 10, wantR2: 10,
+			wantStatus: 200,
+			wantBody:   unsafeToResponseBody([]*City{city1}),
+		},
+		{
+			name:      "admins see all cities",
+			query:     "q1=0&r1=0&q2=40&r2=40",
+			principal: &auth.Principal{UserID: "admin", Roles: []string{auth.RoleAdmin}},
+			mockRes:   []*City{city1, city2, far},
+			wantQ1:    0, wantR1: 0, wantQ2: 40, wantR2: 40,
 			wantStatus: 200,
-			wantBody:   unsafeToResponseBody([]*City{city1, city2}),
+			wantBody:   unsafeToResponseBody([]*City{city1, city2, far}),
 		},
 		{
-			name:       "empty result",
-			query:      "q1=0&r1=0&q2=1&r2=1",
-			mockRes:    nil,
+			name:      "empty result",
+			query:     "q1=0&r1=0&q2=1&r2=1",
+			principal: &auth.Principal{UserID: "test-user"},
+			mockRes:   nil,
+			wantQ1:    0, wantR1: 0, wantQ2: 1, wantR2: 1,
 			wantStatus: 200,
 			wantBody:   unsafeToResponseBody([]*City(nil)),
 		},
 		{
 			name:       "missing parameter",
 			query:      "q1=0&r1=0&q2=10",
+			principal:  &auth.Principal{UserID: "test-user"},
 			wantStatus: 400,
 			wantBody:   []byte("user error: invalid r2 parameter: missing required parameter: r2\n"),
 		},
 		{
-			name:       "database error",
+			name:       "unauthorized",
 			query:      "q1=0&r1=0&q2=10&r2=10",
-			mockErr:    errors.New("a database error"),
+			wantStatus: 401,
+			wantBody:   []byte("unauthorized\n"),
+		},
+		{
+			name:      "database error",
+			query:     "q1=0&r1=0&q2=10&r2=10",
+			principal: &auth.Principal{UserID: "test-user"},
+			mockErr:   errors.New("a database error"),
+			wantQ1:    0, wantR1: 0, wantQ2: 10, wantR2: 10,
 			wantStatus: 500,
 			wantBody:   []byte("a database error\n"),
 		},
@@ -174,29 +339,47 @@ func Test_GetCities(t *testing.T) {
 		t.Run(testcase.name, func(t *testing.T) {
 			rec := httptest.NewRecorder()
 			gotQ1, gotR1, gotQ2, gotR2 := 0, 0, 0, 0
+			var gotSightings []string
 			// given
 			mockDB := &mockDatabase{
 				GetCitiesFunc: func(q1, r1, q2, r2 int) ([]*City, error) {
 					gotQ1, gotR1, gotQ2, gotR2 = q1, r1, q2, r2
 					return testcase.mockRes, testcase.mockErr
 				},
+				GetSightingsFunc: func(playerID string, q1, r1, q2, r2 int) ([]*City, error) {
+					return testcase.mockSightings, nil
+				},
+				ExploreFunc: func(playerID string, tiles []byte, sightings []*City) error {
+					for _, c := range sightings {
+						if c.LastSeen == nil {
+							t.Errorf("sighting of %s without time", c.ID)
+						}
+						gotSightings = append(gotSightings, c.ID)
+					}
+					return nil
+				},
 			}
+			mockVision(mockDB, nil, city1, city2)
 			service := &GameService{Database: mockDB}
 			req := &http.Request{
 				Method: "GET",
 				URL:    &url.URL{Path: "/api/cities", RawQuery: testcase.query},
 			}
+			if testcase.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(context.Background(), testcase.principal))
+			}
 
 			// when
 			service.GetCities(rec, req)
 
 			// then
-			if testcase.mockErr == nil && testcase.query != "q1=0&r1=0&q2=1&r2=1" {
-				if gotQ1 != testcase.wantQ1 || gotR1 != testcase.wantR1 || gotQ2 != testcase.wantQ2 || gotR2 != testcase.wantR2 {
-					t.Errorf("unexpected coords: want (%v,%v,%v,%v), got (%v,%v,%v,%v)",
-						testcase.wantQ1, testcase.wantR1, testcase.wantQ2, testcase.wantR2,
-						gotQ1, gotR1, gotQ2, gotR2)
-				}
+			if gotQ1 != testcase.wantQ1 || gotR1 != testcase.wantR1 || gotQ2 != testcase.wantQ2 || gotR2 != testcase.wantR2 {
+				t.Errorf("unexpected coords: want (%v,%v,%v,%v), got (%v,%v,%v,%v)",
+					testcase.wantQ1, testcase.wantR1, testcase.wantQ2, testcase.wantR2,
+					gotQ1, gotR1, gotQ2, gotR2)
+			}
+			if diff := cmp.Diff(testcase.wantSightings, gotSightings); diff != "" {
+				t.Errorf("unexpected sightings diff (-want, +got): %v", diff)
 			}
 			if testcase.wantStatus != rec.Code {
 				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxLoyalty is the loyalty of a city to its owner when nobody is trying to take it over
+	maxLoyalty = 100
+	// conqueredLoyalty is the loyalty of a city to its new owner right after being conquered
+	conqueredLoyalty = 25
+	// loyaltyPerHour is the hourly loyalty a city recovers up to maxLoyalty
+	loyaltyPerHour = 2
+	// minLoyaltyHit is the minimum loyalty a unit with loyalty takes from a city in a won attack
+	minLoyaltyHit = 20
+)
+
+// loyaltyHit returns the loyalty the surviving units of a won attack take from the city, drawn from the
+// battle random generator such that it is deterministic.
+//
+// Capitals, i.e., the first city of a player in a world (see capitalID), can not be conquered.
+func loyaltyHit(rng *rand.Rand, survivors map[string]int, defender *City) int {
+	if defender.ID == capitalID(defender.WorldID, defender.PlayerID) {
+		return 0
+	}
+	hit := 0
+	// iterate in a fixed order, or the random draws would not be deterministic
+	for _, unit := range slices.Sorted(maps.Keys(survivors)) {
+		spec, ok := unitSpecs[unit]
+		if !ok || spec.Loyalty <= 0 {
+			continue
+		}
+		for range survivors[unit] {
+			hit += minLoyaltyHit + rng.IntN(spec.Loyalty-minLoyaltyHit+1)
+		}
+	}
+	return hit
+}
+
+// conquer hands the city over to the attacker, stationing the surviving units in it. One of the units
+// with loyalty is spent to take over the city.
+func conquer(m *Movement, city *City, survivors map[string]int) {
+	for _, unit := range slices.Sorted(maps.Keys(survivors)) {
+		if spec, ok := unitSpecs[unit]; ok && spec.Loyalty > 0 {
+			survivors[unit]--
+			if survivors[unit] == 0 {
+				delete(survivors, unit)
+			}
+			break
+		}
+	}
+	city.PlayerID = m.PlayerID
+	city.Loyalty = conqueredLoyalty
+	city.addUnits(survivors)
+}
+
+// colonize founds a new city for the player at the target tile of the movement, if the tile is still free,
+// stationing the escort in it. One of the settlers is spent to found the city. It returns false otherwise.
+//
+// The city ID is derived from the movement ID, such that re-processing the tick founds the exact same city.
+func colonize(state *TickState, m *Movement) bool {
+	home, ok := state.Cities[m.CityID]
+	if !ok {
+		return false
+	}
+	tile := state.tileAt(home.WorldID, m.Q, m.R)
+	if tile == nil || !tile.Settleable || state.cityAt(home.WorldID, m.Q, m.R) != nil {
+		return false
+	}
+
+	units := maps.Clone(m.Units)
+	for _, unit := range slices.Sorted(maps.Keys(units)) {
+		if spec, ok := unitSpecs[unit]; ok && spec.Settler {
+			units[unit]--
+			if units[unit] == 0 {
+				delete(units, unit)
+			}
+			break
+		}
+	}
+	resources := *InitialResources
+	city := &City{
+		ID:        uuid.NewSHA1(uuid.NameSpaceOID, []byte(m.ID)).String(),
+		PlayerID:  m.PlayerID,
+		WorldID:   home.WorldID,
+		Name:      m.CityName,
+		Q:         tile.Q,
+		R:         tile.R,
+		Biome:     tile.Biome,
+		Loyalty:   maxLoyalty,
+		Buildings: &Buildings{},
+		Resources: &resources,
+	}
+	city.addUnits(units)
+	state.Cities[city.ID] = city
+	state.Notify(city.PlayerID, NotificationCityFounded, city.ID, cityFoundedPayload{CityID: city.ID, Name: city.Name})
+	return true
+}
+
+// restoreLoyalty recovers the loyalty of all cities during the tick, at the speed of their world.
+func (e *TickEngine) restoreLoyalty(state *TickState) {
+	for _, city := range state.Cities {
+		if city.Loyalty < maxLoyalty {
+			rate := loyaltyPerHour * state.world(city.WorldID).speed()
+			city.Loyalty = min(maxLoyalty, city.Loyalty+perTick(rate, state.Tick, e.TickDuration))
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package game
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+func Test_Conquest(t *testing.T) {
+	attack := func(units map[string]int) *Movement {
+		return &Movement{
+			ID: "attack-1", CityID: "home", PlayerID: "attacker", Mission: MissionAttack, Q: 3, R: 0, TargetCityID: "target",
+			Units: units, StartTick: 4, ArrivalTick: 10,
+		}
+	}
+
+	testcases := []struct {
+		name          string
+		movement      *Movement
+		defender      *City
+		wantDefender  *City
+		wantMovements map[string]*Movement
+	}{
+		{
+			name:     "envoys take over a city without loyalty",
+			movement: attack(map[string]int{"horseman": 20, "envoy": 2}),
+			defender: &City{
+				ID: "target", PlayerID: "defender", Q: 3, R: 0, Loyalty: 30,
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 500},
+			},
+			wantDefender: &City{
+				ID: "target", PlayerID: "attacker", Q: 3, R: 0, Loyalty: conqueredLoyalty,
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 500},
+				Units:     map[string]int{"horseman": 20, "envoy": 1},
+			},
+			wantMovements: map[string]*Movement{},
+		},
+		{
+			name:     "capitals can not be conquered",
+			movement: attack(map[string]int{"horseman": 20, "envoy": 2}),
+			defender: &City{
+				ID: "target", PlayerID: "target", WorldID: DefaultWorldID, Q: 3, R: 0, Loyalty: 30,
+				Buildings: &Buildings{},
+				Resources: &Resources{},
+			},
+			wantDefender: &City{
+				ID: "target", PlayerID: "target", WorldID: DefaultWorldID, Q: 3, R: 0, Loyalty: 30,
+				Buildings: &Buildings{},
+				Resources: &Resources{},
+			},
+			wantMovements: map[string]*Movement{
+				"attack-1": {
+					ID: "attack-1", CityID: "home", PlayerID: "attacker", Mission: MissionAttack, Q: 3, R: 0, TargetCityID: "target",
+					Units: map[string]int{"horseman": 20, "envoy": 2}, StartTick: 10, ArrivalTick: 16, Returning: true,
+				},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			engine := &TickEngine{TickDuration: time.Minute}
+			state := &TickState{
+				Tick: 10,
+				Cities: map[string]*City{
+					"home":   {ID: "home", PlayerID: "attacker", Resources: &Resources{}},
+					"target": testcase.defender,
+				},
+				Movements: map[string]*Movement{testcase.movement.ID: testcase.movement},
+			}
+
+			// when
+			engine.move(state)
+
+			// then
+			if diff := cmp.Diff(testcase.wantDefender, state.Cities["target"]); diff != "" {
+				t.Errorf("unexpected defender diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantMovements, state.Movements); diff != "" {
+				t.Errorf("unexpected movements diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_Colonize(t *testing.T) {
+	colonize := func() *Movement {
+		return &Movement{
+			ID: "colonize-1", CityID: "home", PlayerID: "player", Mission: MissionColonize, Q: 3, R: 0, CityName: "Colony",
+			Units: map[string]int{"settler": 1, "spearman": 10}, StartTick: 4, ArrivalTick: 10,
+		}
+	}
+	colonyID := uuid.NewSHA1(uuid.NameSpaceOID, []byte("colonize-1")).String()
+	resources := *InitialResources
+
+	testcases := []struct {
+		name          string
+		tiles         []*MapTile
+		cities        map[string]*City
+		wantCities    map[string]*City
+		wantMovements map[string]*Movement
+	}{
+		{
+			name:   "settlers found a city on a free tile",
+			tiles:  []*MapTile{{Q: 3, R: 0, Biome: BiomePlains, Settleable: true}},
+			cities: map[string]*City{},
+			wantCities: map[string]*City{
+				colonyID: {
+					ID: colonyID, PlayerID: "player", Name: "Colony", Q: 3, R: 0, Biome: BiomePlains, Loyalty: maxLoyalty,
+					Buildings: &Buildings{}, Resources: &resources, Units: map[string]int{"spearman": 10},
+				},
+			},
+			wantMovements: map[string]*Movement{},
+		},
+		{
+			name:  "settlers turn back from an occupied tile",
+			tiles: []*MapTile{{Q: 3, R: 0, Biome: BiomePlains, Settleable: true}},
+			cities: map[string]*City{
+				"another": {ID: "another", PlayerID: "another-player", Q: 3, R: 0},
+			},
+			wantCities: map[string]*City{
+				"another": {ID: "another", PlayerID: "another-player", Q: 3, R: 0},
+			},
+			wantMovements: map[string]*Movement{
+				"colonize-1": {
+					ID: "colonize-1", CityID: "home", PlayerID: "player", Mission: MissionColonize, Q: 3, R: 0, CityName: "Colony",
+					Units: map[string]int{"settler": 1, "spearman": 10}, StartTick: 10, ArrivalTick: 16, Returning: true,
+				},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			engine := &TickEngine{TickDuration: time.Minute}
+			m := colonize()
+			testcase.cities["home"] = &City{ID: "home", PlayerID: "player", Resources: &Resources{}}
+			state := &TickState{
+				Tick:      10,
+				Cities:    testcase.cities,
+				Movements: map[string]*Movement{m.ID: m},
+				Tiles:     testcase.tiles,
+			}
+
+			// when
+			engine.move(state)
+
+			// then
+			delete(state.Cities, "home")
+			if diff := cmp.Diff(testcase.wantCities, state.Cities); diff != "" {
+				t.Errorf("unexpected cities diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantMovements, state.Movements); diff != "" {
+				t.Errorf("unexpected movements diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_RestoreLoyalty(t *testing.T) {
+	// given
+	engine := &TickEngine{TickDuration: time.Hour}
+	state := &TickState{Tick: 1, Cities: map[string]*City{
+		"conquered": {Loyalty: conqueredLoyalty},
+		"loyal":     {Loyalty: maxLoyalty - 1},
+	}}
+
+	// when
+	engine.restoreLoyalty(state)
+
+	// then
+	if got := state.Cities["conquered"].Loyalty; got != conqueredLoyalty+loyaltyPerHour {
+		t.Errorf("unexpected loyalty: want %v, got %v", conqueredLoyalty+loyaltyPerHour, got)
+	}
+	if got := state.Cities["loyal"].Loyalty; got != maxLoyalty {
+		t.Errorf("unexpected loyalty: want %v, got %v", maxLoyalty, got)
+	}
+}
+
+func Test_GetPlayerCities(t *testing.T) {
+	near := makeCity(func(c *City) { c.ID, c.WorldID = "near", DefaultWorldID })
+	far := makeCity(func(c *City) { c.ID, c.WorldID, c.Q, c.R = "far", DefaultWorldID, 100, 100 })
+	// three tiles from the near city, in its vision
+	watcher := makeCity(func(c *City) { c.ID, c.WorldID, c.R, c.PlayerID = "watcher", DefaultWorldID, 8, "another-user" })
+	testcases := []struct {
+		name      string
+		principal *auth.Principal
+		want      []*City
+	}{
+		{
+			name:      "own cities",
+			principal: &auth.Principal{UserID: "test-user"},
+			want:      []*City{near, far},
+		},
+		{
+			name:      "admins see all cities",
+			principal: &auth.Principal{UserID: "admin", Roles: []string{auth.RoleAdmin}},
+			want:      []*City{near, far},
+		},
+		{
+			name:      "cities of another player in vision",
+			principal: &auth.Principal{UserID: "another-user"},
+			want:      []*City{near},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			mockDB := &mockDatabase{}
+			mockVision(mockDB, nil, watcher)
+			mockDB.GetPlayerCitiesFunc = func(playerID string) ([]*City, error) {
+				switch playerID {
+				case "test-user":
+					return []*City{near, far}, nil
+				case "another-user":
+					return []*City{watcher}, nil
+				}
+				return nil, nil
+			}
+			service := &GameService{Database: mockDB}
+			rec := httptest.NewRecorder()
+
+			// when
+			req := httptest.NewRequest("GET", "/api/players/test-user/cities", http.NoBody)
+			req.SetPathValue("id", "test-user")
+			req = req.WithContext(auth.WithPrincipal(req.Context(), testcase.principal))
+			service.GetPlayerCities(rec, req)
+
+			// then
+			if rec.Code != 200 {
+				t.Errorf("unexpected status code: want 200, got %v", rec.Code)
+			}
+			if diff := cmp.Diff(unsafeToResponseBody(testcase.want), rec.Body.Bytes()); diff != "" {
+				t.Errorf("unexpected body diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -2,33 +2,147 @@ package game
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"reflect"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/luisferreira32/stickian/server/internal/utils"
 )
 
 type MapTile struct {
-	Q     int
-	R     int
-	Biome int
+	WorldID    string
+	Q          int
+	R          int
+	Biome      int
+	Settleable bool
 }
 
 type GameDatabase interface {
+	GetWorld(ctx context.Context, id string) (*World, error)
+	GetWorlds(ctx context.Context) ([]*World, error)
+	CreateWorld(ctx context.Context, w *World, tiles []*MapTile) error
 	GetCity(ctx context.Context, id string) (*City, error)
-	GetCities(ctx context.Context, q1, r1, q2, r2 int) ([]*City, error)
+	GetCities(ctx context.Context, worldID string, q1, r1, q2, r2 int) ([]*City, error)
+	GetPlayerCities(ctx context.Context, playerID string) ([]*City, error)
 	CreateCity(ctx context.Context, c *City) error
-	GetMap(ctx context.Context, minQ, maxQ, minR, maxR int) ([]*MapTile, error)
-	GetNextCitySpot(ctx context.Context) (*MapTile, error)
+	GetMap(ctx context.Context, worldID string, minQ, maxQ, minR, maxR int) ([]*MapTile, error)
+	GetExplored(ctx context.Context, worldID, playerID string) ([]byte, error)
+	Explore(ctx context.Context, worldID, playerID string, tiles []byte, sightings []*City) error
+	GetSightings(ctx context.Context, worldID, playerID string, q1, r1, q2, r2 int) ([]*City, error)
+	SettleCity(ctx context.Context, c *City, placement PlacementStrategy) error
+	GetMovement(ctx context.Context, id string) (*Movement, error)
+	GetCityMovements(ctx context.Context, cityID string) ([]*Movement, error)
+	GetBattleReport(ctx context.Context, id string) (*BattleReport, error)
+	GetBattleReports(ctx context.Context, playerID string) ([]*BattleReport, error)
+	GetNotifications(ctx context.Context, playerID string, after int64, limit int) ([]*Notification, error)
+	GetNotificationCursor(ctx context.Context, playerID string) (int64, error)
+
+	// event queue
+	AddEvent(ctx context.Context, e *Event) error
+	GetClock(ctx context.Context) (*Clock, error)
+	ProcessTick(ctx context.Context, tick int64, process func(*TickState) error) error
 }
 
+// PostgresDatabase is the implementation of the GameDatabase on a Postgres connection pool. Writes spanning
+// multiple statements always run in a single transaction.
 type PostgresDatabase struct {
-	DB *pgx.Conn
+	DB *pgxpool.Pool
+}
+
+const selectWorldQuery = `SELECT id, name, size, speed, status, starts_at, ends_at, placement FROM worlds`
+
+const getWorldQuery = selectWorldQuery + `
+	WHERE id = $1`
+
+const getWorldsQuery = selectWorldQuery + `
+	ORDER BY starts_at, name`
+
+func scanWorld(row pgx.Row) (*World, error) {
+	w := &World{}
+	err := row.Scan(&w.ID, &w.Name, &w.Size, &w.Speed, &w.Status, &w.StartsAt, &w.EndsAt, &w.Placement)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (db *PostgresDatabase) GetWorld(ctx context.Context, id string) (*World, error) {
+	if uuid.Validate(id) != nil {
+		return nil, utils.ErrNotFound
+	}
+	w, err := scanWorld(db.DB.QueryRow(ctx, getWorldQuery, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GetWorlds returns all worlds, in order of start.
+func (db *PostgresDatabase) GetWorlds(ctx context.Context) ([]*World, error) {
+	rows, err := db.DB.Query(ctx, getWorldsQuery)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*World, error) {
+		return scanWorld(row)
+	})
 }
 
-const getCityQuery = `SELECT
-	c.id, c.player_id, c.name, c.q, c.r, c.biome, c.points,
+const createWorldQuery = `INSERT INTO worlds (id, name, size, speed, status, starts_at, ends_at, placement)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (id) DO UPDATE SET size = EXCLUDED.size, placement = EXCLUDED.placement`
+
+const lockWorldQuery = `SELECT 1 FROM worlds WHERE id = $1 FOR UPDATE`
+
+const worldTilesExistQuery = `SELECT EXISTS (SELECT 1 FROM world WHERE world_id = $1)`
+
+// CreateWorld creates the world with its tiles, in a single transaction. A world that exists without tiles
+// (e.g., the classic world of a new database) gets the tiles, along with their size and placement, while one that already has them is left as is
+// and ErrWorldExists is returned: replacing the map under the cities of a running game would leave them in
+// the middle of the ocean.
+func (db *PostgresDatabase) CreateWorld(ctx context.Context, w *World, tiles []*MapTile) error {
+	err := pgx.BeginFunc(ctx, db.DB, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, createWorldQuery, w.ID, w.Name, w.Size, w.Speed, w.Status, w.StartsAt, w.EndsAt, w.Placement)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, lockWorldQuery, w.ID); err != nil {
+			return err
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, worldTilesExistQuery, w.ID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrWorldExists
+		}
+
+		rows := make([][]any, len(tiles))
+		for i, t := range tiles {
+			rows[i] = []any{w.ID, t.Q, t.R, t.Biome, t.Settleable}
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"world"}, []string{"world_id", "q", "r", "biome", "settleable"}, pgx.CopyFromRows(rows))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("world creation: %w", err)
+	}
+	return nil
+}
+
+const selectCityQuery = `SELECT
+	c.id, c.player_id, c.world_id, c.name, c.q, c.r, c.biome, c.points, c.loyalty,
 	cr.food, cr.sticks, cr.stones, cr.gems, cr.population, cr.faith,
 	cb.city_hall, cb.embassy, cb.treasury, cb.tavern,
 	cb.farm, cb.lumbermill, cb.quarry, cb.crystal_mine,
@@ -37,22 +151,27 @@ const getCityQuery = `SELECT
 	cb.workshop, cb.observatory, cb.temple, cb.shrine, cb.cathedral
 	FROM city c
 	LEFT JOIN city_resources cr ON cr.city_id = c.id
-	LEFT JOIN city_buildings cb ON cb.city_id = c.id
+	LEFT JOIN city_buildings cb ON cb.city_id = c.id`
+
+const getCityQuery = selectCityQuery + `
 	WHERE c.id = $1`
 
-func (db *PostgresDatabase) GetCity(ctx context.Context, id string) (*City, error) {
+// scanCity scans a row of the selectCityQuery into a city with its buildings and resources.
+func scanCity(row pgx.Row) (*City, error) {
 	city := &City{
 		Resources: &Resources{},
 		Buildings: &Buildings{},
 	}
-	err := db.DB.QueryRow(ctx, getCityQuery, id).Scan(
+	err := row.Scan(
 		&city.ID,
 		&city.PlayerID,
+		&city.WorldID,
 		&city.Name,
 		&city.Q,
 		&city.R,
 		&city.Biome,
 		&city.Points,
+		&city.Loyalty,
 		&city.Resources.Food,
 		&city.Resources.Sticks,
 		&city.Resources.Stones,
@@ -81,22 +200,77 @@ func (db *PostgresDatabase) GetCity(ctx context.Context, id string) (*City, erro
 		&city.Buildings.Shrine,
 		&city.Buildings.Cathedral,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return city, nil
+}
+
+const getCityPendingQuery = `SELECT e.key, e.tick, e.type, COALESCE(e.city_id::text, ''), e.payload
+	FROM game_events e, game_clock c
+	WHERE e.city_id = $1 AND e.type = ANY($2) AND e.tick > c.last_tick
+	ORDER BY e.tick, e.seq`
+
+const getCityUnitsQuery = `SELECT unit, count FROM city_units WHERE city_id = $1 AND count > 0`
+
+// pendingEventTypes are the types of the events scheduled for the future that make up the queues of a city.
+var pendingEventTypes = []string{EventConstructionCompleted, EventUnitsTrained}
+
+// GetCity returns a city with its buildings, resources, units, and construction and training queues.
+func (db *PostgresDatabase) GetCity(ctx context.Context, id string) (*City, error) {
+	city, err := scanCity(db.DB.QueryRow(ctx, getCityQuery, id))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, utils.ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
+
+	rows, err := db.DB.Query(ctx, getCityUnitsQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("city units: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			unit  string
+			count int
+		)
+		if err := rows.Scan(&unit, &count); err != nil {
+			return nil, fmt.Errorf("city units: %w", err)
+		}
+		if city.Units == nil {
+			city.Units = make(map[string]int)
+		}
+		city.Units[unit] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("city units: %w", err)
+	}
+
+	rows, err = db.DB.Query(ctx, getCityPendingQuery, id, pendingEventTypes)
+	if err != nil {
+		return nil, fmt.Errorf("city queues: %w", err)
+	}
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("city queues: %w", err)
+	}
+	for _, e := range events {
+		if err := city.addPending(e); err != nil {
+			return nil, err
+		}
+	}
 	return city, nil
 }
 
-const getCitiesByBoundsQuery = `SELECT id, player_id, name, q, r, biome, points FROM city
-	WHERE q BETWEEN $1 AND $2 AND r BETWEEN $3 AND $4`
+const getCitiesByBoundsQuery = `SELECT id, player_id, world_id, name, q, r, biome, points FROM city
+	WHERE world_id = $1 AND q BETWEEN $2 AND $3 AND r BETWEEN $4 AND $5`
 
-// GetCities returns all cities within the bounding box defined by vertices
+// GetCities returns all cities of a world within the bounding box defined by vertices
 // (q1, r1) and (q2, r2). The range is normalised so order does not matter.
 // Only city table fields are returned — Buildings and Resources are omitted.
-func (db *PostgresDatabase) GetCities(ctx context.Context, q1, r1, q2, r2 int) ([]*City, error) {
+func (db *PostgresDatabase) GetCities(ctx context.Context, worldID string, q1, r1, q2, r2 int) ([]*City, error) {
 	minQ, maxQ := q1, q2
 	if minQ > maxQ {
 		minQ, maxQ = maxQ, minQ
@@ -105,7 +279,7 @@ func (db *PostgresDatabase) GetCities(ctx context.Context, q1, r1, q2, r2 int) (
 	if minR > maxR {
 		minR, maxR = maxR, minR
 	}
-	rows, err := db.DB.Query(ctx, getCitiesByBoundsQuery, minQ, maxQ, minR, maxR)
+	rows, err := db.DB.Query(ctx, getCitiesByBoundsQuery, worldID, minQ, maxQ, minR, maxR)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +289,7 @@ func (db *PostgresDatabase) GetCities(ctx context.Context, q1, r1, q2, r2 int) (
 	for rows.Next() {
 		city := &City{}
 		if err := rows.Scan(
-			&city.ID, &city.PlayerID, &city.Name, &city.Q, &city.R, &city.Biome, &city.Points,
+			&city.ID, &city.PlayerID, &city.WorldID, &city.Name, &city.Q, &city.R, &city.Biome, &city.Points,
 		); err != nil {
 			return nil, err
 		}
@@ -124,8 +298,33 @@ func (db *PostgresDatabase) GetCities(ctx context.Context, q1, r1, q2, r2 int) (
 	return cities, nil
 }
 
-const createCityQuery = `INSERT INTO city (id, player_id, name, q, r, biome, points)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
+const getPlayerCitiesQuery = `SELECT id, player_id, world_id, name, q, r, biome, points FROM city
+	WHERE player_id = $1
+	ORDER BY name, id`
+
+// GetPlayerCities returns all cities owned by a player, in all worlds. Only city table fields are returned.
+func (db *PostgresDatabase) GetPlayerCities(ctx context.Context, playerID string) ([]*City, error) {
+	rows, err := db.DB.Query(ctx, getPlayerCitiesQuery, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cities []*City
+	for rows.Next() {
+		city := &City{}
+		if err := rows.Scan(
+			&city.ID, &city.PlayerID, &city.WorldID, &city.Name, &city.Q, &city.R, &city.Biome, &city.Points,
+		); err != nil {
+			return nil, err
+		}
+		cities = append(cities, city)
+	}
+	return cities, rows.Err()
+}
+
+const createCityQuery = `INSERT INTO city (id, player_id, world_id, name, q, r, biome, points, loyalty)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	ON CONFLICT DO NOTHING`
 
 const createCityResourcesQuery = `INSERT INTO city_resources (city_id, food, sticks, stones, gems, population, faith)
@@ -136,66 +335,25 @@ const createCityBuildingsQuery = `INSERT INTO city_buildings (city_id, city_hall
 	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
 	ON CONFLICT DO NOTHING`
 
+// CreateCity creates the city with its resources and buildings in a single transaction, such that a city
+// can never be left half-created.
 func (db *PostgresDatabase) CreateCity(ctx context.Context, c *City) error {
-	_, err := db.DB.Exec(ctx, createCityQuery,
-		c.ID,
-		c.PlayerID,
-		c.Name,
-		c.Q,
-		c.R,
-		c.Biome,
-		c.Points,
-	)
+	err := pgx.BeginFunc(ctx, db.DB, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		queueCreateCity(batch, c)
+		return tx.SendBatch(ctx, batch).Close()
+	})
 	if err != nil {
 		return fmt.Errorf("city creation: %w", err)
 	}
-	_, err = db.DB.Exec(ctx, createCityResourcesQuery,
-		c.ID,
-		c.Resources.Food,
-		c.Resources.Sticks,
-		c.Resources.Stones,
-		c.Resources.Gems,
-		c.Resources.Population,
-		c.Resources.Faith,
-	)
-	if err != nil {
-		return fmt.Errorf("city resources: %w", err)
-	}
-	_, err = db.DB.Exec(ctx, createCityBuildingsQuery,
-		c.ID,
-		c.Buildings.CityHall,
-		c.Buildings.Embassy,
-		c.Buildings.Treasury,
-		c.Buildings.Tavern,
-		c.Buildings.Farm,
-		c.Buildings.Lumbermill,
-		c.Buildings.Quarry,
-		c.Buildings.CrystalMine,
-		c.Buildings.Warehouse,
-		c.Buildings.Market,
-		c.Buildings.Harbor,
-		c.Buildings.Walls,
-		c.Buildings.Barracks,
-		c.Buildings.Docks,
-		c.Buildings.SpyGuild,
-		c.Buildings.Library,
-		c.Buildings.Workshop,
-		c.Buildings.Observatory,
-		c.Buildings.Temple,
-		c.Buildings.Shrine,
-		c.Buildings.Cathedral,
-	)
-	if err != nil {
-		return fmt.Errorf("city buildings: %w", err)
-	}
-
 	return nil
 }
 
-const getMapQuery = `SELECT q, r, biome FROM world WHERE q BETWEEN $1 AND $2 AND r BETWEEN $3 AND $4`
+const getMapQuery = `SELECT world_id, q, r, biome, settleable FROM world
+	WHERE world_id = $1 AND q BETWEEN $2 AND $3 AND r BETWEEN $4 AND $5`
 
-func (db *PostgresDatabase) GetMap(ctx context.Context, minQ, maxQ, minR, maxR int) ([]*MapTile, error) {
-	rows, err := db.DB.Query(ctx, getMapQuery, minQ, maxQ, minR, maxR)
+func (db *PostgresDatabase) GetMap(ctx context.Context, worldID string, minQ, maxQ, minR, maxR int) ([]*MapTile, error) {
+	rows, err := db.DB.Query(ctx, getMapQuery, worldID, minQ, maxQ, minR, maxR)
 	if err != nil {
 		return nil, err
 	}
@@ -204,7 +362,7 @@ func (db *PostgresDatabase) GetMap(ctx context.Context, minQ, maxQ, minR, maxR i
 	var tiles []*MapTile
 	for rows.Next() {
 		var t MapTile
-		err := rows.Scan(&t.Q, &t.R, &t.Biome)
+		err := rows.Scan(&t.WorldID, &t.Q, &t.R, &t.Biome, &t.Settleable)
 		if err != nil {
 			return nil, err
 		}
@@ -214,29 +372,755 @@ func (db *PostgresDatabase) GetMap(ctx context.Context, minQ, maxQ, minR, maxR i
 	return tiles, nil
 }
 
-const getNextCitySpotQuery = `
-SELECT w.q, w.r, w.biome 
-FROM world w
-LEFT JOIN city c ON w.q = c.q AND w.r = c.r
-WHERE w.settleable=true
-AND c.id IS NULL
-ORDER BY w.q+w.r
-LIMIT 1`
+const getExploredQuery = `SELECT tiles FROM explored_tiles WHERE world_id = $1 AND player_id = $2`
+
+// GetExplored returns the tiles the player explored in the world, nil if none.
+func (db *PostgresDatabase) GetExplored(ctx context.Context, worldID, playerID string) ([]byte, error) {
+	var tiles pgtype.Bits
+	err := db.DB.QueryRow(ctx, getExploredQuery, worldID, playerID).Scan(&tiles)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tiles.Bytes, nil
+}
+
+const exploreQuery = `INSERT INTO explored_tiles (player_id, world_id, tiles)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (player_id, world_id) DO UPDATE SET tiles = explored_tiles.tiles | EXCLUDED.tiles`
+
+const sightCityQuery = `INSERT INTO city_sightings (player_id, city_id, world_id, owner_id, name, q, r, biome, points, seen_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (player_id, city_id) DO UPDATE SET
+	owner_id = EXCLUDED.owner_id, name = EXCLUDED.name, points = EXCLUDED.points, seen_at = EXCLUDED.seen_at`
+
+// Explore adds the tiles to the ones the player explored in the world, and records the sightings of the cities,
+// replacing the previous sightings of the same cities, all in a single transaction. The explored tiles of a
+// world always have the same length, see vision.explore.
+func (db *PostgresDatabase) Explore(ctx context.Context, worldID, playerID string, tiles []byte, sightings []*City) error {
+	err := pgx.BeginFunc(ctx, db.DB, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		bits := pgtype.Bits{Bytes: tiles, Len: int32(len(tiles) * 8), Valid: true}
+		batch.Queue(exploreQuery, playerID, worldID, bits)
+		for _, c := range sightings {
+			batch.Queue(sightCityQuery, playerID, c.ID, c.WorldID, c.PlayerID, c.Name, c.Q, c.R, c.Biome, c.Points, c.LastSeen)
+		}
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return fmt.Errorf("explore: %w", err)
+	}
+	return nil
+}
+
+const getSightingsQuery = `SELECT city_id, owner_id, world_id, name, q, r, biome, points, seen_at FROM city_sightings
+	WHERE player_id = $1 AND world_id = $2 AND q BETWEEN $3 AND $4 AND r BETWEEN $5 AND $6
+	ORDER BY city_id`
+
+// GetSightings returns the cities the player saw in the world within the bounding box defined by vertices
+// (q1, r1) and (q2, r2), as they were when last seen.
+func (db *PostgresDatabase) GetSightings(ctx context.Context, worldID, playerID string, q1, r1, q2, r2 int) ([]*City, error) {
+	rows, err := db.DB.Query(ctx, getSightingsQuery, playerID, worldID, min(q1, q2), max(q1, q2), min(r1, r2), max(r1, r2))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*City, error) {
+		c := &City{LastSeen: &time.Time{}}
+		err := row.Scan(&c.ID, &c.PlayerID, &c.WorldID, &c.Name, &c.Q, &c.R, &c.Biome, &c.Points, c.LastSeen)
+		return c, err
+	})
+}
+
+const getWorldTilesQuery = `SELECT world_id, q, r, biome, settleable FROM world WHERE world_id = $1`
 
-// GetNextCitySpot returns the next available spot for a new city.
+const getCitySpotsQuery = `SELECT id, player_id, q, r FROM city WHERE world_id = $1`
+
+const lockCitySpotQuery = `SELECT w.biome FROM world w
+	WHERE w.world_id = $1 AND w.q = $2 AND w.r = $3 AND w.settleable
+	AND NOT EXISTS (SELECT 1 FROM city c WHERE c.world_id = w.world_id AND c.q = w.q AND c.r = w.r)
+	FOR UPDATE OF w SKIP LOCKED`
+
+const getCitySpotQuery = `SELECT q, r, biome FROM city WHERE id = $1`
+
+const settleCityQuery = `INSERT INTO city (id, player_id, world_id, name, q, r, biome, points, loyalty)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (id) DO NOTHING`
+
+// maxSettleAttempts is the number of times a city placement is retried after losing a tile to a concurrent one
+const maxSettleAttempts = 3
+
+// SettleCity places the new city on the best free spot of its world according to the placement strategy and
+// creates it, all in a single transaction. If a city with the same ID already exists it is left as is, and c is set to
+// its spot instead.
 //
-// The spot is determined by the lowest sum of q and r coordinates, which creates a diagonal pattern
-// of city placement starting from the origin (0, 0) and moving outward. Only settleable tiles that
-// are not already occupied by a city are considered.
-func (db *PostgresDatabase) GetNextCitySpot(ctx context.Context) (*MapTile, error) {
-	row := db.DB.QueryRow(ctx, getNextCitySpotQuery)
-	var t MapTile
-	err := row.Scan(&t.Q, &t.R, &t.Biome)
+// The chosen tile is locked until the city is created, and tiles locked by concurrent placements are
+// skipped, such that several servers can place cities at the same time without colliding.
+func (db *PostgresDatabase) SettleCity(ctx context.Context, c *City, placement PlacementStrategy) error {
+	for attempt := 1; ; attempt++ {
+		err := pgx.BeginFunc(ctx, db.DB, func(tx pgx.Tx) error {
+			return settleCity(ctx, tx, c, placement)
+		})
+		// a placement that read the tile right before a concurrent one committed a city on it still collides
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "city_unique_coords" && attempt < maxSettleAttempts {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("settle city: %w", err)
+		}
+		return nil
+	}
+}
+
+func settleCity(ctx context.Context, tx pgx.Tx, c *City, placement PlacementStrategy) error {
+	err := tx.QueryRow(ctx, getCitySpotQuery, c.ID).Scan(&c.Q, &c.R, &c.Biome)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, getWorldTilesQuery, c.WorldID)
+	if err != nil {
+		return err
+	}
+	tiles, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*MapTile, error) {
+		var t MapTile
+		return &t, row.Scan(&t.WorldID, &t.Q, &t.R, &t.Biome, &t.Settleable)
+	})
+	if err != nil {
+		return fmt.Errorf("world: %w", err)
+	}
+	rows, err = tx.Query(ctx, getCitySpotsQuery, c.WorldID)
+	if err != nil {
+		return err
+	}
+	cities, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*City, error) {
+		var city City
+		return &city, row.Scan(&city.ID, &city.PlayerID, &city.Q, &city.R)
+	})
+	if err != nil {
+		return fmt.Errorf("cities: %w", err)
+	}
+
+	// take the best spot that is not being taken by a concurrent placement
+	found := false
+	for _, t := range placement.Rank(tiles, cities) {
+		err := tx.QueryRow(ctx, lockCitySpotQuery, c.WorldID, t.Q, t.R).Scan(&c.Biome)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.Q, c.R, found = t.Q, t.R, true
+		break
+	}
+	if !found {
+		return utils.ErrNotFound
+	}
+
+	tag, err := tx.Exec(ctx, settleCityQuery, c.ID, c.PlayerID, c.WorldID, c.Name, c.Q, c.R, c.Biome, c.Points, c.Loyalty)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		// created by a concurrent placement of the same city
+		return tx.QueryRow(ctx, getCitySpotQuery, c.ID).Scan(&c.Q, &c.R, &c.Biome)
+	}
+
+	batch := &pgx.Batch{}
+	queueCreateCityDetails(batch, c)
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+const selectMovementQuery = `SELECT id, city_id::text, player_id::text, mission, q, r,
+	COALESCE(target_city_id::text, ''), units, resources, city_name, start_tick, arrival_tick, returning
+	FROM movements`
+
+const getMovementQuery = selectMovementQuery + `
+	WHERE id = $1`
+
+const getCityMovementsQuery = selectMovementQuery + `
+	WHERE city_id = $1 OR target_city_id = $1
+	ORDER BY arrival_tick, start_tick, id`
+
+// scanMovement scans a row of the selectMovementQuery into a movement.
+func scanMovement(row pgx.Row) (*Movement, error) {
+	m := &Movement{}
+	var units, resources []byte
+	err := row.Scan(
+		&m.ID, &m.CityID, &m.PlayerID, &m.Mission, &m.Q, &m.R,
+		&m.TargetCityID, &units, &resources, &m.CityName, &m.StartTick, &m.ArrivalTick, &m.Returning,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(units, &m.Units); err != nil {
+		return nil, fmt.Errorf("invalid movement units: %w", err)
+	}
+	if err := json.Unmarshal(resources, &m.Resources); err != nil {
+		return nil, fmt.Errorf("invalid movement resources: %w", err)
+	}
+	return m, nil
+}
+
+// scanMovements scans and closes the rows of a movements query.
+func scanMovements(rows pgx.Rows) ([]*Movement, error) {
+	defer rows.Close()
+
+	var movements []*Movement
+	for rows.Next() {
+		m, err := scanMovement(rows)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+func (db *PostgresDatabase) GetMovement(ctx context.Context, id string) (*Movement, error) {
+	m, err := scanMovement(db.DB.QueryRow(ctx, getMovementQuery, id))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, utils.ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &t, nil
+	return m, nil
+}
+
+// GetCityMovements returns the movements from and towards a city, in order of arrival.
+func (db *PostgresDatabase) GetCityMovements(ctx context.Context, cityID string) ([]*Movement, error) {
+	rows, err := db.DB.Query(ctx, getCityMovementsQuery, cityID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMovements(rows)
+}
+
+const getBattleReportQuery = `SELECT report FROM battle_reports WHERE id = $1`
+
+const getBattleReportsQuery = `SELECT report FROM battle_reports
+	WHERE attacker_id = $1 OR defender_id = $1
+	ORDER BY tick DESC
+	LIMIT 100`
+
+func (db *PostgresDatabase) GetBattleReport(ctx context.Context, id string) (*BattleReport, error) {
+	var b []byte
+	err := db.DB.QueryRow(ctx, getBattleReportQuery, id).Scan(&b)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	report := &BattleReport{}
+	if err := json.Unmarshal(b, report); err != nil {
+		return nil, fmt.Errorf("invalid battle report: %w", err)
+	}
+	return report, nil
+}
+
+// GetBattleReports returns the latest battle reports of a player, either as attacker or defender.
+func (db *PostgresDatabase) GetBattleReports(ctx context.Context, playerID string) ([]*BattleReport, error) {
+	rows, err := db.DB.Query(ctx, getBattleReportsQuery, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*BattleReport
+	for rows.Next() {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		report := &BattleReport{}
+		if err := json.Unmarshal(b, report); err != nil {
+			return nil, fmt.Errorf("invalid battle report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+const getNotificationsQuery = `SELECT seq, player_id::text, tick, type, COALESCE(city_id::text, ''), payload
+	FROM notifications
+	WHERE player_id = $1 AND seq > $2
+	ORDER BY seq
+	LIMIT $3`
+
+// GetNotifications returns the notifications of a player after the cursor, in order.
+func (db *PostgresDatabase) GetNotifications(ctx context.Context, playerID string, after int64, limit int) ([]*Notification, error) {
+	rows, err := db.DB.Query(ctx, getNotificationsQuery, playerID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*Notification
+	for rows.Next() {
+		n := &Notification{}
+		var payload []byte
+		if err := rows.Scan(&n.Seq, &n.PlayerID, &n.Tick, &n.Type, &n.CityID, &payload); err != nil {
+			return nil, err
+		}
+		n.Payload = json.RawMessage(payload)
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+const getNotificationCursorQuery = `SELECT COALESCE(MAX(seq), 0) FROM notifications WHERE player_id = $1`
+
+// GetNotificationCursor returns the cursor of the latest notification of a player.
+func (db *PostgresDatabase) GetNotificationCursor(ctx context.Context, playerID string) (int64, error) {
+	var cursor int64
+	err := db.DB.QueryRow(ctx, getNotificationCursorQuery, playerID).Scan(&cursor)
+	return cursor, err
+}
+
+const addEventQuery = `INSERT INTO game_events (key, tick, type, city_id, payload)
+	SELECT $1, GREATEST($2, c.last_tick + 1), $3, NULLIF($4, '')::uuid, $5
+	FROM game_clock c
+	ON CONFLICT (key) DO NOTHING
+	RETURNING tick`
+
+// AddEvent adds an event to the queue, to be processed at its tick.
+//
+// If the tick was already processed the event is scheduled for the next unprocessed tick instead, and the event
+// tick is updated accordingly. The clock is not locked, so an event submitted while its tick is being processed
+// is left for the next tick, which processes all the events that are due. Events with an already existing key
+// are ignored.
+func (db *PostgresDatabase) AddEvent(ctx context.Context, e *Event) error {
+	payload := e.Payload
+	if payload == nil {
+		payload = json.RawMessage("{}")
+	}
+	err := db.DB.QueryRow(ctx, addEventQuery, e.Key, e.Tick, e.Type, e.CityID, payload).Scan(&e.Tick)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// duplicated event
+		return nil
+	}
+	return err
+}
+
+const getClockQuery = `SELECT started_at, last_tick FROM game_clock`
+
+func (db *PostgresDatabase) GetClock(ctx context.Context) (*Clock, error) {
+	var c Clock
+	err := db.DB.QueryRow(ctx, getClockQuery).Scan(&c.StartedAt, &c.LastTick)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, utils.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+const lockClockQuery = `SELECT last_tick FROM game_clock FOR UPDATE`
+
+const getTickEventsQuery = `SELECT key, tick, type, COALESCE(city_id::text, ''), payload
+	FROM game_events
+	WHERE tick <= $1
+	ORDER BY tick, seq`
+
+const getPendingQuery = `SELECT key, tick, type, COALESCE(city_id::text, ''), payload
+	FROM game_events
+	WHERE type = ANY($2) AND tick >= $1 AND city_id = ANY($3::uuid[])
+	ORDER BY tick, seq`
+
+const getTickMovementsQuery = selectMovementQuery + `
+	WHERE arrival_tick <= $1 OR id = ANY($2)`
+
+const deleteEventsQuery = `DELETE FROM game_events WHERE key = ANY($1)`
+
+const getUnitsQuery = `SELECT city_id::text, unit, count FROM city_units WHERE count > 0`
+
+const updateCityUnitsQuery = `INSERT INTO city_units (city_id, unit, count) VALUES ($1, $2, $3)
+	ON CONFLICT (city_id, unit) DO UPDATE SET count = EXCLUDED.count`
+
+const updateCityQuery = `UPDATE city SET player_id = $2, loyalty = $3 WHERE id = $1`
+
+const getColonizeTilesQuery = `SELECT DISTINCT w.world_id, w.q, w.r, w.biome, w.settleable FROM world w
+	JOIN city c ON c.world_id = w.world_id
+	JOIN movements m ON m.city_id = c.id AND m.q = w.q AND m.r = w.r
+	WHERE m.mission = $1 AND m.arrival_tick <= $2`
+
+const updateCityResourcesQuery = `UPDATE city_resources
+	SET food = $2, sticks = $3, stones = $4, gems = $5, population = $6, faith = $7
+	WHERE city_id = $1`
+
+const updateCityBuildingsQuery = `UPDATE city_buildings
+	SET city_hall = $2, embassy = $3, treasury = $4, tavern = $5, farm = $6, lumbermill = $7, quarry = $8,
+	crystal_mine = $9, warehouse = $10, market = $11, harbor = $12, walls = $13, barracks = $14, docks = $15,
+	spy_guild = $16, library = $17, workshop = $18, observatory = $19, temple = $20, shrine = $21, cathedral = $22
+	WHERE city_id = $1`
+
+const scheduleEventQuery = `INSERT INTO game_events (key, tick, type, city_id, payload)
+	VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5)
+	ON CONFLICT (key) DO NOTHING`
+
+const updateMovementQuery = `INSERT INTO movements
+	(id, city_id, player_id, mission, q, r, target_city_id, units, resources, city_name, start_tick, arrival_tick, returning)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::uuid, $8, $9, $10, $11, $12, $13)
+	ON CONFLICT (id) DO UPDATE SET
+	units = EXCLUDED.units, resources = EXCLUDED.resources, start_tick = EXCLUDED.start_tick, arrival_tick = EXCLUDED.arrival_tick,
+	returning = EXCLUDED.returning`
+
+const deleteMovementQuery = `DELETE FROM movements WHERE id = $1`
+
+const addBattleReportQuery = `INSERT INTO battle_reports (id, tick, attacker_id, defender_id, report)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (id) DO NOTHING`
+
+const addNotificationQuery = `INSERT INTO notifications (player_id, tick, type, city_id, payload)
+	VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5)`
+
+const commitTickQuery = `UPDATE game_clock SET last_tick = $1`
+
+const (
+	// ticksChannel is the channel notified on the commit of every tick, with the tick result as the payload
+	ticksChannel = "ticks"
+	// maxTickPayload is the size a notification payload must be shorter than
+	maxTickPayload = 8000
+)
+
+const notifyTickQuery = `SELECT pg_notify($1, $2)`
+
+// ProcessTick loads the events due at the tick and the state they act on (see TickState), hands them over to process,
+// and persists the resulting state, battle reports and notifications along with the tick as the last processed tick,
+// deleting the processed events, all in a single transaction.
+//
+// If the tick does not immediately follow the last processed tick ErrTickOutOfOrder is returned.
+func (db *PostgresDatabase) ProcessTick(ctx context.Context, tick int64, process func(*TickState) error) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		// no-op if the transaction was already committed
+		_ = tx.Rollback(ctx)
+	}()
+
+	var lastTick int64
+	err = tx.QueryRow(ctx, lockClockQuery).Scan(&lastTick)
+	if err != nil {
+		return fmt.Errorf("lock clock: %w", err)
+	}
+	if lastTick+1 != tick {
+		return fmt.Errorf("%w: want tick %d, got %d", ErrTickOutOfOrder, lastTick+1, tick)
+	}
+
+	state := &TickState{Tick: tick, Cities: make(map[string]*City)}
+	rows, err := tx.Query(ctx, getTickEventsQuery, tick)
+	if err != nil {
+		return fmt.Errorf("tick events: %w", err)
+	}
+	state.Events, err = scanEvents(rows)
+	if err != nil {
+		return fmt.Errorf("tick events: %w", err)
+	}
+	processed := make([]string, len(state.Events))
+	for i, e := range state.Events {
+		processed[i] = e.Key
+	}
+	cityIDs, movementIDs := touched(state.Events)
+
+	rows, err = tx.Query(ctx, selectCityQuery)
+	if err != nil {
+		return fmt.Errorf("tick cities: %w", err)
+	}
+	// keep a copy of the original values to only write back what changed
+	type cityValues struct {
+		playerID  string
+		loyalty   int
+		resources Resources
+		buildings Buildings
+		units     map[string]int
+	}
+	original := make(map[string]cityValues)
+	for rows.Next() {
+		city, err := scanCity(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("tick cities: %w", err)
+		}
+		state.Cities[city.ID] = city
+		original[city.ID] = cityValues{
+			playerID:  city.PlayerID,
+			loyalty:   city.Loyalty,
+			resources: *city.Resources,
+			buildings: *city.Buildings,
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("tick cities: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, getUnitsQuery)
+	if err != nil {
+		return fmt.Errorf("tick units: %w", err)
+	}
+	for rows.Next() {
+		var (
+			cityID, unit string
+			count        int
+		)
+		if err := rows.Scan(&cityID, &unit, &count); err != nil {
+			rows.Close()
+			return fmt.Errorf("tick units: %w", err)
+		}
+		city, ok := state.Cities[cityID]
+		if !ok {
+			continue
+		}
+		if city.Units == nil {
+			city.Units = make(map[string]int)
+		}
+		city.Units[unit] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("tick units: %w", err)
+	}
+	for id, city := range state.Cities {
+		orig := original[id]
+		orig.units = maps.Clone(city.Units)
+		original[id] = orig
+	}
+
+	// pending events make up the queues of the cities, including the ones that complete on this tick
+	rows, err = tx.Query(ctx, getPendingQuery, tick, pendingEventTypes, cityIDs)
+	if err != nil {
+		return fmt.Errorf("tick queues: %w", err)
+	}
+	pending, err := scanEvents(rows)
+	if err != nil {
+		return fmt.Errorf("tick queues: %w", err)
+	}
+	for _, e := range pending {
+		city, ok := state.Cities[e.CityID]
+		if !ok {
+			continue
+		}
+		if err := city.addPending(e); err != nil {
+			return fmt.Errorf("tick queues: %w", err)
+		}
+	}
+
+	rows, err = tx.Query(ctx, getTickMovementsQuery, tick, movementIDs)
+	if err != nil {
+		return fmt.Errorf("tick movements: %w", err)
+	}
+	movements, err := scanMovements(rows)
+	if err != nil {
+		return fmt.Errorf("tick movements: %w", err)
+	}
+	state.Movements = make(map[string]*Movement, len(movements))
+	originalMovements := make(map[string]Movement, len(movements))
+	for _, m := range movements {
+		state.Movements[m.ID] = m
+		orig := *m
+		orig.Units = maps.Clone(m.Units)
+		originalMovements[m.ID] = orig
+	}
+
+	rows, err = tx.Query(ctx, getColonizeTilesQuery, MissionColonize, tick)
+	if err != nil {
+		return fmt.Errorf("tick tiles: %w", err)
+	}
+	for rows.Next() {
+		t := &MapTile{}
+		if err := rows.Scan(&t.WorldID, &t.Q, &t.R, &t.Biome, &t.Settleable); err != nil {
+			rows.Close()
+			return fmt.Errorf("tick tiles: %w", err)
+		}
+		state.Tiles = append(state.Tiles, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("tick tiles: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, selectWorldQuery)
+	if err != nil {
+		return fmt.Errorf("tick worlds: %w", err)
+	}
+	worlds, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*World, error) {
+		return scanWorld(row)
+	})
+	if err != nil {
+		return fmt.Errorf("tick worlds: %w", err)
+	}
+	state.Worlds = make(map[string]*World, len(worlds))
+	for _, w := range worlds {
+		state.Worlds[w.ID] = w
+	}
+
+	if err := process(state); err != nil {
+		return fmt.Errorf("process tick: %w", err)
+	}
+
+	batch := &pgx.Batch{}
+	for id, city := range state.Cities {
+		orig, ok := original[id]
+		if !ok {
+			// founded during the tick
+			queueCreateCity(batch, city)
+			for unit, count := range city.Units {
+				batch.Queue(updateCityUnitsQuery, id, unit, count)
+			}
+			continue
+		}
+		if orig.playerID != city.PlayerID || orig.loyalty != city.Loyalty {
+			batch.Queue(updateCityQuery, id, city.PlayerID, city.Loyalty)
+		}
+		if orig.resources != *city.Resources {
+			r := city.Resources
+			batch.Queue(updateCityResourcesQuery, id, r.Food, r.Sticks, r.Stones, r.Gems, r.Population, r.Faith)
+		}
+		if orig.buildings != *city.Buildings {
+			b := city.Buildings
+			batch.Queue(updateCityBuildingsQuery, id,
+				b.CityHall, b.Embassy, b.Treasury, b.Tavern, b.Farm, b.Lumbermill, b.Quarry,
+				b.CrystalMine, b.Warehouse, b.Market, b.Harbor, b.Walls, b.Barracks, b.Docks,
+				b.SpyGuild, b.Library, b.Workshop, b.Observatory, b.Temple, b.Shrine, b.Cathedral,
+			)
+		}
+		if !maps.Equal(orig.units, city.Units) {
+			for unit := range orig.units {
+				if _, ok := city.Units[unit]; !ok {
+					batch.Queue(updateCityUnitsQuery, id, unit, 0)
+				}
+			}
+			for unit, count := range city.Units {
+				batch.Queue(updateCityUnitsQuery, id, unit, count)
+			}
+		}
+	}
+	for id := range originalMovements {
+		if _, ok := state.Movements[id]; !ok {
+			batch.Queue(deleteMovementQuery, id)
+		}
+	}
+	for id, m := range state.Movements {
+		if orig, ok := originalMovements[id]; ok && reflect.DeepEqual(orig, *m) {
+			continue
+		}
+		units, err := json.Marshal(m.Units)
+		if err != nil {
+			return fmt.Errorf("movement %s: %w", id, err)
+		}
+		resources, err := json.Marshal(m.Resources)
+		if err != nil {
+			return fmt.Errorf("movement %s: %w", id, err)
+		}
+		batch.Queue(updateMovementQuery, m.ID, m.CityID, m.PlayerID, m.Mission, m.Q, m.R,
+			m.TargetCityID, units, resources, m.CityName, m.StartTick, m.ArrivalTick, m.Returning)
+	}
+	for _, report := range state.Reports {
+		b, err := json.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("battle report %s: %w", report.ID, err)
+		}
+		batch.Queue(addBattleReportQuery, report.ID, report.Tick, report.AttackerID, report.DefenderID, b)
+	}
+	for _, e := range state.NewEvents {
+		payload := e.Payload
+		if payload == nil {
+			payload = json.RawMessage("{}")
+		}
+		batch.Queue(scheduleEventQuery, e.Key, e.Tick, e.Type, e.CityID, payload)
+	}
+	for _, n := range state.Notifications {
+		payload, err := json.Marshal(n.Payload)
+		if err != nil {
+			return fmt.Errorf("notification %s: %w", n.Type, err)
+		}
+		batch.Queue(addNotificationQuery, n.PlayerID, n.Tick, n.Type, n.CityID, payload)
+	}
+	batch.Queue(deleteEventsQuery, processed)
+	batch.Queue(commitTickQuery, tick)
+	payload, err := json.Marshal(&TickResult{Tick: tick, Notified: notifiedPlayers(state)})
+	if err != nil {
+		return fmt.Errorf("tick result: %w", err)
+	}
+	if len(payload) >= maxTickPayload {
+		// too many players to tell, so all streams look up their notifications
+		payload, _ = json.Marshal(&TickResult{Tick: tick})
+	}
+	batch.Queue(notifyTickQuery, ticksChannel, string(payload))
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("write tick: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// ListenTicks listens to the notifications of the committed ticks, on a connection taken out of the pool for as
+// long as it listens.
+func (db *PostgresDatabase) ListenTicks(ctx context.Context, publish func(*TickResult)) error {
+	pooled, err := db.DB.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	conn := pooled.Hijack()
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+ticksChannel); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+		result := &TickResult{}
+		if err := json.Unmarshal([]byte(n.Payload), result); err != nil {
+			return fmt.Errorf("invalid tick result: %w", err)
+		}
+		publish(result)
+	}
+}
+
+// queueCreateCity queues the creation of a city with its resources and buildings.
+func queueCreateCity(batch *pgx.Batch, c *City) {
+	batch.Queue(createCityQuery, c.ID, c.PlayerID, c.WorldID, c.Name, c.Q, c.R, c.Biome, c.Points, c.Loyalty)
+	queueCreateCityDetails(batch, c)
+}
+
+// queueCreateCityDetails queues the creation of the resources and buildings of a city.
+func queueCreateCityDetails(batch *pgx.Batch, c *City) {
+	r, b := c.Resources, c.Buildings
+	batch.Queue(createCityResourcesQuery, c.ID, r.Food, r.Sticks, r.Stones, r.Gems, r.Population, r.Faith)
+	batch.Queue(createCityBuildingsQuery, c.ID,
+		b.CityHall, b.Embassy, b.Treasury, b.Tavern, b.Farm, b.Lumbermill, b.Quarry,
+		b.CrystalMine, b.Warehouse, b.Market, b.Harbor, b.Walls, b.Barracks, b.Docks,
+		b.SpyGuild, b.Library, b.Workshop, b.Observatory, b.Temple, b.Shrine, b.Cathedral,
+	)
+}
+
+// scanEvents scans and closes the rows of an events query.
+func scanEvents(rows pgx.Rows) ([]*Event, error) {
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		e := &Event{}
+		if err := rows.Scan(&e.Key, &e.Tick, &e.Type, &e.CityID, &e.Payload); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
 }
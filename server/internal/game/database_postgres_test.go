@@ -0,0 +1,31 @@
+//go:build postgres
+
+package game_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luisferreira32/stickian/server/internal/game"
+	"github.com/luisferreira32/stickian/server/internal/game/gametest"
+	"github.com/luisferreira32/stickian/server/internal/pgtest"
+)
+
+func Test_PostgresDatabaseContract(t *testing.T) {
+	gametest.Run(t, func(t *testing.T, tiles []*game.MapTile) game.GameDatabase {
+		ctx := context.Background()
+		pool := pgtest.New(t)
+		for _, player := range gametest.Players {
+			_, err := pool.Exec(ctx, `INSERT INTO users (id, email, validated_email, username, hashed_password)
+				VALUES ($1, $1, true, $1, '')`, player)
+			if err != nil {
+				t.Fatalf("failed to create player: %v", err)
+			}
+		}
+		db := &game.PostgresDatabase{DB: pool}
+		if err := db.CreateWorld(ctx, game.DefaultWorld, tiles); err != nil {
+			t.Fatalf("failed to create world: %v", err)
+		}
+		return db
+	})
+}
@@ -0,0 +1,14 @@
+package game_test
+
+import (
+	"testing"
+
+	"github.com/luisferreira32/stickian/server/internal/game"
+	"github.com/luisferreira32/stickian/server/internal/game/gametest"
+)
+
+func Test_MemoryDatabaseContract(t *testing.T) {
+	gametest.Run(t, func(t *testing.T, tiles []*game.MapTile) game.GameDatabase {
+		return game.NewMemoryDatabase(tiles)
+	})
+}
@@ -0,0 +1,186 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event defines an entry of the world event queue.
+//
+// Events are scheduled for a tick and processed in order of submission once the tick window is closed.
+// The Key identifies the event: submitting an event with an existing key is a no-op, which is why events
+// generated while processing a tick must have deterministic keys (e.g. derived from the key of the event
+// that originated them). That way re-processing a tick re-writes the same events instead of adding new ones.
+// Events are deleted once processed.
+type Event struct {
+	Key     string          `json:"key"`
+	Tick    int64           `json:"tick"`
+	Type    string          `json:"type"`
+	CityID  string          `json:"cityID,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+const (
+	// EventUpgradeOrdered is submitted by a player to upgrade a building of a city
+	EventUpgradeOrdered = "upgrade_ordered"
+	// EventConstructionCompleted is scheduled at the end of a building upgrade in the construction queue
+	EventConstructionCompleted = "construction_completed"
+	// EventTrainingOrdered is submitted by a player to train units in a city
+	EventTrainingOrdered = "training_ordered"
+	// EventUnitsTrained is scheduled at the end of a training order in the training queue
+	EventUnitsTrained = "units_trained"
+	// EventMovementOrdered is submitted by a player to send units from a city to a target tile
+	EventMovementOrdered = "movement_ordered"
+	// EventMovementRecalled is submitted by a player to turn back a movement before its arrival
+	EventMovementRecalled = "movement_recalled"
+)
+
+// Clock defines the persisted state of the world clock.
+//
+// Ticks are counted from StartedAt, such that every server (re-)start agrees on the tick number,
+// and LastTick is the last tick for which events were processed and committed.
+type Clock struct {
+	StartedAt time.Time
+	LastTick  int64
+}
+
+// tickAt returns the tick open for submissions at the given time.
+func (c *Clock) tickAt(t time.Time, tickDuration time.Duration) int64 {
+	return int64(t.Sub(c.StartedAt) / tickDuration)
+}
+
+// processedAt returns the time at which the tick is processed, i.e., when its window closes.
+func (c *Clock) processedAt(tick int64, tickDuration time.Duration) time.Time {
+	return c.StartedAt.Add(time.Duration(tick+1) * tickDuration)
+}
+
+// TickState is the world state handed over to the tick processor.
+//
+// The Events are the ones due at the tick, including the ones submitted for an earlier tick while it was being
+// processed. All Cities are loaded, since all of them produce and feed their population on every tick, along with
+// the queues of the ones the events act on, while the Movements are the ones arriving on the tick or recalled by
+// its events.
+//
+// The processor mutates the Cities and Movements in place, adding and removing them from the maps, schedules new
+// events with Schedule, adds the reports of the battles fought during the tick, and notifies the players with
+// Notify. The database implementation is responsible for persisting all of it, and marking the tick as processed,
+// atomically.
+type TickState struct {
+	Tick      int64
+	Events    []*Event
+	Cities    map[string]*City
+	Movements map[string]*Movement
+	NewEvents []*Event
+	Reports   []*BattleReport
+	// Notifications are the messages to the players generated during the tick
+	Notifications []*Notification
+	// Tiles are the world tiles targeted by colonization movements
+	Tiles []*MapTile
+	// Worlds are all worlds of the server, by ID
+	Worlds map[string]*World
+}
+
+// world returns the world of the given ID, or the classic world if it was not loaded.
+func (s *TickState) world(id string) *World {
+	if w, ok := s.Worlds[id]; ok {
+		return w
+	}
+	return DefaultWorld
+}
+
+// touched returns the cities and the movements the events act on, whose queues and movements must be loaded to
+// process them.
+func touched(events []*Event) (cityIDs, movementIDs []string) {
+	for _, e := range events {
+		if e.CityID != "" && !slices.Contains(cityIDs, e.CityID) {
+			cityIDs = append(cityIDs, e.CityID)
+		}
+		if e.Type == EventMovementRecalled {
+			var p movementRecalledPayload
+			if err := json.Unmarshal(e.Payload, &p); err == nil && p.MovementID != "" {
+				movementIDs = append(movementIDs, p.MovementID)
+			}
+		}
+	}
+	return cityIDs, movementIDs
+}
+
+// Schedule adds a new event to be persisted at the end of the tick.
+func (s *TickState) Schedule(e *Event) {
+	s.NewEvents = append(s.NewEvents, e)
+}
+
+// addMovement adds a new movement to the world.
+func (s *TickState) addMovement(m *Movement) {
+	if s.Movements == nil {
+		s.Movements = make(map[string]*Movement)
+	}
+	s.Movements[m.ID] = m
+}
+
+// cityAt returns the city at the tile of the world, or nil if there is none.
+func (s *TickState) cityAt(worldID string, q, r int) *City {
+	for _, c := range s.Cities {
+		if c.WorldID == worldID && c.Q == q && c.R == r {
+			return c
+		}
+	}
+	return nil
+}
+
+// Notify adds a notification to a player, to be persisted at the end of the tick.
+func (s *TickState) Notify(playerID, notificationType, cityID string, payload any) {
+	s.Notifications = append(s.Notifications, &Notification{
+		PlayerID: playerID,
+		Tick:     s.Tick,
+		Type:     notificationType,
+		CityID:   cityID,
+		Payload:  payload,
+	})
+}
+
+// tileAt returns the tile of the world at the coordinates, if it was loaded, or nil otherwise.
+func (s *TickState) tileAt(worldID string, q, r int) *MapTile {
+	for _, t := range s.Tiles {
+		if t.WorldID == worldID && t.Q == q && t.R == r {
+			return t
+		}
+	}
+	return nil
+}
+
+// newEvent creates an event with a random key, to be submitted by an endpoint.
+//
+// The tick is left at zero, which schedules the event for the next tick that is not yet processed.
+func newEvent(eventType, cityID string, payload any) (*Event, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event payload: %w", err)
+	}
+	return &Event{
+		Key:     uuid.New().String(),
+		Type:    eventType,
+		CityID:  cityID,
+		Payload: b,
+	}, nil
+}
+
+// followUp creates an event originated by e scheduled at the given tick. The key is derived from the key
+// of e and the event type, such that re-processing e yields the exact same event.
+func (e *Event) followUp(eventType string, tick int64, payload any) (*Event, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event payload: %w", err)
+	}
+	return &Event{
+		Key:     e.Key + "/" + eventType,
+		Tick:    tick,
+		Type:    eventType,
+		CityID:  e.CityID,
+		Payload: b,
+	}, nil
+}
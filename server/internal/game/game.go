@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"sync"
+	"time"
 
+	"github.com/luisferreira32/stickian/server/internal/auth"
 	"github.com/luisferreira32/stickian/server/internal/utils"
 )
 
@@ -13,13 +14,12 @@ var (
 	// InitialResources for a new city
 	//
 	// The objective of these initial resources is that it is enough for the creation
-	// of the resource producing buildings to a certain extent
-	//
-	// TODO: change these resources to match building costs once we define a cost table for building upgrades
+	// of the resource producing buildings to a certain extent, i.e., the first level of
+	// the farm, lumbermill, quarry and warehouse (see buildingSpecs)
 	InitialResources = &Resources{
-		Food:       100,
-		Sticks:     100,
-		Stones:     100,
+		Food:       150,
+		Sticks:     200,
+		Stones:     150,
 		Gems:       0,
 		Population: 10,
 		Faith:      0,
@@ -27,12 +27,19 @@ var (
 )
 
 type GameService struct {
-	Database GameDatabase
+	Database     GameDatabase
+	TickDuration time.Duration
+	// Broker delivers the results of the processed ticks to the player streams
+	Broker *Broker
+	// RequireVerifiedEmail only lets players with a verified email join the world
+	RequireVerifiedEmail bool
 
-	settleLock sync.Mutex
+	visions visionCache
 }
 
 type JoinWorldRequest struct {
+	// WorldID is the world to join, the classic world if not set
+	WorldID  string `json:"worldID"`
 	CityName string `json:"cityName"`
 }
 
@@ -50,14 +57,14 @@ func validJoinWorldRequest(req *JoinWorldRequest) string {
 	return ""
 }
 
-// JoinWorld is the first endpoint called once a logged in player wants to start a game.
+// JoinWorld is the first endpoint called once a logged in player wants to start a game in a world.
 //
 // The endpoint returns the city ID of the newly generated city, or, if the endpoint was called
 // multiple times (e.g., network issues), it returns the city ID of the first city created for
-// this player.
+// this player in the world.
 //
-// The first city ID, which is a UUID, will be the player ID such that multiple calls to this endpoint
-// do NOT create multiple cities in the world, and instead always return the first created city.
+// The first city ID is derived from the player ID and the world (see capitalID) such that multiple calls to
+// this endpoint do NOT create multiple cities in the world, and instead always return the first created city.
 func (g *GameService) JoinWorld(w http.ResponseWriter, r *http.Request) {
 	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
 	defer func() {
@@ -74,48 +81,55 @@ func (g *GameService) JoinWorld(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, ok := r.Context().Value("sub").(string)
-	if !ok || userID == "" {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
 		utils.WithError(w, utils.ErrUnauthorized)
 		return
 	}
+	if g.RequireVerifiedEmail && !principal.EmailVerified {
+		utils.WithError(w, fmt.Errorf("%w: email not verified", utils.ErrForbidden))
+		return
+	}
+
+	if req.WorldID == "" {
+		req.WorldID = DefaultWorldID
+	}
+	world, err := g.Database.GetWorld(r.Context(), req.WorldID)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("failed to get world: %w", err))
+		return
+	}
+
+	if !world.joinable(time.Now()) {
+		utils.WithError(w, fmt.Errorf("%w: world is not open", utils.ErrForbidden))
+		return
+	}
 
-	// NOTE: The first city ID, which is a UUID, will be the player ID such that multiple calls to this endpoint
+	// NOTE: The first city ID is derived from the player ID such that multiple calls to this endpoint
 	// do NOT create multiple cities in the world, and instead always return the first created city.
 	newCity := &City{
-		ID:        userID,
-		PlayerID:  userID,
+		ID:        capitalID(world.ID, principal.UserID),
+		PlayerID:  principal.UserID,
+		WorldID:   world.ID,
 		Name:      req.CityName,
 		Points:    0,
+		Loyalty:   maxLoyalty,
 		Buildings: &Buildings{},
 		Resources: InitialResources,
 	}
 
-	// HACK: to avoid collisions we can have a shared lock on our monolith server!
-	var (
-		citySpot *MapTile
-		err      error
-	)
-	func() {
-		g.settleLock.Lock()
-		defer g.settleLock.Unlock()
-		citySpot, err = g.Database.GetNextCitySpot(r.Context())
-	}()
+	// the spot is chosen and the city created atomically by the database, so concurrent calls never collide
+	placement, err := world.placement()
 	if err != nil {
-		utils.WithError(w, fmt.Errorf("failed to get next city spot: %w", err))
+		utils.WithError(w, fmt.Errorf("failed to get world placement: %w", err))
 		return
 	}
-	newCity.Q = citySpot.Q
-	newCity.R = citySpot.R
-	newCity.Biome = citySpot.Biome
-
-	err = g.Database.CreateCity(r.Context(), newCity)
-	if err != nil {
-		utils.WithError(w, err)
+	if err := g.Database.SettleCity(r.Context(), newCity, placement); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to settle city: %w", err))
 		return
 	}
 
-	rsp := JoinWorldResponse{CityID: userID}
+	rsp := JoinWorldResponse{CityID: newCity.ID}
 	utils.WithDefaultOKHeaders(w)
 	if err := json.NewEncoder(w).Encode(rsp); err != nil {
 		utils.WithError(w, fmt.Errorf("failed to encode response: %w", err))
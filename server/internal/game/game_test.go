@@ -1 +1,166 @@
 package game
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+func Test_JoinWorld(t *testing.T) {
+	blitz := &World{ID: "blitz", Name: "blitz", Size: 64, Speed: 10, Status: WorldOpen, Placement: "diagonal"}
+	worlds := map[string]*World{
+		DefaultWorldID: DefaultWorld,
+		blitz.ID:       blitz,
+		"misplaced":    {ID: "misplaced", Name: "misplaced", Size: 64, Speed: 1, Status: WorldOpen, Placement: "random"},
+		"ended":        {ID: "ended", Name: "ended", Size: 64, Speed: 1, Status: WorldEnded},
+		"upcoming":     {ID: "upcoming", Name: "upcoming", Size: 64, Speed: 1, Status: WorldOpen, StartsAt: time.Now().Add(time.Hour)},
+	}
+	blitzCapital := capitalID(blitz.ID, "test-user")
+
+	testcases := []struct {
+		name          string
+		body          string
+		requireEmail  bool
+		emailVerified bool
+		settleErr     error
+		wantCity      *City
+		wantPlacement PlacementStrategy
+		wantStatus    int
+		wantBody      []byte
+	}{
+		{
+			name: "success",
+			body: `{"cityName":"Capital"}`,
+			wantCity: &City{
+				ID: "test-user", PlayerID: "test-user", WorldID: DefaultWorldID, Name: "Capital", Loyalty: maxLoyalty,
+				Buildings: &Buildings{}, Resources: InitialResources,
+			},
+			wantPlacement: DefaultPlacement,
+			wantStatus:    200,
+			wantBody:      unsafeToResponseBody(JoinWorldResponse{CityID: "test-user"}),
+		},
+		{
+			name: "another world",
+			body: `{"worldID":"blitz","cityName":"Capital"}`,
+			wantCity: &City{
+				ID: blitzCapital, PlayerID: "test-user", WorldID: blitz.ID, Name: "Capital", Loyalty: maxLoyalty,
+				Buildings: &Buildings{}, Resources: InitialResources,
+			},
+			wantPlacement: DiagonalPlacement{},
+			wantStatus:    200,
+			wantBody:      unsafeToResponseBody(JoinWorldResponse{CityID: blitzCapital}),
+		},
+		{
+			name:       "unknown placement of the world",
+			body:       `{"worldID":"misplaced","cityName":"Capital"}`,
+			wantStatus: 500,
+			wantBody:   []byte("failed to get world placement: unknown placement strategy: random\n"),
+		},
+		{
+			name:       "unknown world",
+			body:       `{"worldID":"unknown","cityName":"Capital"}`,
+			wantStatus: 404,
+			wantBody:   []byte("failed to get world: not found\n"),
+		},
+		{
+			name:       "world ended",
+			body:       `{"worldID":"ended","cityName":"Capital"}`,
+			wantStatus: 403,
+			wantBody:   []byte("forbidden: world is not open\n"),
+		},
+		{
+			name:       "world not started",
+			body:       `{"worldID":"upcoming","cityName":"Capital"}`,
+			wantStatus: 403,
+			wantBody:   []byte("forbidden: world is not open\n"),
+		},
+		{
+			name:       "missing city name",
+			body:       `{}`,
+			wantStatus: 400,
+			wantBody:   []byte("user error: city name is required\n"),
+		},
+		{
+			name:      "world is full",
+			body:      `{"cityName":"Capital"}`,
+			settleErr: utils.ErrNotFound,
+			wantCity: &City{
+				ID: "test-user", PlayerID: "test-user", WorldID: DefaultWorldID, Name: "Capital", Loyalty: maxLoyalty,
+				Buildings: &Buildings{}, Resources: InitialResources,
+			},
+			wantPlacement: DefaultPlacement,
+			wantStatus:    404,
+			wantBody:      []byte("failed to settle city: not found\n"),
+		},
+		{
+			name:         "email not verified",
+			body:         `{"cityName":"Capital"}`,
+			requireEmail: true,
+			wantStatus:   403,
+			wantBody:     []byte("forbidden: email not verified\n"),
+		},
+		{
+			name:          "email verified",
+			body:          `{"cityName":"Capital"}`,
+			requireEmail:  true,
+			emailVerified: true,
+			wantCity: &City{
+				ID: "test-user", PlayerID: "test-user", WorldID: DefaultWorldID, Name: "Capital", Loyalty: maxLoyalty,
+				Buildings: &Buildings{}, Resources: InitialResources,
+			},
+			wantPlacement: DefaultPlacement,
+			wantStatus:    200,
+			wantBody:      unsafeToResponseBody(JoinWorldResponse{CityID: "test-user"}),
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			var (
+				gotCity      *City
+				gotPlacement PlacementStrategy
+			)
+			// given
+			mockDB := &mockDatabase{
+				GetWorldFunc: func(id string) (*World, error) {
+					if world, ok := worlds[id]; ok {
+						return world, nil
+					}
+					return nil, utils.ErrNotFound
+				},
+				SettleCityFunc: func(c *City, placement PlacementStrategy) error {
+					gotCity = c
+					gotPlacement = placement
+					return testcase.settleErr
+				},
+			}
+			service := &GameService{Database: mockDB, RequireVerifiedEmail: testcase.requireEmail}
+
+			// when
+			req := httptest.NewRequest("POST", "/api/joinworld", strings.NewReader(testcase.body))
+			principal := &auth.Principal{UserID: "test-user", EmailVerified: testcase.emailVerified}
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+			service.JoinWorld(rec, req)
+
+			// then
+			if diff := cmp.Diff(testcase.wantCity, gotCity); diff != "" {
+				t.Errorf("unexpected city diff (-want, +got): %v", diff)
+			}
+			if gotPlacement != testcase.wantPlacement {
+				t.Errorf("unexpected placement: %v", gotPlacement)
+			}
+			if testcase.wantStatus != rec.Code {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+			if diff := cmp.Diff(testcase.wantBody, rec.Body.Bytes()); diff != "" {
+				t.Errorf("unexpected body diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
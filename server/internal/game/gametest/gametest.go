@@ -0,0 +1,810 @@
+// Package gametest is a conformance test suite for the implementations of the game.GameDatabase, such that
+// every storage backend behaves exactly like the others.
+package gametest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/luisferreira32/stickian/server/internal/game"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+// Players are the IDs of the players owning the cities of the suite, which the databases under test must
+// accept, e.g., by creating them as users.
+var Players = []string{
+	"00000000-0000-0000-0000-00000000000a",
+	"00000000-0000-0000-0000-00000000000b",
+}
+
+// NewDatabase returns an empty database under test, with its clock at tick -1 and the classic world with the
+// given tiles.
+type NewDatabase func(t *testing.T, tiles []*game.MapTile) game.GameDatabase
+
+const (
+	cityA = "00000000-0000-0000-0000-0000000000a1"
+	cityB = "00000000-0000-0000-0000-0000000000a2"
+	cityC = "00000000-0000-0000-0000-0000000000b1"
+
+	blitzID = "00000000-0000-0000-0000-0000000000f1"
+)
+
+// world is a 3x3 map, where only the tiles with q > 0 are settleable.
+func world() []*game.MapTile {
+	var tiles []*game.MapTile
+	for q := range 3 {
+		for r := range 3 {
+			tiles = append(tiles, &game.MapTile{Q: q, R: r, Biome: game.BiomePlains, Settleable: q > 0})
+		}
+	}
+	return tiles
+}
+
+func city(id, playerID, name string, q, r int) *game.City {
+	return &game.City{
+		ID: id, PlayerID: playerID, WorldID: game.DefaultWorldID, Name: name, Q: q, R: r, Biome: game.BiomePlains,
+		Loyalty:   100,
+		Buildings: &game.Buildings{Farm: 1},
+		Resources: &game.Resources{Food: 150, Sticks: 200},
+	}
+}
+
+// mustCreateCities creates the cities, failing the test otherwise.
+func mustCreateCities(t *testing.T, db game.GameDatabase, cities ...*game.City) {
+	t.Helper()
+	for _, c := range cities {
+		if err := db.CreateCity(t.Context(), c); err != nil {
+			t.Fatalf("failed to create city %s: %v", c.ID, err)
+		}
+	}
+}
+
+// mustProcessTick processes the next tick, failing the test otherwise.
+func mustProcessTick(t *testing.T, db game.GameDatabase, process func(*game.TickState) error) {
+	t.Helper()
+	clock, err := db.GetClock(t.Context())
+	if err != nil {
+		t.Fatalf("failed to get clock: %v", err)
+	}
+	if err := db.ProcessTick(t.Context(), clock.LastTick+1, process); err != nil {
+		t.Fatalf("failed to process tick %d: %v", clock.LastTick+1, err)
+	}
+}
+
+// mustGetSightings returns the sightings of the player in the classic world, failing the test otherwise.
+func mustGetSightings(t *testing.T, db game.GameDatabase, playerID string, q1, r1, q2, r2 int) []*game.City {
+	t.Helper()
+	sightings, err := db.GetSightings(t.Context(), game.DefaultWorldID, playerID, q1, r1, q2, r2)
+	if err != nil {
+		t.Fatalf("failed to get sightings: %v", err)
+	}
+	return sightings
+}
+
+// normalizeJSON re-encodes a JSON document, such that documents are equal regardless of their formatting.
+func normalizeJSON(t *testing.T, b []byte) string {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("invalid json %s: %v", b, err)
+	}
+	normalized, _ := json.Marshal(v)
+	return string(normalized)
+}
+
+// Run runs the conformance test suite against fresh databases returned by newDB.
+func Run(t *testing.T, newDB NewDatabase) {
+	ctx := context.Background()
+
+	t.Run("CreateWorld creates a world once", func(t *testing.T) {
+		db := newDB(t, world())
+		if _, err := db.GetWorld(ctx, blitzID); !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", utils.ErrNotFound, err)
+		}
+		endsAt := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+		blitz := &game.World{
+			ID: blitzID, Name: "blitz", Size: 3, Speed: 10, Status: game.WorldOpen,
+			StartsAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), EndsAt: &endsAt, Placement: "diagonal",
+		}
+		if err := db.CreateWorld(ctx, blitz, world()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := db.CreateWorld(ctx, blitz, world()); !errors.Is(err, game.ErrWorldExists) {
+			t.Errorf("unexpected error: want %v, got %v", game.ErrWorldExists, err)
+		}
+
+		got, err := db.GetWorld(ctx, blitzID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(blitz, got, cmpopts.EquateApproxTime(0)); diff != "" {
+			t.Errorf("unexpected world diff (-want, +got): %v", diff)
+		}
+		worlds, err := db.GetWorlds(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(worlds) != 2 || worlds[0].ID != game.DefaultWorldID || worlds[1].ID != blitzID {
+			t.Errorf("unexpected worlds: %+v", worlds)
+		}
+	})
+
+	t.Run("worlds keep their maps and cities apart", func(t *testing.T) {
+		db := newDB(t, world())
+		blitz := &game.World{ID: blitzID, Name: "blitz", Size: 1, Speed: 10, Status: game.WorldOpen}
+		if err := db.CreateWorld(ctx, blitz, []*game.MapTile{{Q: 1, R: 1, Biome: game.BiomeBeach, Settleable: true}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1))
+
+		colony := city(cityB, Players[0], "Blitz", 0, 0)
+		colony.WorldID = blitzID
+		if err := db.SettleCity(ctx, colony, game.DiagonalPlacement{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if colony.Q != 1 || colony.R != 1 || colony.Biome != game.BiomeBeach {
+			t.Errorf("unexpected spot: (%v, %v) %v", colony.Q, colony.R, colony.Biome)
+		}
+
+		cities, err := db.GetCities(ctx, blitzID, 0, 0, 2, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wantCities := []*game.City{
+			{ID: cityB, PlayerID: Players[0], WorldID: blitzID, Name: "Blitz", Q: 1, R: 1, Biome: game.BiomeBeach},
+		}
+		if diff := cmp.Diff(wantCities, cities); diff != "" {
+			t.Errorf("unexpected cities diff (-want, +got): %v", diff)
+		}
+		tiles, err := db.GetMap(ctx, blitzID, 0, 2, 0, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wantTiles := []*game.MapTile{{WorldID: blitzID, Q: 1, R: 1, Biome: game.BiomeBeach, Settleable: true}}
+		if diff := cmp.Diff(wantTiles, tiles); diff != "" {
+			t.Errorf("unexpected tiles diff (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("GetCity returns not found", func(t *testing.T) {
+		db := newDB(t, world())
+		if _, err := db.GetCity(ctx, cityA); !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", utils.ErrNotFound, err)
+		}
+	})
+
+	t.Run("CreateCity round trips through GetCity", func(t *testing.T) {
+		db := newDB(t, world())
+		want := city(cityA, Players[0], "Capital", 1, 1)
+		mustCreateCities(t, db, want)
+
+		got, err := db.GetCity(ctx, cityA)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected city diff (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("CreateCity leaves an existing city as is", func(t *testing.T) {
+		db := newDB(t, world())
+		want := city(cityA, Players[0], "Capital", 1, 1)
+		mustCreateCities(t, db, want)
+		mustCreateCities(t, db, city(cityA, Players[0], "Renamed", 2, 2))
+
+		got, err := db.GetCity(ctx, cityA)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected city diff (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("CreateCity fails on a taken tile", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1))
+		if err := db.CreateCity(ctx, city(cityB, Players[0], "Colony", 1, 1)); err == nil {
+			t.Errorf("expected an error")
+		}
+		if _, err := db.GetCity(ctx, cityB); !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", utils.ErrNotFound, err)
+		}
+	})
+
+	t.Run("GetCities normalises swapped corners", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db,
+			city(cityA, Players[0], "Capital", 1, 1),
+			city(cityB, Players[0], "Colony", 2, 2),
+			city(cityC, Players[1], "Another", 0, 2),
+		)
+
+		for _, corners := range [][4]int{{1, 1, 2, 2}, {2, 2, 1, 1}, {1, 2, 2, 1}} {
+			got, err := db.GetCities(ctx, game.DefaultWorldID, corners[0], corners[1], corners[2], corners[3])
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			slices.SortFunc(got, func(a, b *game.City) int { return strings.Compare(a.ID, b.ID) })
+			want := []*game.City{
+				{ID: cityA, PlayerID: Players[0], WorldID: game.DefaultWorldID, Name: "Capital", Q: 1, R: 1, Biome: game.BiomePlains},
+				{ID: cityB, PlayerID: Players[0], WorldID: game.DefaultWorldID, Name: "Colony", Q: 2, R: 2, Biome: game.BiomePlains},
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected cities %v diff (-want, +got): %v", corners, diff)
+			}
+		}
+	})
+
+	t.Run("GetPlayerCities is ordered by name", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db,
+			city(cityA, Players[0], "Zion", 1, 1),
+			city(cityB, Players[0], "Atlantis", 2, 2),
+			city(cityC, Players[1], "Another", 0, 2),
+		)
+
+		got, err := db.GetPlayerCities(ctx, Players[0])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []*game.City{
+			{ID: cityB, PlayerID: Players[0], WorldID: game.DefaultWorldID, Name: "Atlantis", Q: 2, R: 2, Biome: game.BiomePlains},
+			{ID: cityA, PlayerID: Players[0], WorldID: game.DefaultWorldID, Name: "Zion", Q: 1, R: 1, Biome: game.BiomePlains},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected cities diff (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("GetMap returns the tiles within the bounds", func(t *testing.T) {
+		db := newDB(t, world())
+		got, err := db.GetMap(ctx, game.DefaultWorldID, 1, 2, 0, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		slices.SortFunc(got, func(a, b *game.MapTile) int { return a.Q - b.Q })
+		want := []*game.MapTile{
+			{WorldID: game.DefaultWorldID, Q: 1, R: 0, Biome: game.BiomePlains, Settleable: true},
+			{WorldID: game.DefaultWorldID, Q: 2, R: 0, Biome: game.BiomePlains, Settleable: true},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected tiles diff (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("Explore adds up the explored tiles", func(t *testing.T) {
+		db := newDB(t, world())
+		if got, err := db.GetExplored(ctx, game.DefaultWorldID, Players[0]); err != nil || got != nil {
+			t.Fatalf("unexpected explored tiles: %v, %v", got, err)
+		}
+		for _, tiles := range [][]byte{{0b10000000, 0}, {0b00000001, 0b10000000}} {
+			if err := db.Explore(ctx, game.DefaultWorldID, Players[0], tiles, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		got, err := db.GetExplored(ctx, game.DefaultWorldID, Players[0])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff([]byte{0b10000001, 0b10000000}, got); diff != "" {
+			t.Errorf("unexpected explored tiles diff (-want, +got): %v", diff)
+		}
+		if got, err := db.GetExplored(ctx, game.DefaultWorldID, Players[1]); err != nil || got != nil {
+			t.Errorf("unexpected explored tiles of another player: %v, %v", got, err)
+		}
+	})
+
+	t.Run("GetSightings returns the last sightings within the bounds", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1), city(cityC, Players[1], "Another", 0, 2))
+		tiles := []byte{0, 0}
+		for i, name := range []string{"Another", "Renamed"} {
+			seen := time.Date(2030, 1, 1+i, 0, 0, 0, 0, time.UTC)
+			sighting := &game.City{
+				ID: cityC, PlayerID: Players[1], WorldID: game.DefaultWorldID, Name: name, Q: 0, R: 2,
+				Biome: game.BiomePlains, Points: 10 * i, LastSeen: &seen,
+			}
+			if err := db.Explore(ctx, game.DefaultWorldID, Players[0], tiles, []*game.City{sighting}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		got, err := db.GetSightings(ctx, game.DefaultWorldID, Players[0], 2, 2, 0, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		seen := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+		want := []*game.City{{
+			ID: cityC, PlayerID: Players[1], WorldID: game.DefaultWorldID, Name: "Renamed", Q: 0, R: 2,
+			Biome: game.BiomePlains, Points: 10, LastSeen: &seen,
+		}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected sightings diff (-want, +got): %v", diff)
+		}
+
+		for _, sightings := range [][]*game.City{
+			mustGetSightings(t, db, Players[0], 1, 1, 2, 2),
+			mustGetSightings(t, db, Players[1], 0, 0, 2, 2),
+		} {
+			if len(sightings) != 0 {
+				t.Errorf("unexpected sightings: %+v", sightings)
+			}
+		}
+	})
+
+	t.Run("SettleCity places cities on free tiles once", func(t *testing.T) {
+		db := newDB(t, []*game.MapTile{
+			{Q: 0, R: 0, Biome: game.BiomeOcean},
+			{Q: 0, R: 1, Biome: game.BiomeBeach, Settleable: true},
+			{Q: 1, R: 0, Biome: game.BiomePlains, Settleable: true},
+		})
+		mustCreateCities(t, db, city(cityC, Players[1], "Another", 0, 1))
+
+		first := city(cityA, Players[0], "Capital", 0, 0)
+		if err := db.SettleCity(ctx, first, game.DiagonalPlacement{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		again := city(cityA, Players[0], "Capital", 0, 0)
+		if err := db.SettleCity(ctx, again, game.DiagonalPlacement{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, c := range []*game.City{first, again} {
+			if c.Q != 1 || c.R != 0 || c.Biome != game.BiomePlains {
+				t.Errorf("unexpected spot: (%v, %v) %v", c.Q, c.R, c.Biome)
+			}
+		}
+		if _, err := db.GetCity(ctx, cityA); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		full := city(cityB, Players[0], "Colony", 0, 0)
+		if err := db.SettleCity(ctx, full, game.DiagonalPlacement{}); !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", utils.ErrNotFound, err)
+		}
+	})
+
+	t.Run("AddEvent schedules events on the next tick once", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1))
+		clock, err := db.GetClock(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if clock.LastTick != -1 {
+			t.Errorf("unexpected last tick: %v", clock.LastTick)
+		}
+		mustProcessTick(t, db, func(*game.TickState) error { return nil })
+
+		event := &game.Event{Key: "event", Type: game.EventUpgradeOrdered, CityID: cityA, Payload: json.RawMessage(`{"building":"farm"}`)}
+		if err := db.AddEvent(ctx, event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if event.Tick != 1 {
+			t.Errorf("unexpected event tick: want 1, got %v", event.Tick)
+		}
+		duplicate := &game.Event{Key: "event", Tick: 5, Type: game.EventUpgradeOrdered, CityID: cityA}
+		if err := db.AddEvent(ctx, duplicate); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got []*game.Event
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			got = state.Events
+			return nil
+		})
+		if len(got) != 1 || got[0].Key != "event" || got[0].Tick != 1 || got[0].CityID != cityA {
+			t.Errorf("unexpected events: %+v", got)
+		}
+		if len(got) == 1 && normalizeJSON(t, got[0].Payload) != `{"building":"farm"}` {
+			t.Errorf("unexpected payload: %s", got[0].Payload)
+		}
+	})
+
+	t.Run("ProcessTick rejects ticks out of order", func(t *testing.T) {
+		db := newDB(t, world())
+		for _, tick := range []int64{-1, 1} {
+			err := db.ProcessTick(ctx, tick, func(*game.TickState) error { return nil })
+			if !errors.Is(err, game.ErrTickOutOfOrder) {
+				t.Errorf("unexpected error for tick %d: want %v, got %v", tick, game.ErrTickOutOfOrder, err)
+			}
+		}
+	})
+
+	t.Run("ProcessTick discards a failed tick", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1))
+
+		err := db.ProcessTick(ctx, 0, func(state *game.TickState) error {
+			state.Cities[cityA].Resources.Food = 0
+			state.Schedule(&game.Event{Key: "scheduled", Tick: 3, Type: game.EventUpgradeOrdered, CityID: cityA})
+			return errors.New("failed")
+		})
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+
+		got, err := db.GetCity(ctx, cityA)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Resources.Food != 150 {
+			t.Errorf("unexpected food: want 150, got %v", got.Resources.Food)
+		}
+		clock, err := db.GetClock(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if clock.LastTick != -1 {
+			t.Errorf("unexpected last tick: %v", clock.LastTick)
+		}
+	})
+
+	t.Run("ProcessTick persists the state of the tick", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1), city(cityC, Players[1], "Another", 2, 2))
+		movement := &game.Movement{
+			ID: "movement", CityID: cityA, PlayerID: Players[0], Mission: game.MissionAttack, Q: 2, R: 2, TargetCityID: cityC,
+			Units: map[string]int{"spearman": 5}, StartTick: 0, ArrivalTick: 4,
+		}
+		report := &game.BattleReport{ID: "report", Tick: 0, AttackerID: Players[0], DefenderID: Players[1], AttackerWon: true}
+		colony := city(cityB, Players[0], "Colony", 2, 1)
+		colony.Units = map[string]int{"spearman": 1}
+
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			capital := state.Cities[cityA]
+			capital.Resources.Food = 100
+			capital.Buildings.Farm = 2
+			capital.Units = map[string]int{"archer": 3}
+			state.Cities[cityC].PlayerID = Players[0]
+			state.Cities[cityC].Loyalty = 25
+			state.Cities[cityB] = colony
+			state.Movements[movement.ID] = movement
+			state.Reports = append(state.Reports, report)
+			state.Schedule(&game.Event{
+				Key: "construction", Tick: 5, Type: game.EventConstructionCompleted, CityID: cityA,
+				Payload: json.RawMessage(`{"building":"farm","level":3,"startTick":0}`),
+			})
+			state.Notify(Players[0], game.NotificationBattleReport, cityA, map[string]any{"reportID": "report"})
+			return nil
+		})
+
+		capital, err := db.GetCity(ctx, cityA)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wantCapital := city(cityA, Players[0], "Capital", 1, 1)
+		wantCapital.Resources.Food = 100
+		wantCapital.Buildings.Farm = 2
+		wantCapital.Units = map[string]int{"archer": 3}
+		wantCapital.Constructions = []*game.Construction{{Building: "farm", Level: 3, StartTick: 0, EndTick: 5}}
+		if diff := cmp.Diff(wantCapital, capital); diff != "" {
+			t.Errorf("unexpected capital diff (-want, +got): %v", diff)
+		}
+
+		conquered, err := db.GetCity(ctx, cityC)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if conquered.PlayerID != Players[0] || conquered.Loyalty != 25 {
+			t.Errorf("unexpected conquered city: %v, %v", conquered.PlayerID, conquered.Loyalty)
+		}
+
+		gotColony, err := db.GetCity(ctx, cityB)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(colony, gotColony); diff != "" {
+			t.Errorf("unexpected colony diff (-want, +got): %v", diff)
+		}
+
+		gotMovement, err := db.GetMovement(ctx, movement.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(movement, gotMovement); diff != "" {
+			t.Errorf("unexpected movement diff (-want, +got): %v", diff)
+		}
+
+		gotReport, err := db.GetBattleReport(ctx, report.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(report, gotReport); diff != "" {
+			t.Errorf("unexpected report diff (-want, +got): %v", diff)
+		}
+
+		notifications, err := db.GetNotifications(ctx, Players[0], 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(notifications) != 1 {
+			t.Fatalf("unexpected notifications: %+v", notifications)
+		}
+		n := notifications[0]
+		if n.Tick != 0 || n.Type != game.NotificationBattleReport || n.CityID != cityA {
+			t.Errorf("unexpected notification: %+v", n)
+		}
+		if payload, ok := n.Payload.(json.RawMessage); !ok || normalizeJSON(t, payload) != `{"reportID":"report"}` {
+			t.Errorf("unexpected notification payload: %v", n.Payload)
+		}
+	})
+
+	t.Run("ListenTicks tells about the committed ticks", func(t *testing.T) {
+		db := newDB(t, world())
+		listener, ok := db.(game.TickListener)
+		if !ok {
+			t.Skip("the database is not shared by several instances")
+		}
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1))
+		listenCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		results := make(chan *game.TickResult, 1)
+		go func() {
+			_ = listener.ListenTicks(listenCtx, func(result *game.TickResult) {
+				select {
+				case results <- result:
+				default:
+				}
+			})
+		}()
+
+		// the listener may only be listening after a few ticks
+		for range 50 {
+			mustProcessTick(t, db, func(state *game.TickState) error {
+				state.Notify(Players[0], game.NotificationBattleReport, cityA, nil)
+				return nil
+			})
+			select {
+			case result := <-results:
+				if !result.Notified[Players[0]] || result.Notified[Players[1]] || result.Resources != nil {
+					t.Errorf("unexpected tick result: %+v", result)
+				}
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		t.Errorf("no tick result")
+	})
+
+	t.Run("ProcessTick loads the state of the tick", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1), city(cityC, Players[1], "Another", 2, 2))
+		movement := &game.Movement{
+			ID: "colonize", CityID: cityA, PlayerID: Players[0], Mission: game.MissionColonize, Q: 2, R: 0,
+			Units: map[string]int{"settler": 1}, CityName: "Colony", StartTick: 0, ArrivalTick: 1,
+		}
+		recalled := &game.Movement{
+			ID: "recalled", CityID: cityA, PlayerID: Players[0], Mission: game.MissionAttack, Q: 2, R: 2,
+			TargetCityID: cityC, Units: map[string]int{"spearman": 1}, StartTick: 0, ArrivalTick: 4,
+		}
+		// neither arriving nor recalled on the tick
+		underway := &game.Movement{
+			ID: "underway", CityID: cityC, PlayerID: Players[1], Mission: game.MissionAttack, Q: 1, R: 1,
+			TargetCityID: cityA, Units: map[string]int{"spearman": 1}, StartTick: 0, ArrivalTick: 4,
+		}
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			state.Cities[cityA].Units = map[string]int{"archer": 3}
+			for _, m := range []*game.Movement{movement, recalled, underway} {
+				state.Movements[m.ID] = m
+			}
+			state.Schedule(&game.Event{
+				Key: "construction", Tick: 1, Type: game.EventConstructionCompleted, CityID: cityA,
+				Payload: json.RawMessage(`{"building":"farm","level":2,"startTick":0}`),
+			})
+			// the queue of a city no event of the tick acts on
+			state.Schedule(&game.Event{
+				Key: "training", Tick: 3, Type: game.EventUnitsTrained, CityID: cityC,
+				Payload: json.RawMessage(`{"unit":"spearman","count":1,"startTick":0}`),
+			})
+			// due at a tick that was already processed, e.g., submitted while it was being processed
+			state.Schedule(&game.Event{
+				Key: "recall", Tick: 0, Type: game.EventMovementRecalled, CityID: cityA,
+				Payload: json.RawMessage(`{"movementID":"recalled"}`),
+			})
+			return nil
+		})
+
+		var got *game.TickState
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			got = state
+			return nil
+		})
+		if got.Tick != 1 || len(got.Events) != 2 || got.Events[0].Key != "recall" || got.Events[1].Key != "construction" {
+			t.Fatalf("unexpected tick %v with events %+v", got.Tick, got.Events)
+		}
+		wantCapital := city(cityA, Players[0], "Capital", 1, 1)
+		wantCapital.Units = map[string]int{"archer": 3}
+		wantCapital.Constructions = []*game.Construction{{Building: "farm", Level: 2, StartTick: 0, EndTick: 1}}
+		wantCities := map[string]*game.City{cityA: wantCapital, cityC: city(cityC, Players[1], "Another", 2, 2)}
+		if diff := cmp.Diff(wantCities, got.Cities); diff != "" {
+			t.Errorf("unexpected cities diff (-want, +got): %v", diff)
+		}
+		wantMovements := map[string]*game.Movement{movement.ID: movement, recalled.ID: recalled}
+		if diff := cmp.Diff(wantMovements, got.Movements); diff != "" {
+			t.Errorf("unexpected movements diff (-want, +got): %v", diff)
+		}
+		wantTiles := []*game.MapTile{{WorldID: game.DefaultWorldID, Q: 2, R: 0, Biome: game.BiomePlains, Settleable: true}}
+		if diff := cmp.Diff(wantTiles, got.Tiles); diff != "" {
+			t.Errorf("unexpected tiles diff (-want, +got): %v", diff)
+		}
+		if w, ok := got.Worlds[game.DefaultWorldID]; !ok || w.Speed != 1 {
+			t.Errorf("unexpected worlds: %+v", got.Worlds)
+		}
+
+		// the processed events are gone, while the movements that were not loaded are kept
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			got = state
+			return nil
+		})
+		if len(got.Events) != 0 {
+			t.Errorf("unexpected events: %+v", got.Events)
+		}
+		if _, err := db.GetMovement(ctx, underway.ID); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("movements are ordered by arrival", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1), city(cityC, Players[1], "Another", 2, 2))
+		movement := func(id, cityID, playerID, target string, arrival int64) *game.Movement {
+			return &game.Movement{
+				ID: id, CityID: cityID, PlayerID: playerID, Mission: game.MissionAttack, TargetCityID: target,
+				Units: map[string]int{"spearman": 1}, ArrivalTick: arrival,
+			}
+		}
+		incoming := movement("incoming", cityC, Players[1], cityA, 3)
+		outgoing := movement("outgoing", cityA, Players[0], cityC, 5)
+		returning := movement("returning", cityA, Players[0], cityC, 1)
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			for _, m := range []*game.Movement{incoming, outgoing, returning} {
+				state.Movements[m.ID] = m
+			}
+			return nil
+		})
+
+		got, err := db.GetCityMovements(ctx, cityA)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff([]*game.Movement{returning, incoming, outgoing}, got); diff != "" {
+			t.Errorf("unexpected movements diff (-want, +got): %v", diff)
+		}
+
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			delete(state.Movements, returning.ID)
+			return nil
+		})
+		if _, err := db.GetMovement(ctx, returning.ID); !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", utils.ErrNotFound, err)
+		}
+	})
+
+	t.Run("a conquered city keeps nothing of its former owner", func(t *testing.T) {
+		db := newDB(t, world())
+		mustCreateCities(t, db, city(cityA, Players[0], "Capital", 1, 1), city(cityC, Players[1], "Another", 2, 2))
+		engine := &game.TickEngine{Database: db, TickDuration: time.Second}
+		conquest := &game.Movement{
+			ID: "conquest", CityID: cityA, PlayerID: Players[0], Mission: game.MissionAttack, Q: 2, R: 2,
+			TargetCityID: cityC, Units: map[string]int{"horseman": 20, "envoy": 2}, StartTick: 0, ArrivalTick: 1,
+		}
+		// the units of the former owner coming back home after the conquest
+		raid := &game.Movement{
+			ID: "raid", CityID: cityC, PlayerID: Players[1], Mission: game.MissionAttack, Q: 1, R: 1,
+			TargetCityID: cityA, Units: map[string]int{"spearman": 5}, Resources: game.Resources{Food: 100},
+			StartTick: 0, ArrivalTick: 2, Returning: true,
+		}
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			state.Cities[cityC].Loyalty = 30
+			state.Movements[conquest.ID] = conquest
+			state.Movements[raid.ID] = raid
+			state.Schedule(&game.Event{
+				Key: "construction", Tick: 3, Type: game.EventConstructionCompleted, CityID: cityC,
+				Payload: json.RawMessage(`{"building":"farm","level":2,"startTick":0,"playerID":"` + Players[1] + `"}`),
+			})
+			state.Schedule(&game.Event{
+				Key: "training", Tick: 3, Type: game.EventUnitsTrained, CityID: cityC,
+				Payload: json.RawMessage(`{"unit":"spearman","count":1,"startTick":0,"playerID":"` + Players[1] + `"}`),
+			})
+			return nil
+		})
+		for range 3 {
+			mustProcessTick(t, db, engine.Process)
+		}
+
+		got, err := db.GetCity(ctx, cityC)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.PlayerID != Players[0] {
+			t.Fatalf("unexpected owner: want %v, got %v", Players[0], got.PlayerID)
+		}
+		if diff := cmp.Diff(map[string]int{"horseman": 20, "envoy": 1}, got.Units); diff != "" {
+			t.Errorf("unexpected units diff (-want, +got): %v", diff)
+		}
+		if got.Buildings.Farm != 1 || len(got.Constructions) != 0 || len(got.Training) != 0 {
+			t.Errorf("unexpected queues of the former owner: %+v, %+v, %+v", got.Buildings, got.Constructions, got.Training)
+		}
+		if _, err := db.GetMovement(ctx, raid.ID); !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", utils.ErrNotFound, err)
+		}
+	})
+
+	t.Run("battle reports are listed for both sides", func(t *testing.T) {
+		db := newDB(t, world())
+		if _, err := db.GetBattleReport(ctx, "report"); !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", utils.ErrNotFound, err)
+		}
+		older := &game.BattleReport{ID: "older", Tick: 0, AttackerID: Players[0], DefenderID: Players[1]}
+		newer := &game.BattleReport{ID: "newer", Tick: 1, AttackerID: Players[1], DefenderID: Players[0]}
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			state.Reports = append(state.Reports, older)
+			return nil
+		})
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			state.Reports = append(state.Reports, newer)
+			return nil
+		})
+
+		for _, player := range Players {
+			got, err := db.GetBattleReports(ctx, player)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff([]*game.BattleReport{newer, older}, got); diff != "" {
+				t.Errorf("unexpected reports of %s diff (-want, +got): %v", player, diff)
+			}
+		}
+	})
+
+	t.Run("notifications are read after the cursor", func(t *testing.T) {
+		db := newDB(t, world())
+		cursor, err := db.GetNotificationCursor(ctx, Players[0])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cursor != 0 {
+			t.Errorf("unexpected cursor: want 0, got %v", cursor)
+		}
+		mustProcessTick(t, db, func(state *game.TickState) error {
+			state.Notify(Players[0], game.NotificationUnitsTrained, "", nil)
+			state.Notify(Players[1], game.NotificationUnitsTrained, "", nil)
+			state.Notify(Players[0], game.NotificationBattleReport, "", nil)
+			state.Notify(Players[0], game.NotificationCityFounded, "", nil)
+			return nil
+		})
+
+		all, err := db.GetNotifications(ctx, Players[0], 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(all) != 3 || !(all[0].Seq < all[1].Seq && all[1].Seq < all[2].Seq) {
+			t.Fatalf("unexpected notifications: %+v", all)
+		}
+		page, err := db.GetNotifications(ctx, Players[0], all[0].Seq, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(page) != 1 || page[0].Seq != all[1].Seq || page[0].Type != game.NotificationBattleReport {
+			t.Errorf("unexpected page: %+v", page)
+		}
+		cursor, err = db.GetNotificationCursor(ctx, Players[0])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cursor != all[2].Seq {
+			t.Errorf("unexpected cursor: want %v, got %v", all[2].Seq, cursor)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+// Package hex is the geometry of the hex tiles of the game worlds, in the axial coordinates (q, r) of the map
+// and their cube coordinates (q, r, s), where q + r + s = 0.
+//
+// The tiles are pointy-top hexes, as drawn by the web application, i.e., in tile widths the centre of a tile is
+// at x = q + r/2 and y = r * sqrt(3)/2.
+package hex
+
+import "math"
+
+// Hex is a tile in axial coordinates.
+type Hex struct {
+	Q int
+	R int
+}
+
+// Cube is a tile in cube coordinates, where Q + R + S = 0.
+type Cube struct {
+	Q int
+	R int
+	S int
+}
+
+// Directions are the offsets of the six neighbours of a tile, counterclockwise from the east.
+var Directions = [6]Hex{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}}
+
+// Cube returns the tile in cube coordinates.
+func (h Hex) Cube() Cube {
+	return Cube{Q: h.Q, R: h.R, S: -h.Q - h.R}
+}
+
+// Hex returns the tile in axial coordinates.
+func (c Cube) Hex() Hex {
+	return Hex{Q: c.Q, R: c.R}
+}
+
+// Valid returns whether the coordinates are of a tile, i.e., they add up to zero.
+func (c Cube) Valid() bool {
+	return c.Q+c.R+c.S == 0
+}
+
+func (h Hex) Add(o Hex) Hex {
+	return Hex{Q: h.Q + o.Q, R: h.R + o.R}
+}
+
+func (h Hex) Sub(o Hex) Hex {
+	return Hex{Q: h.Q - o.Q, R: h.R - o.R}
+}
+
+func (h Hex) Scale(k int) Hex {
+	return Hex{Q: h.Q * k, R: h.R * k}
+}
+
+// Neighbor returns the neighbour of the tile in the direction, see Directions.
+func (h Hex) Neighbor(direction int) Hex {
+	return h.Add(Directions[direction])
+}
+
+// Neighbors returns the six neighbours of the tile, in the order of Directions.
+func (h Hex) Neighbors() [6]Hex {
+	var n [6]Hex
+	for i, d := range Directions {
+		n[i] = h.Add(d)
+	}
+	return n
+}
+
+// Distance returns the number of steps between two tiles.
+func Distance(a, b Hex) int {
+	d := b.Sub(a).Cube()
+	return (abs(d.Q) + abs(d.R) + abs(d.S)) / 2
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Round returns the tile of fractional axial coordinates, e.g., of a point of the map.
+func Round(q, r float64) Hex {
+	s := -q - r
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	// the coordinate furthest from its rounding is the one that breaks the sum, so it is derived from the others
+	switch {
+	case dq > dr && dq > ds:
+		rq = -rr - rs
+	case dr > ds:
+		rr = -rq - rs
+	}
+	return Hex{Q: int(rq), R: int(rr)}
+}
+
+// Ring returns the tiles at exactly the radius from the centre, counterclockwise from the south-west corner.
+// The ring of radius 0 is the centre alone.
+func Ring(center Hex, radius int) []Hex {
+	if radius <= 0 {
+		return []Hex{center}
+	}
+	ring := make([]Hex, 0, 6*radius)
+	h := center.Add(Directions[4].Scale(radius))
+	for direction := range Directions {
+		for range radius {
+			ring = append(ring, h)
+			h = h.Neighbor(direction)
+		}
+	}
+	return ring
+}
+
+// Spiral returns the tiles within the radius of the centre, ring after ring from the centre outwards.
+func Spiral(center Hex, radius int) []Hex {
+	spiral := make([]Hex, 0, 1+3*radius*(radius+1))
+	for r := 0; r <= radius; r++ {
+		spiral = append(spiral, Ring(center, r)...)
+	}
+	return spiral
+}
+
+// Range returns the tiles within the distance of the centre, sorted by q and then r.
+func Range(center Hex, distance int) []Hex {
+	if distance < 0 {
+		return nil
+	}
+	tiles := make([]Hex, 0, 1+3*distance*(distance+1))
+	for dq := -distance; dq <= distance; dq++ {
+		for dr := max(-distance, -dq-distance); dr <= min(distance, -dq+distance); dr++ {
+			tiles = append(tiles, center.Add(Hex{Q: dq, R: dr}))
+		}
+	}
+	return tiles
+}
+
+// Line returns the tiles crossed by the straight line between the centres of two tiles, from a to b, each a
+// neighbour of the previous one.
+func Line(a, b Hex) []Hex {
+	n := Distance(a, b)
+	line := make([]Hex, 0, n+1)
+	// nudged such that a line along the edge between two tiles always picks the same side
+	aq, ar := float64(a.Q)+1e-6, float64(a.R)+2e-6
+	bq, br := float64(b.Q)+1e-6, float64(b.R)+2e-6
+	for i := 0; i <= n; i++ {
+		t := 0.0
+		if n > 0 {
+			t = float64(i) / float64(n)
+		}
+		line = append(line, Round(aq+(bq-aq)*t, ar+(br-ar)*t))
+	}
+	return line
+}
@@ -0,0 +1,212 @@
+package hex
+
+import (
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// point is a random tile for the property-based tests, near enough to the origin to never overflow.
+type point Hex
+
+func (point) Generate(rand *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(point{Q: rand.Intn(201) - 100, R: rand.Intn(201) - 100})
+}
+
+// radius is a random radius for the property-based tests.
+type radius int
+
+func (radius) Generate(rand *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(radius(rand.Intn(8)))
+}
+
+// check runs the property-based test, failing the test if the property does not hold.
+func check(t *testing.T, property any) {
+	t.Helper()
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// distinct returns whether all tiles are different.
+func distinct(tiles []Hex) bool {
+	seen := make(map[Hex]bool, len(tiles))
+	for _, h := range tiles {
+		if seen[h] {
+			return false
+		}
+		seen[h] = true
+	}
+	return true
+}
+
+func Test_Distance(t *testing.T) {
+	testcases := []struct {
+		name string
+		a, b Hex
+		want int
+	}{
+		{name: "same tile", a: Hex{3, 3}, b: Hex{3, 3}, want: 0},
+		{name: "along q", a: Hex{0, 0}, b: Hex{4, 0}, want: 4},
+		{name: "along r", a: Hex{0, 0}, b: Hex{0, -4}, want: 4},
+		{name: "diagonal", a: Hex{0, 0}, b: Hex{3, -3}, want: 3},
+		{name: "mixed", a: Hex{1, 2}, b: Hex{4, 4}, want: 5},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			if got := Distance(testcase.a, testcase.b); got != testcase.want {
+				t.Errorf("unexpected distance: want %v, got %v", testcase.want, got)
+			}
+		})
+	}
+
+	t.Run("is a metric", func(t *testing.T) {
+		check(t, func(a, b, c point) bool {
+			ab := Distance(Hex(a), Hex(b))
+			return ab >= 0 && (ab == 0) == (a == b) && ab == Distance(Hex(b), Hex(a)) &&
+				Distance(Hex(a), Hex(c)) <= ab+Distance(Hex(b), Hex(c))
+		})
+	})
+	t.Run("is invariant to translation", func(t *testing.T) {
+		check(t, func(a, b, offset point) bool {
+			return Distance(Hex(a), Hex(b)) == Distance(Hex(a).Add(Hex(offset)), Hex(b).Add(Hex(offset)))
+		})
+	})
+}
+
+func Test_Cube(t *testing.T) {
+	check(t, func(p point) bool {
+		c := Hex(p).Cube()
+		return c.Valid() && c.Hex() == Hex(p)
+	})
+}
+
+func Test_Neighbors(t *testing.T) {
+	check(t, func(p point) bool {
+		neighbors := Hex(p).Neighbors()
+		for i, n := range neighbors {
+			if Distance(Hex(p), n) != 1 || n != Hex(p).Neighbor(i) {
+				return false
+			}
+		}
+		return distinct(neighbors[:])
+	})
+}
+
+func Test_Round(t *testing.T) {
+	testcases := []struct {
+		name string
+		q, r float64
+		want Hex
+	}{
+		{name: "centre", q: 2, r: -1, want: Hex{2, -1}},
+		{name: "near the centre", q: 2.2, r: -0.9, want: Hex{2, -1}},
+		{name: "rounding q breaks the sum", q: 0.6, r: 0.3, want: Hex{1, 0}},
+		{name: "rounding r breaks the sum", q: 0.3, r: 0.6, want: Hex{0, 1}},
+		{name: "negative coordinates", q: -1.4, r: 0.3, want: Hex{-1, 0}},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			if got := Round(testcase.q, testcase.r); got != testcase.want {
+				t.Errorf("unexpected tile: want %v, got %v", testcase.want, got)
+			}
+		})
+	}
+
+	t.Run("is the closest tile", func(t *testing.T) {
+		check(t, func(p point, dq, dr uint8) bool {
+			// within a third of a step of the centre, which is always inside the tile
+			q, r := float64(p.Q)+float64(dq)/256/3, float64(p.R)-float64(dr)/256/3
+			return Round(q, r) == Hex(p)
+		})
+	})
+}
+
+func Test_Ring(t *testing.T) {
+	check(t, func(center point, r radius) bool {
+		ring := Ring(Hex(center), int(r))
+		if len(ring) != max(1, 6*int(r)) || !distinct(ring) {
+			return false
+		}
+		for i, h := range ring {
+			if Distance(Hex(center), h) != int(r) {
+				return false
+			}
+			// each tile of a ring follows the previous one, and the last closes the ring
+			if next := ring[(i+1)%len(ring)]; r > 0 && Distance(h, next) != 1 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func Test_Spiral(t *testing.T) {
+	check(t, func(center point, r radius) bool {
+		spiral := Spiral(Hex(center), int(r))
+		if len(spiral) != 1+3*int(r)*int(r+1) || !distinct(spiral) || spiral[0] != Hex(center) {
+			return false
+		}
+		for i := 1; i < len(spiral); i++ {
+			if Distance(Hex(center), spiral[i]) < Distance(Hex(center), spiral[i-1]) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func Test_Range(t *testing.T) {
+	check(t, func(center point, r radius) bool {
+		tiles := Range(Hex(center), int(r))
+		spiral := Spiral(Hex(center), int(r))
+		slices.SortFunc(spiral, func(a, b Hex) int {
+			if a.Q != b.Q {
+				return a.Q - b.Q
+			}
+			return a.R - b.R
+		})
+		return slices.Equal(tiles, spiral)
+	})
+}
+
+func Test_Line(t *testing.T) {
+	testcases := []struct {
+		name string
+		a, b Hex
+		want []Hex
+	}{
+		{name: "single tile", a: Hex{1, 1}, b: Hex{1, 1}, want: []Hex{{1, 1}}},
+		{name: "along q", a: Hex{0, 0}, b: Hex{3, 0}, want: []Hex{{0, 0}, {1, 0}, {2, 0}, {3, 0}}},
+		{name: "along an edge", a: Hex{0, 0}, b: Hex{1, 1}, want: []Hex{{0, 0}, {0, 1}, {1, 1}}},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testcase.want, Line(testcase.a, testcase.b)); diff != "" {
+				t.Errorf("unexpected line diff (-want, +got): %v", diff)
+			}
+		})
+	}
+
+	t.Run("connects the tiles", func(t *testing.T) {
+		check(t, func(a, b point) bool {
+			line := Line(Hex(a), Hex(b))
+			if len(line) != Distance(Hex(a), Hex(b))+1 || line[0] != Hex(a) || line[len(line)-1] != Hex(b) {
+				return false
+			}
+			for i := 1; i < len(line); i++ {
+				if Distance(line[i-1], line[i]) != 1 {
+					return false
+				}
+			}
+			return true
+		})
+	})
+}
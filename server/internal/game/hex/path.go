@@ -0,0 +1,93 @@
+package hex
+
+import "container/heap"
+
+// Tiles are the biomes of the tiles of a map.
+type Tiles map[Hex]int
+
+// Passable returns whether a tile is on the map and its biome is one of the given biomes, e.g., to find a path
+// only through the land.
+func (t Tiles) Passable(biomes ...int) func(Hex) bool {
+	passable := make(map[int]bool, len(biomes))
+	for _, b := range biomes {
+		passable[b] = true
+	}
+	return func(h Hex) bool {
+		biome, ok := t[h]
+		return ok && passable[biome]
+	}
+}
+
+// Path returns a shortest path between two tiles through passable tiles, from the start to the goal, or nil if
+// there is none. Both the start and the goal must be passable.
+//
+// The search gives up once every passable tile reachable from the start was visited, so passable must be false
+// for all but a finite number of tiles (e.g., the tiles of a map) unless the goal is known to be reachable.
+func Path(from, to Hex, passable func(Hex) bool) []Hex {
+	if !passable(from) || !passable(to) {
+		return nil
+	}
+
+	// A* search, where the distance is the exact cost of an unobstructed path, so it never overestimates
+	cameFrom := map[Hex]Hex{from: from}
+	cost := map[Hex]int{from: 0}
+	open := &frontier{}
+	heap.Push(open, &node{hex: from, priority: Distance(from, to)})
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*node).hex
+		if current == to {
+			break
+		}
+		for _, next := range current.Neighbors() {
+			if !passable(next) {
+				continue
+			}
+			c := cost[current] + 1
+			if known, ok := cost[next]; ok && known <= c {
+				continue
+			}
+			cost[next] = c
+			cameFrom[next] = current
+			heap.Push(open, &node{hex: next, priority: c + Distance(next, to), seq: len(cost)})
+		}
+	}
+	if _, ok := cameFrom[to]; !ok {
+		return nil
+	}
+
+	path := make([]Hex, cost[to]+1)
+	for h, i := to, cost[to]; i >= 0; h, i = cameFrom[h], i-1 {
+		path[i] = h
+	}
+	return path
+}
+
+type node struct {
+	hex      Hex
+	priority int
+	// seq breaks the ties of the priority in order of discovery, such that paths are deterministic
+	seq int
+}
+
+// frontier is a min-heap of the nodes to visit by priority, see container/heap.
+type frontier []*node
+
+func (f frontier) Len() int { return len(f) }
+
+func (f frontier) Less(i, j int) bool {
+	if f[i].priority != f[j].priority {
+		return f[i].priority < f[j].priority
+	}
+	return f[i].seq < f[j].seq
+}
+
+func (f frontier) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+
+func (f *frontier) Push(x any) { *f = append(*f, x.(*node)) }
+
+func (f *frontier) Pop() any {
+	old := *f
+	n := old[len(old)-1]
+	*f = old[:len(old)-1]
+	return n
+}
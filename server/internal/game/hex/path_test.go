@@ -0,0 +1,124 @@
+package hex
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	water = iota
+	land
+)
+
+// makeTiles returns the tiles of a map with a row per r, where '#' is land and anything else is water.
+func makeTiles(rows ...string) Tiles {
+	tiles := Tiles{}
+	for r, row := range rows {
+		for q, c := range row {
+			tiles[Hex{Q: q, R: r}] = water
+			if c == '#' {
+				tiles[Hex{Q: q, R: r}] = land
+			}
+		}
+	}
+	return tiles
+}
+
+func Test_Path(t *testing.T) {
+	testcases := []struct {
+		name     string
+		tiles    Tiles
+		from, to Hex
+		want     []Hex
+	}{
+		{
+			name:  "same tile",
+			tiles: makeTiles("#"),
+			from:  Hex{0, 0}, to: Hex{0, 0},
+			want: []Hex{{0, 0}},
+		},
+		{
+			name: "straight line",
+			tiles: makeTiles(
+				"####",
+			),
+			from: Hex{0, 0}, to: Hex{3, 0},
+			want: []Hex{{0, 0}, {1, 0}, {2, 0}, {3, 0}},
+		},
+		{
+			name: "no path through the water",
+			tiles: makeTiles(
+				"#~#",
+				"#~~",
+			),
+			from: Hex{0, 0}, to: Hex{2, 0},
+			want: nil,
+		},
+		{
+			name: "detour",
+			tiles: makeTiles(
+				"#~#",
+				"###",
+			),
+			from: Hex{0, 0}, to: Hex{2, 0},
+			want: []Hex{{0, 0}, {0, 1}, {1, 1}, {2, 0}},
+		},
+		{
+			name:  "impassable goal",
+			tiles: makeTiles("#~"),
+			from:  Hex{0, 0}, to: Hex{1, 0},
+			want: nil,
+		},
+		{
+			name:  "outside of the map",
+			tiles: makeTiles("#"),
+			from:  Hex{0, 0}, to: Hex{5, 5},
+			want: nil,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			got := Path(testcase.from, testcase.to, testcase.tiles.Passable(land))
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Errorf("unexpected path diff (-want, +got): %v", diff)
+			}
+		})
+	}
+
+	t.Run("is a shortest path without obstacles", func(t *testing.T) {
+		check(t, func(a, b point) bool {
+			path := Path(Hex(a), Hex(b), func(Hex) bool { return true })
+			return len(path) == Distance(Hex(a), Hex(b))+1 && path[0] == Hex(a) && path[len(path)-1] == Hex(b)
+		})
+	})
+	t.Run("only steps on passable tiles", func(t *testing.T) {
+		tiles := makeTiles(
+			"#####~####",
+			"#~~~#~#~~#",
+			"#~#~###~##",
+			"#~#~~~~~#~",
+			"###~####~#",
+			"~~#~#~~#~#",
+			"#~###~###~",
+		)
+		passable := tiles.Passable(land)
+		check(t, func(a, b point) bool {
+			from, to := Hex{Q: abs(a.Q) % 10, R: abs(a.R) % 7}, Hex{Q: abs(b.Q) % 10, R: abs(b.R) % 7}
+			path := Path(from, to, passable)
+			if path == nil {
+				return true
+			}
+			if path[0] != from || path[len(path)-1] != to || len(path) < Distance(from, to)+1 {
+				return false
+			}
+			for i, h := range path {
+				if !passable(h) || (i > 0 && Distance(path[i-1], h) != 1) {
+					return false
+				}
+			}
+			return true
+		})
+	})
+}
@@ -1,73 +1,228 @@
-package game
-
-import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"net/http"
-
-	"github.com/luisferreira32/stickian/server/internal/utils"
-)
-
-const worldSize = 256
-
-type GetMapChunkResponse struct {
-	Biome [][]int `json:"biome"`
-}
-
-type GetMapChunkRequest struct {
-	MinQ int `json:"minQ"`
-	MaxQ int `json:"maxQ"`
-	MinR int `json:"minR"`
-	MaxR int `json:"maxR"`
-}
-
-func validateMapChunkRequest(req *GetMapChunkRequest) error {
-	if req.MinQ < 0 || req.MaxQ > worldSize || req.MinR < 0 || req.MaxR > worldSize {
-		return errors.New("invalid map chunk request")
-	}
-	return nil
-}
-
-func (s *GameService) GetMapChunk(w http.ResponseWriter, r *http.Request) {
-	req := GetMapChunkRequest{}
-	err := json.Unmarshal([]byte(r.URL.Query().Get("coords")), &req)
-	if err != nil {
-		utils.WithError(w, fmt.Errorf("invalid request parameters: %w", err))
-		return
-	}
-
-	if err := validateMapChunkRequest(&req); err != nil {
-		utils.WithError(w, err)
-		return
-	}
-
-	tiles, err := s.Database.GetMap(r.Context(), req.MinQ, req.MaxQ, req.MinR, req.MaxR)
-	if err != nil {
-		utils.WithError(w, fmt.Errorf("failed to fetch map: %w", err))
-		return
-	}
-
-	// Transform tiles into the 2D biome array expected by MapChunkResponse
-	// We need to know the dimensions to create the array
-	width := req.MaxQ - req.MinQ + 1
-	height := req.MaxR - req.MinR + 1
-	biome := make([][]int, width)
-	for i := range biome {
-		biome[i] = make([]int, height)
-	}
-
-	for _, t := range tiles {
-		qIdx := t.Q - req.MinQ
-		rIdx := t.R - req.MinR
-		if qIdx >= 0 && qIdx < width && rIdx >= 0 && rIdx < height {
-			biome[qIdx][rIdx] = t.Biome
-		}
-	}
-
-	utils.WithDefaultOKHeaders(w)
-	if err := json.NewEncoder(w).Encode(GetMapChunkResponse{Biome: biome}); err != nil {
-		utils.WithError(w, fmt.Errorf("failed to encode map: %w", err))
-		return
-	}
-}
+package game
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+// Biomes of the world tiles, matching the ids of the world generator.
+const (
+	BiomeOcean = iota
+	BiomeSea
+	BiomeBeach
+	BiomePlains
+	BiomeMountain
+)
+
+// BiomeUnexplored is the biome of the tiles a player did not explore yet (see vision.go), the largest that fits
+// in a byte of the map chunks.
+const BiomeUnexplored = 255
+
+type GetMapChunkResponse struct {
+	Biome [][]int `json:"biome"`
+}
+
+type GetMapChunkRequest struct {
+	MinQ int `json:"minQ"`
+	MaxQ int `json:"maxQ"`
+	MinR int `json:"minR"`
+	MaxR int `json:"maxR"`
+}
+
+func validateMapChunkRequest(world *World, req *GetMapChunkRequest) error {
+	if req.MinQ < 0 || req.MaxQ > world.Size || req.MinR < 0 || req.MaxR > world.Size {
+		return errors.New("invalid map chunk request")
+	}
+	return nil
+}
+
+// GetMapChunk returns the biomes of the tiles of a world within the coordinates, of the classic world if the
+// world query parameter is not set, where the tiles the player did not explore are BiomeUnexplored. Unlike the
+// chunks of GetChunk, the response cannot be cached.
+func (s *GameService) GetMapChunk(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	req := GetMapChunkRequest{}
+	err := json.Unmarshal([]byte(r.URL.Query().Get("coords")), &req)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("invalid request parameters: %w", err))
+		return
+	}
+
+	world, err := s.worldParam(r)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if err := validateMapChunkRequest(world, &req); err != nil {
+		utils.WithError(w, err)
+		return
+	}
+
+	v, err := s.playerVision(r.Context(), world, principal)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	known, err := s.exploreVision(r.Context(), v, principal.UserID, nil, nil)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+
+	tiles, err := s.Database.GetMap(r.Context(), world.ID, req.MinQ, req.MaxQ, req.MinR, req.MaxR)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("failed to fetch map: %w", err))
+		return
+	}
+
+	// Transform tiles into the 2D biome array expected by MapChunkResponse
+	// We need to know the dimensions to create the array
+	width := req.MaxQ - req.MinQ + 1
+	height := req.MaxR - req.MinR + 1
+	biome := make([][]int, width)
+	for i := range biome {
+		biome[i] = make([]int, height)
+	}
+
+	for _, t := range tiles {
+		qIdx := t.Q - req.MinQ
+		rIdx := t.R - req.MinR
+		if qIdx >= 0 && qIdx < width && rIdx >= 0 && rIdx < height {
+			biome[qIdx][rIdx] = t.Biome
+		}
+	}
+	if !v.all {
+		for qIdx := range biome {
+			for rIdx := range biome[qIdx] {
+				q, r := req.MinQ+qIdx, req.MinR+rIdx
+				if world.contains(q, r) && !explored(world, known, q, r) {
+					biome[qIdx][rIdx] = BiomeUnexplored
+				}
+			}
+		}
+	}
+
+	utils.WithDefaultOKHeaders(w)
+	if err := json.NewEncoder(w).Encode(GetMapChunkResponse{Biome: biome}); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode map: %w", err))
+		return
+	}
+}
+
+const (
+	// ChunkSize is the number of tiles of both axes of a map chunk, where the chunk (cq, cr) starts at the tile
+	// (cq*ChunkSize, cr*ChunkSize)
+	ChunkSize = 32
+	// chunkMaxAge is how long the clients keep a map chunk before revalidating it, long since the terrain of a
+	// world never changes
+	chunkMaxAge = 7 * 24 * time.Hour
+)
+
+// encodeChunk encodes the biomes of the tiles of a chunk, by q and then r, as its width and height followed by
+// the runs of tiles with the same biome, each as its length (up to 255) and the biome. E.g., a chunk of ocean is
+// only a few bytes.
+func encodeChunk(width, height int, biomes []byte) []byte {
+	chunk := []byte{byte(width), byte(height)}
+	for i := 0; i < len(biomes); {
+		run := 1
+		for i+run < len(biomes) && biomes[i+run] == biomes[i] && run < 255 {
+			run++
+		}
+		chunk = append(chunk, byte(run), biomes[i])
+		i += run
+	}
+	return chunk
+}
+
+// GetChunk returns the biomes of the tiles of a chunk of a world, of the classic world if the world query
+// parameter is not set, run-length encoded (see encodeChunk). The chunks at the edges of the world are smaller.
+//
+// The tiles the player did not explore are BiomeUnexplored. The chunks are cached by the clients and revalidated
+// with their ETag: the fully explored ones for long, since the terrain never changes, and the others right away,
+// since the player may explore them any time.
+func (s *GameService) GetChunk(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	cq, err := strconv.Atoi(r.PathValue("cq"))
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("%w: invalid cq parameter: %w", utils.ErrUserError, err))
+		return
+	}
+	cr, err := strconv.Atoi(r.PathValue("cr"))
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("%w: invalid cr parameter: %w", utils.ErrUserError, err))
+		return
+	}
+
+	world, err := s.worldParam(r)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	minQ, minR := cq*ChunkSize, cr*ChunkSize
+	if !world.contains(minQ, minR) {
+		utils.WithError(w, fmt.Errorf("%w: chunk (%d, %d) is outside of the world", utils.ErrNotFound, cq, cr))
+		return
+	}
+	width, height := min(ChunkSize, world.Size-minQ), min(ChunkSize, world.Size-minR)
+
+	v, err := s.playerVision(r.Context(), world, principal)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	known, err := s.exploreVision(r.Context(), v, principal.UserID, nil, nil)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+
+	tiles, err := s.Database.GetMap(r.Context(), world.ID, minQ, minQ+width-1, minR, minR+height-1)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("failed to fetch map: %w", err))
+		return
+	}
+
+	biomes := make([]byte, width*height) // missing tiles are ocean
+	for _, t := range tiles {
+		q, r := t.Q-minQ, t.R-minR
+		if q >= 0 && q < width && r >= 0 && r < height {
+			biomes[q*height+r] = byte(t.Biome)
+		}
+	}
+	maxAge := chunkMaxAge
+	for q := range width {
+		for r := range height {
+			if !v.all && !explored(world, known, minQ+q, minR+r) {
+				biomes[q*height+r] = BiomeUnexplored
+				maxAge = 0
+			}
+		}
+	}
+	chunk := encodeChunk(width, height, biomes)
+	sum := sha256.Sum256(chunk)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	if !utils.WithCacheableHeaders(w, r, "application/octet-stream", etag, maxAge) {
+		return
+	}
+	_, _ = w.Write(chunk)
+}
@@ -0,0 +1,210 @@
+package game
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+func Test_EncodeChunk(t *testing.T) {
+	testcases := []struct {
+		name          string
+		width, height int
+		biomes        []byte
+		want          []byte
+	}{
+		{
+			name:  "runs of biomes",
+			width: 2, height: 3,
+			biomes: []byte{3, 3, 0, 3, 4, 4},
+			want:   []byte{2, 3, 2, 3, 1, 0, 1, 3, 2, 4},
+		},
+		{
+			name:  "runs longer than a byte",
+			width: 32, height: 32,
+			biomes: make([]byte, 32*32),
+			want:   []byte{32, 32, 255, 0, 255, 0, 255, 0, 255, 0, 4, 0},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			got := encodeChunk(testcase.width, testcase.height, testcase.biomes)
+			if diff := cmp.Diff(testcase.want, got); diff != "" {
+				t.Errorf("unexpected chunk diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_GetChunk(t *testing.T) {
+	small := &World{ID: "small", Name: "small", Size: 40, Speed: 1, Status: WorldOpen}
+	// the bitset of the explored tiles of the whole world
+	all := bytes.Repeat([]byte{0xff}, 40*40/8)
+	tiles := makeMap(
+		"330",
+		"300",
+		"000",
+	)
+	testcases := []struct {
+		name       string
+		cq, cr     string
+		explored   []byte
+		mockErr    error
+		wantBounds [4]int
+		wantStatus int
+		wantBody   []byte
+		wantETag   bool
+		// wantMaxAge is the max-age of the cached chunks
+		wantMaxAge string
+	}{
+		{
+			name: "first chunk",
+			cq:   "0", cr: "0",
+			explored:   all,
+			wantBounds: [4]int{0, 31, 0, 31},
+			wantStatus: 200,
+			// by q and then r: the two plains of q = 0, the ocean up to the plains of q = 1, and only ocean after
+			wantBody:   []byte{32, 32, 2, 3, 30, 0, 1, 3, 255, 0, 255, 0, 255, 0, 226, 0},
+			wantETag:   true,
+			wantMaxAge: "604800",
+		},
+		{
+			name: "unexplored tiles",
+			cq:   "0", cr: "0",
+			// only the tiles (0, 0) to (0, 7)
+			explored:   []byte{0xff},
+			wantBounds: [4]int{0, 31, 0, 31},
+			wantStatus: 200,
+			wantBody:   []byte{32, 32, 2, 3, 6, 0, 255, 255, 255, 255, 255, 255, 251, 255},
+			wantETag:   true,
+			wantMaxAge: "0",
+		},
+		{
+			name: "chunk at the edge of the world",
+			cq:   "1", cr: "0",
+			explored:   all,
+			wantBounds: [4]int{32, 39, 0, 31},
+			wantStatus: 200,
+			wantBody:   []byte{8, 32, 255, 0, 1, 0},
+			wantETag:   true,
+			wantMaxAge: "604800",
+		},
+		{
+			name: "chunk outside of the world",
+			cq:   "2", cr: "0",
+			wantStatus: 404,
+			wantBody:   []byte("not found: chunk (2, 0) is outside of the world\n"),
+		},
+		{
+			name: "negative chunk",
+			cq:   "0", cr: "-1",
+			wantStatus: 404,
+			wantBody:   []byte("not found: chunk (0, -1) is outside of the world\n"),
+		},
+		{
+			name: "invalid chunk",
+			cq:   "a", cr: "0",
+			wantStatus: 400,
+			wantBody:   []byte("user error: invalid cq parameter: strconv.Atoi: parsing \"a\": invalid syntax\n"),
+		},
+		{
+			name: "database error",
+			cq:   "0", cr: "0",
+			mockErr:    errors.New("a database error"),
+			wantBounds: [4]int{0, 31, 0, 31},
+			wantStatus: 500,
+			wantBody:   []byte("failed to fetch map: a database error\n"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			var gotBounds [4]int
+			// given
+			mockDB := &mockDatabase{
+				GetWorldFunc: func(id string) (*World, error) {
+					return small, nil
+				},
+				GetMapFunc: func(minQ, maxQ, minR, maxR int) ([]*MapTile, error) {
+					gotBounds = [4]int{minQ, maxQ, minR, maxR}
+					return tiles, testcase.mockErr
+				},
+				ExploreFunc: func(playerID string, tiles []byte, sightings []*City) error {
+					return nil
+				},
+			}
+			mockVision(mockDB, testcase.explored)
+			service := &GameService{Database: mockDB}
+
+			// when
+			req := httptest.NewRequest("GET", "/api/map/chunks/"+testcase.cq+"/"+testcase.cr+"?world=small", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+			req.SetPathValue("cq", testcase.cq)
+			req.SetPathValue("cr", testcase.cr)
+			service.GetChunk(rec, req)
+
+			// then
+			if testcase.wantBounds != gotBounds {
+				t.Errorf("unexpected bounds: want %v, got %v", testcase.wantBounds, gotBounds)
+			}
+			if testcase.wantStatus != rec.Code {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+			if diff := cmp.Diff(testcase.wantBody, rec.Body.Bytes()); diff != "" {
+				t.Errorf("unexpected body diff (-want, +got): %v", diff)
+			}
+			if etag := rec.Header().Get("ETag"); (etag != "") != testcase.wantETag {
+				t.Errorf("unexpected etag: %q", etag)
+			}
+			if got := rec.Header().Get("Cache-Control"); testcase.wantMaxAge != "" && got != "private, max-age="+testcase.wantMaxAge {
+				t.Errorf("unexpected cache control: %q", got)
+			}
+		})
+	}
+
+	t.Run("not modified", func(t *testing.T) {
+		// given
+		mockDB := &mockDatabase{
+			GetMapFunc: func(minQ, maxQ, minR, maxR int) ([]*MapTile, error) {
+				return tiles, nil
+			},
+		}
+		mockVision(mockDB, bytes.Repeat([]byte{0xff}, DefaultWorld.Size*DefaultWorld.Size/8))
+		service := &GameService{Database: mockDB}
+		newRequest := func() *http.Request {
+			req := httptest.NewRequest("GET", "/api/map/chunks/0/0", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+			req.SetPathValue("cq", "0")
+			req.SetPathValue("cr", "0")
+			return req
+		}
+		first := httptest.NewRecorder()
+		service.GetChunk(first, newRequest())
+		etag := first.Header().Get("ETag")
+
+		// when
+		rec := httptest.NewRecorder()
+		req := newRequest()
+		req.Header.Set("If-None-Match", etag)
+		service.GetChunk(rec, req)
+
+		// then
+		if rec.Code != 304 || rec.Body.Len() != 0 {
+			t.Errorf("unexpected response: %v, %s", rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("ETag"); got != etag || etag == "" {
+			t.Errorf("unexpected etag: want %q, got %q", etag, got)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "private, max-age=604800" {
+			t.Errorf("unexpected cache control: %q", got)
+		}
+	})
+}
@@ -0,0 +1,644 @@
+package game
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+// MemoryDatabase is an in-memory implementation of the GameDatabase with the same semantics as the
+// PostgresDatabase, e.g., for tests or to run the server without a database. Nothing is persisted.
+//
+// All values are copied in and out of the database, such that callers can never change its state.
+type MemoryDatabase struct {
+	mu            sync.RWMutex
+	clock         Clock
+	worlds        map[string]*World
+	tiles         map[string]map[[2]int]*MapTile
+	cities        map[string]*City
+	events        []*Event
+	eventKeys     map[string]bool
+	movements     map[string]*Movement
+	reports       map[string][]byte
+	notifications []*Notification
+	// explored are the explored tiles of the players, by world and player
+	explored map[[2]string][]byte
+	// sightings are the cities seen by the players, by player and city
+	sightings map[[2]string]*City
+}
+
+// NewMemoryDatabase returns an empty in-memory database for the classic world with the given tiles, whose
+// clock starts now.
+func NewMemoryDatabase(tiles []*MapTile) *MemoryDatabase {
+	db := &MemoryDatabase{
+		clock:     Clock{StartedAt: time.Now(), LastTick: -1},
+		worlds:    make(map[string]*World),
+		tiles:     make(map[string]map[[2]int]*MapTile),
+		cities:    make(map[string]*City),
+		eventKeys: make(map[string]bool),
+		movements: make(map[string]*Movement),
+		reports:   make(map[string][]byte),
+		explored:  make(map[[2]string][]byte),
+		sightings: make(map[[2]string]*City),
+	}
+	db.createWorld(DefaultWorld, tiles)
+	return db
+}
+
+// cloneWorld returns a copy of the world.
+func cloneWorld(w *World) *World {
+	world := *w
+	if w.EndsAt != nil {
+		endsAt := *w.EndsAt
+		world.EndsAt = &endsAt
+	}
+	return &world
+}
+
+func (db *MemoryDatabase) GetWorld(_ context.Context, id string) (*World, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	w, ok := db.worlds[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	return cloneWorld(w), nil
+}
+
+// GetWorlds returns all worlds, in order of start.
+func (db *MemoryDatabase) GetWorlds(_ context.Context) ([]*World, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	worlds := make([]*World, 0, len(db.worlds))
+	for _, w := range db.worlds {
+		worlds = append(worlds, cloneWorld(w))
+	}
+	slices.SortFunc(worlds, func(a, b *World) int {
+		return cmp.Or(a.StartsAt.Compare(b.StartsAt), cmp.Compare(a.Name, b.Name))
+	})
+	return worlds, nil
+}
+
+// CreateWorld creates the world with its tiles. A world that exists without tiles gets the tiles, along with
+// their size and placement, while one that already has them is left as is and ErrWorldExists is returned.
+func (db *MemoryDatabase) CreateWorld(_ context.Context, w *World, tiles []*MapTile) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if len(db.tiles[w.ID]) > 0 {
+		return fmt.Errorf("world creation: %w", ErrWorldExists)
+	}
+	db.createWorld(w, tiles)
+	return nil
+}
+
+func (db *MemoryDatabase) createWorld(w *World, tiles []*MapTile) {
+	if world, ok := db.worlds[w.ID]; ok {
+		world.Size = w.Size
+		world.Placement = w.Placement
+	} else {
+		db.worlds[w.ID] = cloneWorld(w)
+	}
+	db.tiles[w.ID] = make(map[[2]int]*MapTile, len(tiles))
+	for _, t := range tiles {
+		tile := *t
+		tile.WorldID = w.ID
+		db.tiles[w.ID][[2]int{t.Q, t.R}] = &tile
+	}
+}
+
+// cloneCity returns a copy of the city with its buildings, resources and units.
+func cloneCity(c *City) *City {
+	city := &City{
+		ID:       c.ID,
+		PlayerID: c.PlayerID,
+		WorldID:  c.WorldID,
+		Name:     c.Name,
+		Q:        c.Q,
+		R:        c.R,
+		Biome:    c.Biome,
+		Points:   c.Points,
+		Loyalty:  c.Loyalty,
+	}
+	resources, buildings := Resources{}, Buildings{}
+	if c.Resources != nil {
+		resources = *c.Resources
+	}
+	if c.Buildings != nil {
+		buildings = *c.Buildings
+	}
+	city.Resources, city.Buildings = &resources, &buildings
+	for unit, count := range c.Units {
+		if count <= 0 {
+			continue
+		}
+		if city.Units == nil {
+			city.Units = make(map[string]int)
+		}
+		city.Units[unit] = count
+	}
+	return city
+}
+
+// cloneMovement returns a copy of the movement.
+func cloneMovement(m *Movement) *Movement {
+	movement := *m
+	movement.Units = maps.Clone(m.Units)
+	return &movement
+}
+
+// cloneEvent returns a copy of the event.
+func cloneEvent(e *Event) *Event {
+	event := *e
+	event.Payload = slices.Clone(e.Payload)
+	return &event
+}
+
+// cityRow returns only the city table fields of a city, as returned by the listings.
+func cityRow(c *City) *City {
+	return &City{
+		ID: c.ID, PlayerID: c.PlayerID, WorldID: c.WorldID, Name: c.Name, Q: c.Q, R: c.R, Biome: c.Biome, Points: c.Points,
+	}
+}
+
+// cityAt returns the city on the given tile of the world, if any.
+func (db *MemoryDatabase) cityAt(worldID string, q, r int) *City {
+	for _, c := range db.cities {
+		if c.WorldID == worldID && c.Q == q && c.R == r {
+			return c
+		}
+	}
+	return nil
+}
+
+// GetCity returns a city with its buildings, resources, units, and construction and training queues.
+func (db *MemoryDatabase) GetCity(_ context.Context, id string) (*City, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	c, ok := db.cities[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	city := cloneCity(c)
+	for _, e := range db.pending(db.clock.LastTick + 1) {
+		if e.CityID != id {
+			continue
+		}
+		if err := city.addPending(e); err != nil {
+			return nil, err
+		}
+	}
+	return city, nil
+}
+
+// pending returns the events that make up the queues of the cities from the given tick on, in order.
+func (db *MemoryDatabase) pending(from int64) []*Event {
+	var events []*Event
+	for _, e := range db.events {
+		if e.Tick >= from && slices.Contains(pendingEventTypes, e.Type) {
+			events = append(events, cloneEvent(e))
+		}
+	}
+	slices.SortStableFunc(events, func(a, b *Event) int {
+		return cmp.Compare(a.Tick, b.Tick)
+	})
+	return events
+}
+
+// GetCities returns all cities of a world within the bounding box defined by vertices
+// (q1, r1) and (q2, r2). The range is normalised so order does not matter.
+// Only city table fields are returned — Buildings and Resources are omitted.
+func (db *MemoryDatabase) GetCities(_ context.Context, worldID string, q1, r1, q2, r2 int) ([]*City, error) {
+	minQ, maxQ := min(q1, q2), max(q1, q2)
+	minR, maxR := min(r1, r2), max(r1, r2)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var cities []*City
+	for _, c := range db.cities {
+		if c.WorldID == worldID && c.Q >= minQ && c.Q <= maxQ && c.R >= minR && c.R <= maxR {
+			cities = append(cities, cityRow(c))
+		}
+	}
+	slices.SortFunc(cities, func(a, b *City) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return cities, nil
+}
+
+// GetPlayerCities returns all cities owned by a player, in all worlds. Only city table fields are returned.
+func (db *MemoryDatabase) GetPlayerCities(_ context.Context, playerID string) ([]*City, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var cities []*City
+	for _, c := range db.cities {
+		if c.PlayerID == playerID {
+			cities = append(cities, cityRow(c))
+		}
+	}
+	slices.SortFunc(cities, func(a, b *City) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return cities, nil
+}
+
+// CreateCity creates the city with its resources and buildings. A city that already exists is left as is,
+// while a city on a tile that is already taken is an error.
+func (db *MemoryDatabase) CreateCity(_ context.Context, c *City) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.createCity(c)
+}
+
+func (db *MemoryDatabase) createCity(c *City) error {
+	if _, ok := db.cities[c.ID]; ok {
+		return nil
+	}
+	if db.cityAt(c.WorldID, c.Q, c.R) != nil {
+		return fmt.Errorf("city creation: tile (%d, %d) is already taken", c.Q, c.R)
+	}
+	db.cities[c.ID] = cloneCity(c)
+	return nil
+}
+
+func (db *MemoryDatabase) GetMap(_ context.Context, worldID string, minQ, maxQ, minR, maxR int) ([]*MapTile, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var tiles []*MapTile
+	for _, t := range db.tiles[worldID] {
+		if t.Q >= minQ && t.Q <= maxQ && t.R >= minR && t.R <= maxR {
+			tile := *t
+			tiles = append(tiles, &tile)
+		}
+	}
+	slices.SortFunc(tiles, func(a, b *MapTile) int {
+		return cmp.Or(cmp.Compare(a.Q, b.Q), cmp.Compare(a.R, b.R))
+	})
+	return tiles, nil
+}
+
+// GetExplored returns the tiles the player explored in the world, nil if none.
+func (db *MemoryDatabase) GetExplored(_ context.Context, worldID, playerID string) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return slices.Clone(db.explored[[2]string{worldID, playerID}]), nil
+}
+
+// Explore adds the tiles to the ones the player explored in the world, and records the sightings of the cities,
+// replacing the previous sightings of the same cities.
+func (db *MemoryDatabase) Explore(_ context.Context, worldID, playerID string, tiles []byte, sightings []*City) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, c := range sightings {
+		if _, ok := db.cities[c.ID]; !ok {
+			return fmt.Errorf("explore: city %s: %w", c.ID, utils.ErrNotFound)
+		}
+	}
+
+	key := [2]string{worldID, playerID}
+	explored := db.explored[key]
+	if len(explored) < len(tiles) {
+		explored = append(explored, make([]byte, len(tiles)-len(explored))...)
+	}
+	for i, b := range tiles {
+		explored[i] |= b
+	}
+	db.explored[key] = explored
+
+	for _, c := range sightings {
+		sighting := cityRow(c)
+		seen := *c.LastSeen
+		sighting.LastSeen = &seen
+		db.sightings[[2]string{playerID, c.ID}] = sighting
+	}
+	return nil
+}
+
+// GetSightings returns the cities the player saw in the world within the bounding box defined by vertices
+// (q1, r1) and (q2, r2), as they were when last seen.
+func (db *MemoryDatabase) GetSightings(_ context.Context, worldID, playerID string, q1, r1, q2, r2 int) ([]*City, error) {
+	minQ, maxQ := min(q1, q2), max(q1, q2)
+	minR, maxR := min(r1, r2), max(r1, r2)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var cities []*City
+	for key, c := range db.sightings {
+		if key[0] == playerID && c.WorldID == worldID && c.Q >= minQ && c.Q <= maxQ && c.R >= minR && c.R <= maxR {
+			sighting := cityRow(c)
+			seen := *c.LastSeen
+			sighting.LastSeen = &seen
+			cities = append(cities, sighting)
+		}
+	}
+	slices.SortFunc(cities, func(a, b *City) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return cities, nil
+}
+
+// SettleCity places the new city on the best free spot of its world according to the placement strategy and
+// creates it.
+// If a city with the same ID already exists it is left as is, and c is set to its spot instead.
+func (db *MemoryDatabase) SettleCity(_ context.Context, c *City, placement PlacementStrategy) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if city, ok := db.cities[c.ID]; ok {
+		c.Q, c.R, c.Biome = city.Q, city.R, city.Biome
+		return nil
+	}
+
+	tiles := make([]*MapTile, 0, len(db.tiles[c.WorldID]))
+	for _, t := range db.tiles[c.WorldID] {
+		tile := *t
+		tiles = append(tiles, &tile)
+	}
+	slices.SortFunc(tiles, func(a, b *MapTile) int {
+		return cmp.Or(cmp.Compare(a.Q, b.Q), cmp.Compare(a.R, b.R))
+	})
+	cities := make([]*City, 0, len(db.cities))
+	for _, city := range db.cities {
+		if city.WorldID == c.WorldID {
+			cities = append(cities, cityRow(city))
+		}
+	}
+
+	ranked := placement.Rank(tiles, cities)
+	if len(ranked) == 0 {
+		return fmt.Errorf("settle city: %w", utils.ErrNotFound)
+	}
+	c.Q, c.R, c.Biome = ranked[0].Q, ranked[0].R, ranked[0].Biome
+	return db.createCity(c)
+}
+
+func (db *MemoryDatabase) GetMovement(_ context.Context, id string) (*Movement, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	m, ok := db.movements[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	return cloneMovement(m), nil
+}
+
+// GetCityMovements returns the movements from and towards a city, in order of arrival.
+func (db *MemoryDatabase) GetCityMovements(_ context.Context, cityID string) ([]*Movement, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var movements []*Movement
+	for _, m := range db.movements {
+		if m.CityID == cityID || m.TargetCityID == cityID {
+			movements = append(movements, cloneMovement(m))
+		}
+	}
+	slices.SortFunc(movements, func(a, b *Movement) int {
+		return cmp.Or(cmp.Compare(a.ArrivalTick, b.ArrivalTick), cmp.Compare(a.StartTick, b.StartTick), cmp.Compare(a.ID, b.ID))
+	})
+	return movements, nil
+}
+
+func (db *MemoryDatabase) GetBattleReport(_ context.Context, id string) (*BattleReport, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	b, ok := db.reports[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	report := &BattleReport{}
+	if err := json.Unmarshal(b, report); err != nil {
+		return nil, fmt.Errorf("invalid battle report: %w", err)
+	}
+	return report, nil
+}
+
+// GetBattleReports returns the latest battle reports of a player, either as attacker or defender.
+func (db *MemoryDatabase) GetBattleReports(_ context.Context, playerID string) ([]*BattleReport, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var reports []*BattleReport
+	for _, b := range db.reports {
+		report := &BattleReport{}
+		if err := json.Unmarshal(b, report); err != nil {
+			return nil, fmt.Errorf("invalid battle report: %w", err)
+		}
+		if report.AttackerID == playerID || report.DefenderID == playerID {
+			reports = append(reports, report)
+		}
+	}
+	slices.SortFunc(reports, func(a, b *BattleReport) int {
+		return cmp.Or(cmp.Compare(b.Tick, a.Tick), cmp.Compare(a.ID, b.ID))
+	})
+	if len(reports) > 100 {
+		reports = reports[:100]
+	}
+	return reports, nil
+}
+
+// GetNotifications returns the notifications of a player after the cursor, in order.
+func (db *MemoryDatabase) GetNotifications(_ context.Context, playerID string, after int64, limit int) ([]*Notification, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var notifications []*Notification
+	for _, n := range db.notifications {
+		if len(notifications) == limit {
+			break
+		}
+		if n.PlayerID == playerID && n.Seq > after {
+			notification := *n
+			notifications = append(notifications, &notification)
+		}
+	}
+	return notifications, nil
+}
+
+// GetNotificationCursor returns the cursor of the latest notification of a player.
+func (db *MemoryDatabase) GetNotificationCursor(_ context.Context, playerID string) (int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var cursor int64
+	for _, n := range db.notifications {
+		if n.PlayerID == playerID {
+			cursor = n.Seq
+		}
+	}
+	return cursor, nil
+}
+
+// AddEvent adds an event to the queue, to be processed at its tick.
+//
+// If the tick was already processed the event is scheduled for the next unprocessed tick instead, and the event
+// tick is updated accordingly. Events with an already existing key are ignored.
+func (db *MemoryDatabase) AddEvent(_ context.Context, e *Event) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.eventKeys[e.Key] {
+		return nil
+	}
+	e.Tick = max(e.Tick, db.clock.LastTick+1)
+	db.addEvent(e)
+	return nil
+}
+
+func (db *MemoryDatabase) addEvent(e *Event) {
+	event := cloneEvent(e)
+	if event.Payload == nil {
+		event.Payload = json.RawMessage("{}")
+	}
+	db.events = append(db.events, event)
+	db.eventKeys[e.Key] = true
+}
+
+func (db *MemoryDatabase) GetClock(_ context.Context) (*Clock, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	clock := db.clock
+	return &clock, nil
+}
+
+// ProcessTick hands over the events due at the tick and a copy of the state they act on (see TickState) to process,
+// and replaces the state with the result, along with the tick as the last processed tick, deleting the processed
+// events. Nothing changes if process fails.
+//
+// If the tick does not immediately follow the last processed tick ErrTickOutOfOrder is returned.
+func (db *MemoryDatabase) ProcessTick(_ context.Context, tick int64, process func(*TickState) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.clock.LastTick+1 != tick {
+		return fmt.Errorf("%w: want tick %d, got %d", ErrTickOutOfOrder, db.clock.LastTick+1, tick)
+	}
+
+	state := &TickState{
+		Tick:      tick,
+		Cities:    make(map[string]*City, len(db.cities)),
+		Movements: make(map[string]*Movement, len(db.movements)),
+		Worlds:    make(map[string]*World, len(db.worlds)),
+	}
+	for id, w := range db.worlds {
+		state.Worlds[id] = cloneWorld(w)
+	}
+	var pending []*Event
+	for _, e := range db.events {
+		if e.Tick <= tick {
+			state.Events = append(state.Events, cloneEvent(e))
+		} else {
+			pending = append(pending, e)
+		}
+	}
+	slices.SortStableFunc(state.Events, func(a, b *Event) int {
+		return cmp.Compare(a.Tick, b.Tick)
+	})
+	cityIDs, movementIDs := touched(state.Events)
+	for id, c := range db.cities {
+		state.Cities[id] = cloneCity(c)
+	}
+	// pending events make up the queues of the cities, including the ones that complete on this tick
+	for _, e := range db.pending(tick) {
+		city, ok := state.Cities[e.CityID]
+		if !ok || !slices.Contains(cityIDs, e.CityID) {
+			continue
+		}
+		if err := city.addPending(e); err != nil {
+			return fmt.Errorf("tick queues: %w", err)
+		}
+	}
+	type worldTile struct {
+		worldID string
+		coords  [2]int
+	}
+	colonized := make(map[worldTile]bool)
+	loaded := make(map[string]bool)
+	for id, m := range db.movements {
+		if m.ArrivalTick > tick && !slices.Contains(movementIDs, id) {
+			continue
+		}
+		state.Movements[id] = cloneMovement(m)
+		loaded[id] = true
+		if city, ok := db.cities[m.CityID]; ok && m.Mission == MissionColonize {
+			colonized[worldTile{city.WorldID, [2]int{m.Q, m.R}}] = true
+		}
+	}
+	for wt := range colonized {
+		if t, ok := db.tiles[wt.worldID][wt.coords]; ok {
+			tile := *t
+			state.Tiles = append(state.Tiles, &tile)
+		}
+	}
+
+	if err := process(state); err != nil {
+		return fmt.Errorf("process tick: %w", err)
+	}
+
+	// marshal everything up front, such that a failure leaves the state untouched
+	reports := make(map[string][]byte, len(state.Reports))
+	for _, report := range state.Reports {
+		b, err := json.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("battle report %s: %w", report.ID, err)
+		}
+		reports[report.ID] = b
+	}
+	notifications := make([]*Notification, 0, len(state.Notifications))
+	for _, n := range state.Notifications {
+		payload, err := json.Marshal(n.Payload)
+		if err != nil {
+			return fmt.Errorf("notification %s: %w", n.Type, err)
+		}
+		notification := *n
+		notification.Payload = json.RawMessage(payload)
+		notifications = append(notifications, &notification)
+	}
+
+	db.cities = make(map[string]*City, len(state.Cities))
+	for id, c := range state.Cities {
+		db.cities[id] = cloneCity(c)
+	}
+	for id := range loaded {
+		delete(db.movements, id)
+	}
+	for id, m := range state.Movements {
+		db.movements[id] = cloneMovement(m)
+	}
+	for id, b := range reports {
+		if _, ok := db.reports[id]; !ok {
+			db.reports[id] = b
+		}
+	}
+	db.events = pending
+	for _, e := range state.NewEvents {
+		if !db.eventKeys[e.Key] {
+			db.addEvent(e)
+		}
+	}
+	for _, n := range notifications {
+		n.Seq = int64(len(db.notifications)) + 1
+		db.notifications = append(db.notifications, n)
+	}
+	db.clock.LastTick = tick
+	return nil
+}
@@ -0,0 +1,101 @@
+package game
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+func Test_MemoryDatabaseGame(t *testing.T) {
+	// given
+	ctx := t.Context()
+	db := NewMemoryDatabase(makeMap(
+		"333",
+		"333",
+	))
+	service := &GameService{Database: db, TickDuration: time.Minute}
+	engine := &TickEngine{Database: db, TickDuration: time.Minute}
+
+	// when
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/joinworld", strings.NewReader(`{"cityName":"Capital"}`))
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+	service.JoinWorld(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("unexpected join world status code: %v, %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/cities/test-user/buildings/farm/upgrade", nil)
+	req.SetPathValue("id", "test-user")
+	req.SetPathValue("building", "farm")
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+	service.UpgradeBuilding(rec, req)
+	if rec.Code != 202 {
+		t.Fatalf("unexpected upgrade status code: %v, %s", rec.Code, rec.Body)
+	}
+
+	for tick := int64(0); tick < 100; tick++ {
+		if err := db.ProcessTick(ctx, tick, engine.Process); err != nil {
+			t.Fatalf("unexpected tick %d error: %v", tick, err)
+		}
+	}
+
+	// then
+	city, err := db.GetCity(ctx, "test-user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// placed by the spawn placement of the classic world, in the centre of the map
+	if city.Q != 1 || city.R != 1 {
+		t.Errorf("unexpected city spot: (%v, %v)", city.Q, city.R)
+	}
+	if city.Buildings.Farm != 1 || len(city.Constructions) != 0 {
+		t.Errorf("unexpected farm: level %v, constructions %v", city.Buildings.Farm, city.Constructions)
+	}
+	notifications, err := db.GetNotifications(ctx, "test-user", 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].Type != NotificationConstructionCompleted {
+		t.Errorf("unexpected notifications: %+v", notifications)
+	}
+	if err := db.ProcessTick(ctx, 0, engine.Process); !errors.Is(err, ErrTickOutOfOrder) {
+		t.Errorf("unexpected error processing an old tick: %v", err)
+	}
+}
+
+func Test_MemoryDatabaseFailedTick(t *testing.T) {
+	// given
+	ctx := t.Context()
+	db := NewMemoryDatabase(nil)
+	city := makeCity(func(c *City) { c.Resources = &Resources{Food: 100} })
+	if err := db.CreateCity(ctx, city); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// when
+	err := db.ProcessTick(ctx, 0, func(state *TickState) error {
+		state.Cities[city.ID].Resources.Food = 0
+		return errors.New("failed")
+	})
+
+	// then
+	if err == nil {
+		t.Errorf("expected the tick to fail")
+	}
+	got, err := db.GetCity(ctx, city.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Resources.Food != 100 {
+		t.Errorf("unexpected food after a failed tick: %v", got.Resources.Food)
+	}
+	if clock, _ := db.GetClock(ctx); clock.LastTick != -1 {
+		t.Errorf("unexpected last tick after a failed tick: %v", clock.LastTick)
+	}
+}
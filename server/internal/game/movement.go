@@ -0,0 +1,501 @@
+package game
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/game/hex"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+const (
+	// MissionAttack sends units against a city of another player
+	MissionAttack = "attack"
+	// MissionReinforce relocates units to another city of the same player
+	MissionReinforce = "reinforce"
+	// MissionColonize founds a new city on a free settleable tile with a settler
+	MissionColonize = "colonize"
+)
+
+// Movement defines a group of units travelling between tiles of the world.
+//
+// A movement leaves its origin city at StartTick and reaches the target tile at ArrivalTick, where it
+// carries out its mission. Movements that do not end at the target turn around and travel back home,
+// taking as long to return as they travelled, along with the Resources they plundered.
+type Movement struct {
+	ID           string         `json:"id"`
+	CityID       string         `json:"cityID"`
+	PlayerID     string         `json:"playerID"`
+	Mission      string         `json:"mission"`
+	Q            int            `json:"q"`
+	R            int            `json:"r"`
+	TargetCityID string         `json:"targetCityID,omitempty"`
+	Units        map[string]int `json:"units,omitempty"`
+	Resources    Resources      `json:"resources,omitzero"`
+	CityName     string         `json:"cityName,omitempty"`
+	StartTick    int64          `json:"startTick"`
+	ArrivalTick  int64          `json:"arrivalTick"`
+	Returning    bool           `json:"returning"`
+	ArrivesAt    time.Time      `json:"arrivesAt,omitzero"`
+}
+
+// turnBack sends the movement back to its origin city, taking as long as it travelled so far.
+func (m *Movement) turnBack(tick int64) {
+	m.ArrivalTick = tick + max(1, tick-m.StartTick)
+	m.StartTick = tick
+	m.Returning = true
+}
+
+// movementOrderedPayload is the payload of an EventMovementOrdered.
+type movementOrderedPayload struct {
+	Mission  string         `json:"mission"`
+	Q        int            `json:"q"`
+	R        int            `json:"r"`
+	Units    map[string]int `json:"units"`
+	CityName string         `json:"cityName,omitempty"`
+}
+
+// movementRecalledPayload is the payload of an EventMovementRecalled.
+type movementRecalledPayload struct {
+	MovementID string `json:"movementID"`
+}
+
+// travelTime returns the time it takes for a group of units to travel the distance, i.e., at the
+// speed of its slowest unit.
+func travelTime(distance int, units map[string]int) time.Duration {
+	speed := 0
+	for unit := range units {
+		spec, ok := unitSpecs[unit]
+		if !ok {
+			continue
+		}
+		if speed == 0 || spec.Speed < speed {
+			speed = spec.Speed
+		}
+	}
+	if speed == 0 {
+		return 0
+	}
+	return time.Duration(distance) * time.Hour / time.Duration(speed)
+}
+
+// validMovement checks if the city can send the units on the mission, returning the reason if not.
+//
+// The target is the city at the target tile of the world of the city, if any.
+func validMovement(world *World, c *City, target *City, p *movementOrderedPayload) string {
+	if !world.contains(p.Q, p.R) {
+		return "target is outside of the world"
+	}
+	if p.Q == c.Q && p.R == c.R {
+		return "target is the city itself"
+	}
+	switch p.Mission {
+	case MissionAttack:
+		if target == nil || target.PlayerID == c.PlayerID {
+			return "attack target must be a city of another player"
+		}
+	case MissionReinforce:
+		if target == nil || target.PlayerID != c.PlayerID {
+			return "reinforce target must be one of your cities"
+		}
+	case MissionColonize:
+		if target != nil {
+			return "colonize target must be a free tile"
+		}
+		if p.CityName == "" {
+			return "city name is required"
+		}
+		settlers := 0
+		for unit, count := range p.Units {
+			if spec, ok := unitSpecs[unit]; ok && spec.Settler {
+				settlers += count
+			}
+		}
+		if settlers == 0 {
+			return "colonize requires a settler"
+		}
+	default:
+		return fmt.Sprintf("unknown mission: %s", p.Mission)
+	}
+	if len(p.Units) == 0 {
+		return "no units to send"
+	}
+	for unit, count := range p.Units {
+		if _, ok := unitSpecs[unit]; !ok {
+			return fmt.Sprintf("unknown unit: %s", unit)
+		}
+		if count <= 0 {
+			return "unit counts must be positive"
+		}
+		if c.Units[unit] < count {
+			return fmt.Sprintf("not enough %s", unit)
+		}
+	}
+	return ""
+}
+
+// addUnits adds the units to the army of the city.
+func (c *City) addUnits(units map[string]int) {
+	if c.Units == nil {
+		c.Units = make(map[string]int)
+	}
+	for unit, count := range units {
+		c.Units[unit] += count
+	}
+}
+
+// removeUnits removes the units from the army of the city, it must be checked with validMovement beforehand.
+func (c *City) removeUnits(units map[string]int) {
+	for unit, count := range units {
+		c.Units[unit] -= count
+		if c.Units[unit] <= 0 {
+			delete(c.Units, unit)
+		}
+	}
+}
+
+type SendUnitsRequest struct {
+	Mission string         `json:"mission"`
+	Q       int            `json:"q"`
+	R       int            `json:"r"`
+	Units   map[string]int `json:"units"`
+	// CityName is the name of the city founded by a colonization mission
+	CityName string `json:"cityName,omitempty"`
+}
+
+type SendUnitsResponse struct {
+	Mission       string         `json:"mission"`
+	Q             int            `json:"q"`
+	R             int            `json:"r"`
+	Units         map[string]int `json:"units"`
+	Distance      int            `json:"distance"`
+	TravelSeconds int64          `json:"travelSeconds"`
+}
+
+// SendUnits orders a group of units of a city to travel to a target tile on a mission.
+//
+// The endpoint does a non-binding validation and submits the order to the event queue, the units only
+// leave the city once the order is processed, and the arrival is computed from that moment on. The target must
+// be a tile the player explored, and nothing else is told about the tiles they did not, not to reveal what is
+// behind the fog of war.
+func (g *GameService) SendUnits(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
+	defer func() {
+		_ = bodyReader.Close()
+	}()
+
+	req := SendUnitsRequest{}
+	if err := json.NewDecoder(bodyReader).Decode(&req); err != nil {
+		utils.WithError(w, fmt.Errorf("%w: invalid request body: %w", utils.ErrUserError, err))
+		return
+	}
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	city, err := g.Database.GetCity(r.Context(), id)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if !principal.Owns(city.PlayerID) {
+		utils.WithError(w, utils.ErrForbidden)
+		return
+	}
+
+	world, err := g.Database.GetWorld(r.Context(), city.WorldID)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("failed to get world: %w", err))
+		return
+	}
+
+	if world.contains(req.Q, req.R) {
+		v, err := g.playerVision(r.Context(), world, principal)
+		if err != nil {
+			utils.WithError(w, err)
+			return
+		}
+		known, err := g.exploreVision(r.Context(), v, principal.UserID, nil, nil)
+		if err != nil {
+			utils.WithError(w, err)
+			return
+		}
+		if !v.all && !explored(world, known, req.Q, req.R) {
+			utils.WithError(w, fmt.Errorf("%w: target is not explored", utils.ErrUserError))
+			return
+		}
+	}
+
+	var target *City
+	targets, err := g.Database.GetCities(r.Context(), world.ID, req.Q, req.R, req.Q, req.R)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("failed to get target city: %w", err))
+		return
+	}
+	if len(targets) > 0 {
+		target = targets[0]
+	}
+
+	payload := movementOrderedPayload(req)
+	if errReason := validMovement(world, city, target, &payload); errReason != "" {
+		utils.WithError(w, fmt.Errorf("%w: %s", utils.ErrUserError, errReason))
+		return
+	}
+	if req.Mission == MissionColonize {
+		tiles, err := g.Database.GetMap(r.Context(), world.ID, req.Q, req.Q, req.R, req.R)
+		if err != nil {
+			utils.WithError(w, fmt.Errorf("failed to get target tile: %w", err))
+			return
+		}
+		if len(tiles) == 0 || !tiles[0].Settleable {
+			utils.WithError(w, fmt.Errorf("%w: colonize target must be a settleable tile", utils.ErrUserError))
+			return
+		}
+	}
+
+	event, err := newEvent(EventMovementOrdered, city.ID, payload)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if err := g.Database.AddEvent(r.Context(), event); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to order movement: %w", err))
+		return
+	}
+
+	distance := hex.Distance(hex.Hex{Q: city.Q, R: city.R}, hex.Hex{Q: req.Q, R: req.R})
+	rsp := SendUnitsResponse{
+		Mission:       req.Mission,
+		Q:             req.Q,
+		R:             req.R,
+		Units:         req.Units,
+		Distance:      distance,
+		TravelSeconds: int64(world.duration(travelTime(distance, req.Units)) / time.Second),
+	}
+	utils.WithDefaultAcceptedHeaders(w)
+	if err := json.NewEncoder(w).Encode(rsp); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
+
+type GetMovementsResponse struct {
+	Incoming []*Movement `json:"incoming"`
+	Outgoing []*Movement `json:"outgoing"`
+}
+
+// GetMovements lists the movements from and towards a city.
+//
+// Outgoing movements are the ones travelling from the city to their target, and incoming movements are the
+// ones targeting the city plus the units of the city returning home. The units of incoming movements of other
+// players are not disclosed.
+func (g *GameService) GetMovements(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	city, err := g.Database.GetCity(r.Context(), id)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if !principal.Owns(city.PlayerID) {
+		utils.WithError(w, utils.ErrForbidden)
+		return
+	}
+
+	movements, err := g.Database.GetCityMovements(r.Context(), id)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("failed to get movements: %w", err))
+		return
+	}
+
+	rsp := GetMovementsResponse{Incoming: []*Movement{}, Outgoing: []*Movement{}}
+	if len(movements) > 0 {
+		clock, err := g.Database.GetClock(r.Context())
+		if err != nil {
+			utils.WithError(w, fmt.Errorf("failed to get game clock: %w", err))
+			return
+		}
+		for _, m := range movements {
+			m.ArrivesAt = clock.processedAt(m.ArrivalTick, g.TickDuration)
+			switch {
+			case m.CityID == id && !m.Returning:
+				rsp.Outgoing = append(rsp.Outgoing, m)
+			case m.CityID == id && m.Returning:
+				rsp.Incoming = append(rsp.Incoming, m)
+			case m.TargetCityID == id && !m.Returning:
+				if !principal.Owns(m.PlayerID) {
+					m.Units = nil
+				}
+				rsp.Incoming = append(rsp.Incoming, m)
+			}
+		}
+	}
+
+	utils.WithDefaultOKHeaders(w)
+	if err := json.NewEncoder(w).Encode(rsp); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode movements: %w", err))
+		return
+	}
+}
+
+type RecallMovementResponse struct {
+	ID string `json:"id"`
+}
+
+// RecallMovement orders a movement to turn back home before it reaches its target.
+func (g *GameService) RecallMovement(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	movement, err := g.Database.GetMovement(r.Context(), id)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if !principal.Owns(movement.PlayerID) {
+		utils.WithError(w, utils.ErrForbidden)
+		return
+	}
+	if movement.Returning {
+		utils.WithError(w, fmt.Errorf("%w: movement is already returning", utils.ErrUserError))
+		return
+	}
+
+	event, err := newEvent(EventMovementRecalled, movement.CityID, movementRecalledPayload{MovementID: movement.ID})
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if err := g.Database.AddEvent(r.Context(), event); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to recall movement: %w", err))
+		return
+	}
+
+	utils.WithDefaultAcceptedHeaders(w)
+	if err := json.NewEncoder(w).Encode(RecallMovementResponse{ID: movement.ID}); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
+
+// processMovementOrdered takes the units out of the city and sets them on their way to the target. The
+// movement is identified by the key of the order.
+func (e *TickEngine) processMovementOrdered(state *TickState, event *Event) error {
+	city, ok := state.Cities[event.CityID]
+	if !ok {
+		return nil
+	}
+	var p movementOrderedPayload
+	if err := json.Unmarshal(event.Payload, &p); err != nil {
+		log.Printf("skipping movement order %s: invalid payload: %v", event.Key, err)
+		return nil
+	}
+	world := state.world(city.WorldID)
+	target := state.cityAt(city.WorldID, p.Q, p.R)
+	if validMovement(world, city, target, &p) != "" {
+		return nil
+	}
+
+	city.removeUnits(p.Units)
+	distance := hex.Distance(hex.Hex{Q: city.Q, R: city.R}, hex.Hex{Q: p.Q, R: p.R})
+	movement := &Movement{
+		ID:          event.Key,
+		CityID:      city.ID,
+		PlayerID:    city.PlayerID,
+		Mission:     p.Mission,
+		Q:           p.Q,
+		R:           p.R,
+		Units:       maps.Clone(p.Units),
+		CityName:    p.CityName,
+		StartTick:   state.Tick,
+		ArrivalTick: state.Tick + e.ticks(world.duration(travelTime(distance, p.Units))),
+	}
+	if target != nil {
+		movement.TargetCityID = target.ID
+	}
+	state.addMovement(movement)
+	if movement.Mission == MissionAttack {
+		state.Notify(target.PlayerID, NotificationIncomingAttack, target.ID, incomingAttackPayload{
+			MovementID:   movement.ID,
+			OriginCityID: city.ID,
+			ArrivalTick:  movement.ArrivalTick,
+		})
+	}
+	return nil
+}
+
+// processMovementRecalled turns a movement back home, if it did not arrive yet.
+func (e *TickEngine) processMovementRecalled(state *TickState, event *Event) error {
+	var p movementRecalledPayload
+	if err := json.Unmarshal(event.Payload, &p); err != nil {
+		log.Printf("skipping movement recall %s: invalid payload: %v", event.Key, err)
+		return nil
+	}
+	movement, ok := state.Movements[p.MovementID]
+	if !ok || movement.Returning || movement.CityID != event.CityID {
+		return nil
+	}
+	movement.turnBack(state.Tick)
+	return nil
+}
+
+// move carries out the missions of the movements arriving on the tick, in order of departure.
+func (e *TickEngine) move(state *TickState) {
+	var arrivals []*Movement
+	for _, m := range state.Movements {
+		if m.ArrivalTick <= state.Tick {
+			arrivals = append(arrivals, m)
+		}
+	}
+	slices.SortFunc(arrivals, func(a, b *Movement) int {
+		return cmp.Or(cmp.Compare(a.ArrivalTick, b.ArrivalTick), cmp.Compare(a.StartTick, b.StartTick), cmp.Compare(a.ID, b.ID))
+	})
+
+	for _, m := range arrivals {
+		if m.Returning {
+			// units whose home no longer exists, or was conquered meanwhile, are lost
+			if home, ok := state.Cities[m.CityID]; ok && home.PlayerID == m.PlayerID {
+				home.addUnits(m.Units)
+				home.Resources.add(&m.Resources)
+			}
+			delete(state.Movements, m.ID)
+			continue
+		}
+
+		target, ok := state.Cities[m.TargetCityID]
+		switch {
+		case ok && m.Mission == MissionReinforce && target.PlayerID == m.PlayerID:
+			target.addUnits(m.Units)
+			delete(state.Movements, m.ID)
+		case ok && m.Mission == MissionAttack && target.PlayerID != m.PlayerID:
+			e.battle(state, m, target)
+		case m.Mission == MissionColonize && colonize(state, m):
+			delete(state.Movements, m.ID)
+		default:
+			m.turnBack(state.Tick)
+		}
+	}
+}
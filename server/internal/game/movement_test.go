@@ -0,0 +1,307 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+func Test_SendUnits(t *testing.T) {
+	enemy := &City{ID: "enemy", PlayerID: "another-user", Q: 8, R: 5}
+	testcases := []struct {
+		name       string
+		body       string
+		city       *City
+		targets    []*City
+		wantEvent  bool
+		wantStatus int
+		wantBody   []byte
+	}{
+		{
+			name:      "success",
+			body:      `{"mission":"attack","q":8,"r":5,"units":{"spearman":10,"horseman":2}}`,
+			city:      makeCity(func(c *City) { c.Units = map[string]int{"spearman": 10, "horseman": 5} }),
+			targets:   []*City{enemy},
+			wantEvent: true,
+			// 3 tiles at the spearman speed of 6 tiles per hour
+			wantStatus: 202,
+			wantBody: unsafeToResponseBody(SendUnitsResponse{
+				Mission: "attack", Q: 8, R: 5, Units: map[string]int{"spearman": 10, "horseman": 2},
+				Distance: 3, TravelSeconds: 1800,
+			}),
+		},
+		{
+			name:       "forbidden",
+			body:       `{"mission":"attack","q":8,"r":5,"units":{"spearman":10}}`,
+			city:       makeCity(func(c *City) { c.PlayerID = "another-user" }),
+			wantStatus: 403,
+			wantBody:   []byte("forbidden\n"),
+		},
+		{
+			name:       "not enough units",
+			body:       `{"mission":"attack","q":8,"r":5,"units":{"spearman":11}}`,
+			city:       makeCity(func(c *City) { c.Units = map[string]int{"spearman": 10} }),
+			targets:    []*City{enemy},
+			wantStatus: 400,
+			wantBody:   []byte("user error: not enough spearman\n"),
+		},
+		{
+			name:       "reinforce another player",
+			body:       `{"mission":"reinforce","q":8,"r":5,"units":{"spearman":1}}`,
+			city:       makeCity(func(c *City) { c.Units = map[string]int{"spearman": 10} }),
+			targets:    []*City{enemy},
+			wantStatus: 400,
+			wantBody:   []byte("user error: reinforce target must be one of your cities\n"),
+		},
+		{
+			name:       "attack an empty tile",
+			body:       `{"mission":"attack","q":7,"r":5,"units":{"spearman":1}}`,
+			city:       makeCity(func(c *City) { c.Units = map[string]int{"spearman": 10} }),
+			wantStatus: 400,
+			wantBody:   []byte("user error: attack target must be a city of another player\n"),
+		},
+		{
+			name:       "outside of the world",
+			body:       `{"mission":"attack","q":-1,"r":5,"units":{"spearman":1}}`,
+			city:       makeCity(func(c *City) { c.Units = map[string]int{"spearman": 10} }),
+			wantStatus: 400,
+			wantBody:   []byte("user error: target is outside of the world\n"),
+		},
+		{
+			name:       "target behind the fog of war",
+			body:       `{"mission":"attack","q":9,"r":9,"units":{"spearman":1}}`,
+			city:       makeCity(func(c *City) { c.Units = map[string]int{"spearman": 10} }),
+			targets:    []*City{{ID: "hidden", PlayerID: "another-user", Q: 9, R: 9}},
+			wantStatus: 400,
+			wantBody:   []byte("user error: target is not explored\n"),
+		},
+		{
+			name:       "empty tile behind the fog of war",
+			body:       `{"mission":"attack","q":9,"r":9,"units":{"spearman":1}}`,
+			city:       makeCity(func(c *City) { c.Units = map[string]int{"spearman": 10} }),
+			wantStatus: 400,
+			wantBody:   []byte("user error: target is not explored\n"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			var gotEvent *Event
+			// given
+			mockDB := &mockDatabase{
+				GetCitiesFunc: func(q1, r1, q2, r2 int) ([]*City, error) {
+					return testcase.targets, nil
+				},
+				AddEventFunc: func(e *Event) error {
+					gotEvent = e
+					return nil
+				},
+				ExploreFunc: func(playerID string, tiles []byte, sightings []*City) error {
+					return nil
+				},
+			}
+			testcase.city.ID, testcase.city.WorldID = "123", DefaultWorldID
+			// the player sees the tiles around the city, nothing else is explored
+			mockVision(mockDB, nil, testcase.city)
+			service := &GameService{Database: mockDB}
+
+			// when
+			req := httptest.NewRequest("POST", "/api/cities/123/movements", strings.NewReader(testcase.body))
+			req.SetPathValue("id", "123")
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+			service.SendUnits(rec, req)
+
+			// then
+			if testcase.wantEvent != (gotEvent != nil) {
+				t.Errorf("unexpected event: want %v, got %+v", testcase.wantEvent, gotEvent)
+			}
+			if gotEvent != nil && (gotEvent.Type != EventMovementOrdered || gotEvent.Tick != 0) {
+				t.Errorf("unexpected event: %+v", gotEvent)
+			}
+			if testcase.wantStatus != rec.Code {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+			if diff := cmp.Diff(testcase.wantBody, rec.Body.Bytes()); diff != "" {
+				t.Errorf("unexpected body diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_GetMovements(t *testing.T) {
+	// given
+	clock := &Clock{StartedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), LastTick: 5}
+	movements := []*Movement{
+		{ID: "a", CityID: "123", PlayerID: "test-user", TargetCityID: "456", Units: map[string]int{"spearman": 1}, ArrivalTick: 10},
+		{ID: "b", CityID: "456", PlayerID: "another-user", TargetCityID: "123", Units: map[string]int{"spearman": 2}, ArrivalTick: 11},
+		{ID: "c", CityID: "123", PlayerID: "test-user", TargetCityID: "456", Units: map[string]int{"spearman": 3}, ArrivalTick: 12, Returning: true},
+	}
+	mockDB := &mockDatabase{
+		GetCityFunc: func(id string) (*City, error) {
+			return makeCity(func(c *City) { c.ID = id }), nil
+		},
+		GetCityMovementsFunc: func(cityID string) ([]*Movement, error) {
+			return movements, nil
+		},
+		GetClockFunc: func() (*Clock, error) {
+			return clock, nil
+		},
+	}
+	service := &GameService{Database: mockDB, TickDuration: time.Minute}
+	rec := httptest.NewRecorder()
+
+	// when
+	req := httptest.NewRequest("GET", "/api/cities/123/movements", http.NoBody)
+	req.SetPathValue("id", "123")
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+	service.GetMovements(rec, req)
+
+	// then
+	want := GetMovementsResponse{
+		Outgoing: []*Movement{
+			{ID: "a", CityID: "123", PlayerID: "test-user", TargetCityID: "456", Units: map[string]int{"spearman": 1}, ArrivalTick: 10, ArrivesAt: clock.StartedAt.Add(11 * time.Minute)},
+		},
+		Incoming: []*Movement{
+			{ID: "b", CityID: "456", PlayerID: "another-user", TargetCityID: "123", ArrivalTick: 11, ArrivesAt: clock.StartedAt.Add(12 * time.Minute)},
+			{ID: "c", CityID: "123", PlayerID: "test-user", TargetCityID: "456", Units: map[string]int{"spearman": 3}, ArrivalTick: 12, Returning: true, ArrivesAt: clock.StartedAt.Add(13 * time.Minute)},
+		},
+	}
+	if rec.Code != 200 {
+		t.Errorf("unexpected status code: want 200, got %v", rec.Code)
+	}
+	if diff := cmp.Diff(unsafeToResponseBody(want), rec.Body.Bytes()); diff != "" {
+		t.Errorf("unexpected body diff (-want, +got): %v", diff)
+	}
+}
+
+func Test_ProcessMovements(t *testing.T) {
+	order := func(key, mission string, q, r int, units map[string]int) *Event {
+		payload, _ := json.Marshal(movementOrderedPayload{Mission: mission, Q: q, R: r, Units: units})
+		return &Event{Key: key, Tick: 10, Type: EventMovementOrdered, CityID: "home", Payload: payload}
+	}
+	recall := func(key, movementID string) *Event {
+		payload, _ := json.Marshal(movementRecalledPayload{MovementID: movementID})
+		return &Event{Key: key, Tick: 10, Type: EventMovementRecalled, CityID: "home", Payload: payload}
+	}
+	cities := func(homeUnits, colonyUnits map[string]int) map[string]*City {
+		return map[string]*City{
+			"home":   {ID: "home", PlayerID: "player", Q: 0, R: 0, Resources: &Resources{}, Units: homeUnits},
+			"colony": {ID: "colony", PlayerID: "player", Q: 6, R: 0, Resources: &Resources{}, Units: colonyUnits},
+			"enemy":  {ID: "enemy", PlayerID: "enemy", Q: 0, R: 12, Resources: &Resources{}},
+		}
+	}
+
+	testcases := []struct {
+		name          string
+		events        []*Event
+		cities        map[string]*City
+		movements     map[string]*Movement
+		wantCities    map[string]*City
+		wantMovements map[string]*Movement
+	}{
+		{
+			name:   "units leave the city",
+			events: []*Event{order("a", MissionAttack, 0, 12, map[string]int{"spearman": 5, "horseman": 5})},
+			cities: cities(map[string]int{"spearman": 5, "horseman": 10}, nil),
+			// 12 tiles at the spearman speed of 6 tiles per hour, in ticks of one minute
+			wantCities: cities(map[string]int{"horseman": 5}, nil),
+			wantMovements: map[string]*Movement{
+				"a": {
+					ID: "a", CityID: "home", PlayerID: "player", Mission: MissionAttack, Q: 0, R: 12, TargetCityID: "enemy",
+					Units: map[string]int{"spearman": 5, "horseman": 5}, StartTick: 10, ArrivalTick: 130,
+				},
+			},
+		},
+		{
+			name:       "orders without the units are skipped",
+			events:     []*Event{order("a", MissionAttack, 0, 12, map[string]int{"spearman": 6})},
+			cities:     cities(map[string]int{"spearman": 5}, nil),
+			wantCities: cities(map[string]int{"spearman": 5}, nil),
+		},
+		{
+			name:   "recalled movements return home",
+			events: []*Event{recall("b", "a")},
+			cities: cities(nil, nil),
+			movements: map[string]*Movement{
+				"a": {ID: "a", CityID: "home", PlayerID: "player", Mission: MissionAttack, TargetCityID: "enemy", StartTick: 4, ArrivalTick: 30},
+			},
+			wantCities: cities(nil, nil),
+			wantMovements: map[string]*Movement{
+				"a": {ID: "a", CityID: "home", PlayerID: "player", Mission: MissionAttack, TargetCityID: "enemy", StartTick: 10, ArrivalTick: 16, Returning: true},
+			},
+		},
+		{
+			name:   "reinforcements join the target city",
+			cities: cities(nil, map[string]int{"spearman": 1}),
+			movements: map[string]*Movement{
+				"a": {ID: "a", CityID: "home", PlayerID: "player", Mission: MissionReinforce, TargetCityID: "colony", Units: map[string]int{"spearman": 2}, StartTick: 4, ArrivalTick: 10},
+			},
+			wantCities:    cities(nil, map[string]int{"spearman": 3}),
+			wantMovements: map[string]*Movement{},
+		},
+		{
+			name:   "returning units join their home city",
+			cities: cities(map[string]int{"spearman": 1}, nil),
+			movements: map[string]*Movement{
+				"a": {ID: "a", CityID: "home", PlayerID: "player", Mission: MissionAttack, TargetCityID: "enemy", Units: map[string]int{"spearman": 2}, StartTick: 4, ArrivalTick: 10, Returning: true},
+			},
+			wantCities:    cities(map[string]int{"spearman": 3}, nil),
+			wantMovements: map[string]*Movement{},
+		},
+		{
+			name:   "returning units of a conquered home are lost",
+			cities: cities(map[string]int{"spearman": 1}, nil),
+			movements: map[string]*Movement{
+				"a": {
+					ID: "a", CityID: "home", PlayerID: "former-owner", Mission: MissionAttack, TargetCityID: "enemy",
+					Units: map[string]int{"spearman": 2}, Resources: Resources{Food: 100}, StartTick: 4, ArrivalTick: 10, Returning: true,
+				},
+			},
+			wantCities:    cities(map[string]int{"spearman": 1}, nil),
+			wantMovements: map[string]*Movement{},
+		},
+		{
+			name:   "movements still on their way are left untouched",
+			cities: cities(nil, nil),
+			movements: map[string]*Movement{
+				"a": {ID: "a", CityID: "home", PlayerID: "player", Mission: MissionReinforce, TargetCityID: "colony", StartTick: 4, ArrivalTick: 11},
+			},
+			wantCities: cities(nil, nil),
+			wantMovements: map[string]*Movement{
+				"a": {ID: "a", CityID: "home", PlayerID: "player", Mission: MissionReinforce, TargetCityID: "colony", StartTick: 4, ArrivalTick: 11},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			engine := &TickEngine{TickDuration: time.Minute}
+			state := &TickState{Tick: 10, Events: testcase.events, Cities: testcase.cities, Movements: testcase.movements}
+
+			// when
+			for _, event := range state.Events {
+				if err := engine.processEvent(state, event); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			engine.move(state)
+
+			// then
+			if diff := cmp.Diff(testcase.wantCities, state.Cities); diff != "" {
+				t.Errorf("unexpected cities diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantMovements, state.Movements); diff != "" {
+				t.Errorf("unexpected movements diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+const (
+	// NotificationConstructionCompleted is sent to the owner of a city when a building upgrade completes
+	NotificationConstructionCompleted = "construction_completed"
+	// NotificationUnitsTrained is sent to the owner of a city when a training order completes
+	NotificationUnitsTrained = "units_trained"
+	// NotificationIncomingAttack is sent to the owner of a city when an attack against it sets off
+	NotificationIncomingAttack = "incoming_attack"
+	// NotificationBattleReport is sent to both the attacker and the defender of a battle
+	NotificationBattleReport = "battle_report"
+	// NotificationCityFounded is sent to the owner of a city founded by a colonization
+	NotificationCityFounded = "city_founded"
+
+	// streamEventResources is the stream event with the resource changes of a city during a tick
+	streamEventResources = "resources"
+	// streamBatchSize is the maximum number of notifications read at once by a stream
+	streamBatchSize = 100
+	// streamKeepAlive is the interval of the keep-alive comments of a stream, such that idle
+	// connections are not dropped by proxies
+	streamKeepAlive = 15 * time.Second
+)
+
+// Notification defines a message to a player, generated while processing a tick.
+//
+// Notifications are persisted along with the tick, and the Seq is the cursor of a player stream: a
+// client that reconnects with the last seen Seq receives all the notifications it missed.
+type Notification struct {
+	Seq      int64  `json:"seq"`
+	PlayerID string `json:"-"`
+	Tick     int64  `json:"tick"`
+	Type     string `json:"type"`
+	CityID   string `json:"cityID,omitempty"`
+	Payload  any    `json:"payload,omitempty"`
+}
+
+type incomingAttackPayload struct {
+	MovementID   string `json:"movementID"`
+	OriginCityID string `json:"originCityID"`
+	ArrivalTick  int64  `json:"arrivalTick"`
+}
+
+type battleReportPayload struct {
+	ReportID    string `json:"reportID"`
+	AttackerWon bool   `json:"attackerWon"`
+	Conquered   bool   `json:"conquered"`
+}
+
+type cityFoundedPayload struct {
+	CityID string `json:"cityID"`
+	Name   string `json:"name"`
+}
+
+// ResourceUpdate defines the resources of a city at the end of a tick, and their change during the tick.
+type ResourceUpdate struct {
+	CityID    string    `json:"cityID"`
+	Tick      int64     `json:"tick"`
+	Resources Resources `json:"resources"`
+	Delta     Resources `json:"delta"`
+}
+
+// TickResult defines the live updates of a processed tick.
+//
+// Unlike notifications, resource updates are not persisted, since a client can always fetch the latest
+// resources of a city, so they are lost if no stream is listening when the tick is processed. They are also
+// only sent to the streams of the instance that processed the tick, while the other instances are only told
+// which players to look up the notifications of.
+type TickResult struct {
+	Tick int64 `json:"tick"`
+	// Resources are the resource updates of the cities that changed during the tick, by player ID
+	Resources map[string][]*ResourceUpdate `json:"-"`
+	// Notified are the players with notifications generated during the tick, nil if not known, e.g., for the
+	// ticks of other instances with too many players to tell
+	Notified map[string]bool `json:"notified"`
+}
+
+// notifies returns whether the player may have notifications generated during the tick.
+func (r *TickResult) notifies(playerID string) bool {
+	return r.Notified == nil || r.Notified[playerID]
+}
+
+// notifiedPlayers returns the players with notifications generated during the tick.
+func notifiedPlayers(state *TickState) map[string]bool {
+	notified := make(map[string]bool)
+	for _, n := range state.Notifications {
+		notified[n.PlayerID] = true
+	}
+	return notified
+}
+
+// tickResult returns the resource updates of the cities whose resources changed since before the tick, and the
+// players notified during the tick.
+func tickResult(state *TickState, before map[string]Resources) *TickResult {
+	result := &TickResult{
+		Tick:      state.Tick,
+		Resources: make(map[string][]*ResourceUpdate),
+		Notified:  notifiedPlayers(state),
+	}
+	for id, city := range state.Cities {
+		if city.Resources == nil {
+			continue
+		}
+		prev := before[id]
+		if prev == *city.Resources {
+			continue
+		}
+		result.Resources[city.PlayerID] = append(result.Resources[city.PlayerID], &ResourceUpdate{
+			CityID:    id,
+			Tick:      state.Tick,
+			Resources: *city.Resources,
+			Delta: Resources{
+				Food:       city.Resources.Food - prev.Food,
+				Sticks:     city.Resources.Sticks - prev.Sticks,
+				Stones:     city.Resources.Stones - prev.Stones,
+				Gems:       city.Resources.Gems - prev.Gems,
+				Population: city.Resources.Population - prev.Population,
+				Faith:      city.Resources.Faith - prev.Faith,
+			},
+		})
+	}
+	return result
+}
+
+// Broker fans out the results of the processed ticks to the open streams.
+//
+// Each subscriber holds at most one pending result, results are dropped for subscribers that are lagging
+// behind, which only loses resource updates since the notifications are read from the database.
+type Broker struct {
+	mu          sync.Mutex
+	closed      bool
+	subscribers map[chan *TickResult]struct{}
+}
+
+// Subscribe returns a channel with the results of the processed ticks, and a function to unsubscribe.
+// The channel is closed once the broker is closed.
+func (b *Broker) Subscribe() (<-chan *TickResult, func()) {
+	ch := make(chan *TickResult, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan *TickResult]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close closes the channels of all subscribers, e.g., to end all streams on shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Publish sends the result to all subscribers, without blocking.
+func (b *Broker) Publish(result *TickResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- result:
+		default:
+		}
+	}
+}
+
+// streamCursor returns the last seen notification of the stream request, either from the Last-Event-ID
+// header set by reconnecting clients, or the cursor query parameter, and -1 if there is none.
+func streamCursor(r *http.Request) (int64, error) {
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = r.URL.Query().Get("cursor")
+	}
+	if cursor == "" {
+		return -1, nil
+	}
+	return strconv.ParseInt(cursor, 10, 64)
+}
+
+// writeStreamEvent writes a server-sent event, with the id only set if it is not empty.
+func writeStreamEvent(w http.ResponseWriter, id, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
+
+// Stream pushes the notifications and resource updates of the player as server-sent events.
+//
+// Notifications are sent with their Seq as the event ID, so that a reconnecting client resumes right after
+// the last notification it has seen with the Last-Event-ID header (or the cursor query parameter). Without a
+// cursor the stream starts with the notifications generated from then on. They are looked up on the ticks that
+// notified the player, and along with every keep-alive in case the wake-up of a tick was missed.
+//
+// Browsers cannot set the Authorization header of a stream, so it can also be opened with a stream token (see
+// UserService.StreamToken) in the token query parameter, which is only checked when the stream is opened.
+func (g *GameService) Stream(w http.ResponseWriter, r *http.Request) {
+	cursor, err := streamCursor(r)
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("%w: invalid cursor: %w", utils.ErrUserError, err))
+		return
+	}
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok || g.Broker == nil {
+		utils.WithError(w, errors.New("streaming is not supported"))
+		return
+	}
+
+	if cursor < 0 {
+		cursor, err = g.Database.GetNotificationCursor(r.Context(), principal.UserID)
+		if err != nil {
+			utils.WithError(w, fmt.Errorf("failed to get notification cursor: %w", err))
+			return
+		}
+	}
+
+	// subscribe before reading the notifications, or the ones of a tick processed in between would be missed
+	results, unsubscribe := g.Broker.Subscribe()
+	defer unsubscribe()
+
+	sendNotifications := func() error {
+		for {
+			notifications, err := g.Database.GetNotifications(r.Context(), principal.UserID, cursor, streamBatchSize)
+			if err != nil {
+				return fmt.Errorf("failed to get notifications: %w", err)
+			}
+			for _, n := range notifications {
+				if err := writeStreamEvent(w, strconv.FormatInt(n.Seq, 10), n.Type, n); err != nil {
+					return err
+				}
+				cursor = n.Seq
+			}
+			if len(notifications) < streamBatchSize {
+				return nil
+			}
+		}
+	}
+
+	utils.WithDefaultEventStreamHeaders(w)
+	if err := sendNotifications(); err != nil {
+		log.Printf("stream %s: %v", principal.UserID, err)
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	// the last tick the notifications were looked up for, since the instance that processed a tick also
+	// receives its wake-up for the other instances
+	var notifiedTick int64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
+			for _, update := range result.Resources[principal.UserID] {
+				if err := writeStreamEvent(w, "", streamEventResources, update); err != nil {
+					log.Printf("stream %s: %v", principal.UserID, err)
+					return
+				}
+			}
+			if result.Tick <= notifiedTick || !result.notifies(principal.UserID) {
+				break
+			}
+			notifiedTick = result.Tick
+			if err := sendNotifications(); err != nil {
+				log.Printf("stream %s: %v", principal.UserID, err)
+				return
+			}
+		case <-keepAlive.C:
+			if err := sendNotifications(); err != nil {
+				log.Printf("stream %s: %v", principal.UserID, err)
+				return
+			}
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,151 @@
+package game
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+func Test_Stream(t *testing.T) {
+	testcases := []struct {
+		name       string
+		header     string
+		query      string
+		wantCursor int64
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "resume from the last event id",
+			header:     "3",
+			wantCursor: 3,
+			wantStatus: 200,
+			wantBody: "id: 4\nevent: units_trained\ndata: {\"seq\":4,\"tick\":10,\"type\":\"units_trained\",\"cityID\":\"city\"}\n\n" +
+				"event: resources\ndata: {\"cityID\":\"city\",\"tick\":11,\"resources\":{\"food\":90,\"sticks\":0,\"stones\":0,\"gems\":0,\"population\":0,\"faith\":0},\"delta\":{\"food\":-10,\"sticks\":0,\"stones\":0,\"gems\":0,\"population\":0,\"faith\":0}}\n\n" +
+				"id: 5\nevent: battle_report\ndata: {\"seq\":5,\"tick\":11,\"type\":\"battle_report\"}\n\n",
+		},
+		{
+			name:       "resume from the cursor parameter",
+			query:      "?cursor=3",
+			wantCursor: 3,
+			wantStatus: 200,
+			wantBody: "id: 4\nevent: units_trained\ndata: {\"seq\":4,\"tick\":10,\"type\":\"units_trained\",\"cityID\":\"city\"}\n\n" +
+				"event: resources\ndata: {\"cityID\":\"city\",\"tick\":11,\"resources\":{\"food\":90,\"sticks\":0,\"stones\":0,\"gems\":0,\"population\":0,\"faith\":0},\"delta\":{\"food\":-10,\"sticks\":0,\"stones\":0,\"gems\":0,\"population\":0,\"faith\":0}}\n\n" +
+				"id: 5\nevent: battle_report\ndata: {\"seq\":5,\"tick\":11,\"type\":\"battle_report\"}\n\n",
+		},
+		{
+			name:       "invalid cursor",
+			query:      "?cursor=abc",
+			wantStatus: 400,
+			wantBody:   "user error: invalid cursor: strconv.ParseInt: parsing \"abc\": invalid syntax\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			// given
+			broker := &Broker{}
+			var gotCursors []int64
+			mockDB := &mockDatabase{
+				GetNotificationsFunc: func(playerID string, after int64, limit int) ([]*Notification, error) {
+					gotCursors = append(gotCursors, after)
+					switch len(gotCursors) {
+					case 1:
+						// a tick is processed after the stream caught up
+						broker.Publish(&TickResult{Tick: 11, Resources: map[string][]*ResourceUpdate{
+							"test-user":    {{CityID: "city", Tick: 11, Resources: Resources{Food: 90}, Delta: Resources{Food: -10}}},
+							"another-user": {{CityID: "another-city", Tick: 11, Resources: Resources{Food: 10}, Delta: Resources{Food: 10}}},
+						}, Notified: map[string]bool{"test-user": true}})
+						return []*Notification{{Seq: 4, Tick: 10, Type: NotificationUnitsTrained, CityID: "city"}}, nil
+					default:
+						cancel()
+						return []*Notification{{Seq: 5, Tick: 11, Type: NotificationBattleReport}}, nil
+					}
+				},
+			}
+			service := &GameService{Database: mockDB, Broker: broker}
+			rec := httptest.NewRecorder()
+
+			// when
+			req := httptest.NewRequestWithContext(ctx, "GET", "/api/stream"+testcase.query, http.NoBody)
+			if testcase.header != "" {
+				req.Header.Set("Last-Event-ID", testcase.header)
+			}
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+			done := make(chan struct{})
+			go func() {
+				service.Stream(rec, req)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream did not stop")
+			}
+
+			// then
+			if testcase.wantStatus != rec.Code {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+			if diff := cmp.Diff(testcase.wantBody, rec.Body.String()); diff != "" {
+				t.Errorf("unexpected body diff (-want, +got): %v", diff)
+			}
+			if len(gotCursors) > 0 && gotCursors[0] != testcase.wantCursor {
+				t.Errorf("unexpected cursor: want %v, got %v", testcase.wantCursor, gotCursors[0])
+			}
+		})
+	}
+}
+
+func Test_TickResultNotifies(t *testing.T) {
+	testcases := []struct {
+		name   string
+		result *TickResult
+		want   bool
+	}{
+		{name: "notified player", result: &TickResult{Notified: map[string]bool{"test-user": true}}, want: true},
+		{name: "other players notified", result: &TickResult{Notified: map[string]bool{"another-user": true}}, want: false},
+		{name: "no players notified", result: &TickResult{Notified: map[string]bool{}}, want: false},
+		{name: "notified players not known", result: &TickResult{}, want: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			if got := testcase.result.notifies("test-user"); got != testcase.want {
+				t.Errorf("unexpected notifies: want %v, got %v", testcase.want, got)
+			}
+		})
+	}
+}
+
+func Test_BrokerClose(t *testing.T) {
+	// given
+	broker := &Broker{}
+	results, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+
+	// when
+	broker.Publish(&TickResult{Tick: 1})
+	broker.Publish(&TickResult{Tick: 2})
+	broker.Close()
+
+	// then
+	if result := <-results; result.Tick != 1 {
+		t.Errorf("unexpected result: want tick 1, got %v", result.Tick)
+	}
+	if _, ok := <-results; ok {
+		t.Errorf("expected the channel to be closed")
+	}
+	if _, ok := <-func() <-chan *TickResult { ch, _ := broker.Subscribe(); return ch }(); ok {
+		t.Errorf("expected subscriptions after close to be closed")
+	}
+}
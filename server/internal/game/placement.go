@@ -0,0 +1,238 @@
+package game
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/luisferreira32/stickian/server/internal/game/hex"
+)
+
+// PlacementStrategy chooses where the first city of a new player is placed.
+type PlacementStrategy interface {
+	// Rank returns the free settleable tiles of the map, from the best to the worst spot for a new city,
+	// given all the tiles of the map and the existing cities.
+	//
+	// The database places the city on the first of the tiles that is not taken in the meanwhile.
+	Rank(tiles []*MapTile, cities []*City) []*MapTile
+}
+
+var (
+	// DefaultPlacement spawns new players in rings around the centre of the world, away from other cities,
+	// preferring plains and beaches and the least crowded islands.
+	DefaultPlacement = &SpawnPlacement{
+		Centered:       true,
+		RingWidth:      8,
+		MinDistance:    3,
+		Biomes:         []int{BiomePlains, BiomeBeach},
+		BalanceIslands: true,
+	}
+
+	placementStrategies = map[string]PlacementStrategy{
+		"diagonal": DiagonalPlacement{},
+		"spawn":    DefaultPlacement,
+	}
+)
+
+// ParsePlacementStrategy returns the placement strategy with the given name, i.e., diagonal or spawn.
+func ParsePlacementStrategy(name string) (PlacementStrategy, error) {
+	strategy, ok := placementStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown placement strategy: %s", name)
+	}
+	return strategy, nil
+}
+
+// freeTiles returns the settleable tiles without a city.
+func freeTiles(tiles []*MapTile, cities []*City) []*MapTile {
+	occupied := make(map[hex.Hex]bool, len(cities))
+	for _, c := range cities {
+		occupied[hex.Hex{Q: c.Q, R: c.R}] = true
+	}
+	free := make([]*MapTile, 0, len(tiles))
+	for _, t := range tiles {
+		if t.Settleable && !occupied[hex.Hex{Q: t.Q, R: t.R}] {
+			free = append(free, t)
+		}
+	}
+	return free
+}
+
+// DiagonalPlacement places new cities on the free tile with the lowest sum of q and r coordinates, which
+// creates a diagonal pattern of city placement starting from the origin (0, 0) and moving outward.
+type DiagonalPlacement struct{}
+
+func (DiagonalPlacement) Rank(tiles []*MapTile, cities []*City) []*MapTile {
+	free := freeTiles(tiles, cities)
+	slices.SortFunc(free, func(a, b *MapTile) int {
+		return cmp.Or(cmp.Compare(a.Q+a.R, b.Q+b.R), cmp.Compare(a.Q, b.Q), cmp.Compare(a.R, b.R))
+	})
+	return free
+}
+
+// SpawnPlacement places new cities in rings outward from a centre, such that the inner rings fill up first.
+//
+// Within a ring, the tiles on the islands with the least cities per settleable tile come first, then the
+// tiles of the preferred biomes, and then the tiles closest to the centre. Tiles closer than MinDistance to
+// an existing city come after all others, so that newcomers are not packed next to established players
+// unless the world is full.
+type SpawnPlacement struct {
+	// Q and R are the coordinates of the centre of the rings
+	Q, R int
+	// Centered places the centre of the rings in the middle of the map instead, such that it fits any world size
+	Centered bool
+	// RingWidth is the width of each ring, in tiles
+	RingWidth int
+	// MinDistance is the distance a new city should keep from the existing cities
+	MinDistance int
+	// Biomes are the preferred biomes of a new city, from the most to the least preferred
+	Biomes []int
+	// BalanceIslands spreads the new cities across the islands of the world
+	BalanceIslands bool
+}
+
+type spawnCandidate struct {
+	tile     *MapTile
+	crowded  bool
+	ring     int
+	cities   int
+	capacity int
+	biome    int
+	distance int
+}
+
+func (p *SpawnPlacement) Rank(tiles []*MapTile, cities []*City) []*MapTile {
+	occupied := make(map[hex.Hex]bool, len(cities))
+	for _, c := range cities {
+		occupied[hex.Hex{Q: c.Q, R: c.R}] = true
+	}
+
+	// the load of an island is the number of its cities per settleable tile
+	islands := islandsOf(tiles)
+	capacity := make(map[int]int)
+	load := make(map[int]int)
+	for _, t := range tiles {
+		if island, ok := islands[hex.Hex{Q: t.Q, R: t.R}]; ok && t.Settleable {
+			capacity[island]++
+		}
+	}
+	for _, c := range cities {
+		if island, ok := islands[hex.Hex{Q: c.Q, R: c.R}]; ok {
+			load[island]++
+		}
+	}
+
+	center := hex.Hex{Q: p.Q, R: p.R}
+	if p.Centered {
+		center = mapCenter(tiles)
+	}
+
+	candidates := make([]spawnCandidate, 0, len(tiles))
+	for _, t := range freeTiles(tiles, cities) {
+		tile := hex.Hex{Q: t.Q, R: t.R}
+		distance := hex.Distance(center, tile)
+		candidate := spawnCandidate{
+			tile:     t,
+			crowded:  occupiedWithin(occupied, tile, p.MinDistance-1),
+			ring:     distance / max(1, p.RingWidth),
+			biome:    len(p.Biomes),
+			distance: distance,
+		}
+		if i := slices.Index(p.Biomes, t.Biome); i >= 0 {
+			candidate.biome = i
+		}
+		if island, ok := islands[hex.Hex{Q: t.Q, R: t.R}]; ok && p.BalanceIslands {
+			candidate.cities, candidate.capacity = load[island], capacity[island]
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	slices.SortFunc(candidates, func(a, b spawnCandidate) int {
+		return cmp.Or(
+			compareBool(a.crowded, b.crowded),
+			cmp.Compare(a.ring, b.ring),
+			// compare the loads a.cities/a.capacity and b.cities/b.capacity without dividing
+			cmp.Compare(a.cities*b.capacity, b.cities*a.capacity),
+			cmp.Compare(a.biome, b.biome),
+			cmp.Compare(a.distance, b.distance),
+			cmp.Compare(a.tile.Q, b.tile.Q),
+			cmp.Compare(a.tile.R, b.tile.R),
+		)
+	})
+
+	ranked := make([]*MapTile, len(candidates))
+	for i, candidate := range candidates {
+		ranked[i] = candidate.tile
+	}
+	return ranked
+}
+
+// mapCenter returns the tile in the middle of the bounding box of the tiles.
+func mapCenter(tiles []*MapTile) hex.Hex {
+	if len(tiles) == 0 {
+		return hex.Hex{}
+	}
+	minQ, maxQ, minR, maxR := tiles[0].Q, tiles[0].Q, tiles[0].R, tiles[0].R
+	for _, t := range tiles {
+		minQ, maxQ = min(minQ, t.Q), max(maxQ, t.Q)
+		minR, maxR = min(minR, t.R), max(maxR, t.R)
+	}
+	return hex.Hex{Q: (minQ + maxQ + 1) / 2, R: (minR + maxR + 1) / 2}
+}
+
+// compareBool orders false before true.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// occupiedWithin returns whether there is an occupied tile within the given distance of the tile.
+func occupiedWithin(occupied map[hex.Hex]bool, tile hex.Hex, distance int) bool {
+	for _, h := range hex.Range(tile, distance) {
+		if occupied[h] {
+			return true
+		}
+	}
+	return false
+}
+
+// islandsOf returns the island of each land tile of the map, where an island is a group of land tiles
+// connected through their neighbours.
+func islandsOf(tiles []*MapTile) map[hex.Hex]int {
+	land := make(map[hex.Hex]bool, len(tiles))
+	for _, t := range tiles {
+		if t.Biome != BiomeOcean && t.Biome != BiomeSea {
+			land[hex.Hex{Q: t.Q, R: t.R}] = true
+		}
+	}
+
+	islands := make(map[hex.Hex]int, len(land))
+	island := 0
+	for _, t := range tiles {
+		start := hex.Hex{Q: t.Q, R: t.R}
+		if _, seen := islands[start]; seen || !land[start] {
+			continue
+		}
+		islands[start] = island
+		queue := []hex.Hex{start}
+		for len(queue) > 0 {
+			tile := queue[0]
+			queue = queue[1:]
+			for _, next := range tile.Neighbors() {
+				if _, seen := islands[next]; seen || !land[next] {
+					continue
+				}
+				islands[next] = island
+				queue = append(queue, next)
+			}
+		}
+		island++
+	}
+	return islands
+}
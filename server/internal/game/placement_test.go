@@ -0,0 +1,150 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// makeMap returns the tiles of a map drawn with one row per r coordinate and one biome id per q coordinate,
+// where beaches and plains are settleable.
+func makeMap(rows ...string) []*MapTile {
+	var tiles []*MapTile
+	for r, row := range rows {
+		for q, c := range row {
+			biome := int(c - '0')
+			tiles = append(tiles, &MapTile{Q: q, R: r, Biome: biome, Settleable: biome == BiomeBeach || biome == BiomePlains})
+		}
+	}
+	return tiles
+}
+
+func Test_DiagonalPlacement(t *testing.T) {
+	// given
+	tiles := makeMap(
+		"0333",
+		"3433",
+	)
+	cities := []*City{{ID: "city", Q: 1, R: 0}}
+
+	// when
+	got := DiagonalPlacement{}.Rank(tiles, cities)
+
+	// then
+	want := []*MapTile{
+		{Q: 0, R: 1, Biome: BiomePlains, Settleable: true},
+		{Q: 2, R: 0, Biome: BiomePlains, Settleable: true},
+		{Q: 2, R: 1, Biome: BiomePlains, Settleable: true},
+		{Q: 3, R: 0, Biome: BiomePlains, Settleable: true},
+		{Q: 3, R: 1, Biome: BiomePlains, Settleable: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected rank diff (-want, +got): %v", diff)
+	}
+}
+
+func Test_SpawnPlacement(t *testing.T) {
+	plains := makeMap(
+		"33333",
+		"33333",
+		"33333",
+		"33333",
+		"33333",
+	)
+
+	testcases := []struct {
+		name      string
+		tiles     []*MapTile
+		cities    []*City
+		placement *SpawnPlacement
+		wantFirst *MapTile
+		wantCount int
+	}{
+		{
+			name:      "spawn at the centre of an empty world",
+			tiles:     plains,
+			placement: &SpawnPlacement{Q: 2, R: 2, RingWidth: 1, MinDistance: 2},
+			wantFirst: &MapTile{Q: 2, R: 2, Biome: BiomePlains, Settleable: true},
+			wantCount: 25,
+		},
+		{
+			name:      "keep the distance to existing cities",
+			tiles:     plains,
+			cities:    []*City{{ID: "city", Q: 2, R: 2}},
+			placement: &SpawnPlacement{Q: 2, R: 2, RingWidth: 1, MinDistance: 2},
+			wantFirst: &MapTile{Q: 0, R: 2, Biome: BiomePlains, Settleable: true},
+			wantCount: 24,
+		},
+		{
+			name: "prefer the biomes within a ring",
+			tiles: makeMap(
+				"33332",
+				"33333",
+				"33333",
+				"33333",
+				"33333",
+			),
+			placement: &SpawnPlacement{Q: 2, R: 2, RingWidth: 3, Biomes: []int{BiomeBeach, BiomePlains}},
+			wantFirst: &MapTile{Q: 4, R: 0, Biome: BiomeBeach, Settleable: true},
+			wantCount: 25,
+		},
+		{
+			name:      "balance the islands",
+			tiles:     makeMap("33033"),
+			cities:    []*City{{ID: "city", Q: 0, R: 0}},
+			placement: &SpawnPlacement{Q: 2, R: 0, RingWidth: 10, BalanceIslands: true},
+			wantFirst: &MapTile{Q: 3, R: 0, Biome: BiomePlains, Settleable: true},
+			wantCount: 3,
+		},
+		{
+			name:      "ignore the islands without balancing",
+			tiles:     makeMap("33033"),
+			cities:    []*City{{ID: "city", Q: 0, R: 0}},
+			placement: &SpawnPlacement{Q: 2, R: 0, RingWidth: 10},
+			wantFirst: &MapTile{Q: 1, R: 0, Biome: BiomePlains, Settleable: true},
+			wantCount: 3,
+		},
+		{
+			name:      "pack the cities once the world is full",
+			tiles:     makeMap("333"),
+			cities:    []*City{{ID: "city", Q: 1, R: 0}},
+			placement: &SpawnPlacement{Q: 1, R: 0, RingWidth: 1, MinDistance: 5},
+			wantFirst: &MapTile{Q: 0, R: 0, Biome: BiomePlains, Settleable: true},
+			wantCount: 2,
+		},
+		{
+			name:      "no free tiles",
+			tiles:     makeMap("34"),
+			cities:    []*City{{ID: "city", Q: 0, R: 0}},
+			placement: DefaultPlacement,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// when
+			got := testcase.placement.Rank(testcase.tiles, testcase.cities)
+
+			// then
+			if len(got) != testcase.wantCount {
+				t.Errorf("unexpected number of tiles: want %v, got %v", testcase.wantCount, len(got))
+			}
+			var gotFirst *MapTile
+			if len(got) > 0 {
+				gotFirst = got[0]
+			}
+			if diff := cmp.Diff(testcase.wantFirst, gotFirst); diff != "" {
+				t.Errorf("unexpected first tile diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ParsePlacementStrategy(t *testing.T) {
+	if got, err := ParsePlacementStrategy("spawn"); err != nil || got != DefaultPlacement {
+		t.Errorf("unexpected spawn strategy: %v, %v", got, err)
+	}
+	if _, err := ParsePlacementStrategy("random"); err == nil {
+		t.Errorf("expected an error for an unknown strategy")
+	}
+}
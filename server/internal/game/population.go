@@ -0,0 +1,89 @@
+package game
+
+import "math"
+
+const (
+	// foodPerPopulation is the hourly food upkeep of each inhabitant
+	foodPerPopulation = 1
+	// starvationPercent is the hourly percentage of the population, and of each unit, that leaves a starving city
+	starvationPercent = 10
+)
+
+// populationCapacity returns how many inhabitants the city hall of the given level can house.
+func populationCapacity(cityHallLevel int) int {
+	return 40 + int(math.Round(40*float64(cityHallLevel)*math.Pow(1.2, float64(cityHallLevel-1))))
+}
+
+// foodUpkeep returns the hourly food consumption of a city, for both its population and its army.
+func foodUpkeep(c *City) int {
+	return c.Resources.Population*foodPerPopulation + unitsUpkeep(c.Units)
+}
+
+// starving returns whether the city ran out of food and its production does not cover its upkeep, at the given
+// speed of its world.
+func starving(c *City, speed int) bool {
+	rates := productionRates(c)
+	rates.multiply(speed)
+	return c.Resources.Food == 0 && rates.Food < foodUpkeep(c)*speed
+}
+
+// populationGrowth returns the hourly population change of a city, at the given speed of its world.
+//
+// While there is food in stock the population grows, attracted by the Tavern, up to the city hall capacity.
+// Once the city is starving, the population starves and the units desert.
+func populationGrowth(c *City, speed int) int {
+	if starving(c, speed) {
+		return -starvationRate(c.Resources.Population) * speed
+	}
+	if c.Resources.Food > 0 && c.Resources.Population < populationCapacity(c.Buildings.CityHall) {
+		return immigrationRate(c.Buildings.Tavern) * speed
+	}
+	return 0
+}
+
+// immigrationRate returns the hourly population growth attracted by the tavern of the given level.
+func immigrationRate(tavernLevel int) int {
+	return 5 + 5*tavernLevel
+}
+
+// starvationRate returns the hourly decline of a starving population, or of a deserting unit.
+func starvationRate(count int) int {
+	if count == 0 {
+		return 0
+	}
+	return max(1, count*starvationPercent/100)
+}
+
+// cityRates returns the hourly net rates of a city at the given speed of its world, i.e., the production minus
+// the upkeep, and the population growth.
+func cityRates(c *City, speed int) Resources {
+	rates := productionRates(c)
+	rates.multiply(speed)
+	rates.Food -= foodUpkeep(c) * speed
+	rates.Population = populationGrowth(c, speed)
+	return rates
+}
+
+// feed charges the food upkeep of the tick to all cities, and grows or starves their population and army, at
+// the speed of their world as in populationGrowth.
+func (e *TickEngine) feed(state *TickState) {
+	for _, city := range state.Cities {
+		speed := state.world(city.WorldID).speed()
+		upkeep := perTick(foodUpkeep(city)*speed, state.Tick, e.TickDuration)
+		city.Resources.Food = max(0, city.Resources.Food-upkeep)
+		if starving(city, speed) {
+			decline := perTick(-populationGrowth(city, speed), state.Tick, e.TickDuration)
+			city.Resources.Population = max(0, city.Resources.Population-decline)
+			for unit, count := range city.Units {
+				desertion := perTick(starvationRate(count)*speed, state.Tick, e.TickDuration)
+				city.Units[unit] = max(0, count-desertion)
+			}
+			continue
+		}
+
+		if growth := populationGrowth(city, speed); growth > 0 {
+			capacity := populationCapacity(city.Buildings.CityHall)
+			city.Resources.Population = min(city.Resources.Population+perTick(growth, state.Tick, e.TickDuration), capacity)
+		}
+	}
+}
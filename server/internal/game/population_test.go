@@ -0,0 +1,161 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Feed(t *testing.T) {
+	testcases := []struct {
+		name          string
+		city          *City
+		speed         int
+		wantResources *Resources
+		wantUnits     map[string]int
+	}{
+		{
+			name: "population grows with food",
+			city: &City{
+				Buildings: &Buildings{Tavern: 1},
+				Resources: &Resources{Food: 100, Population: 10},
+			},
+			wantResources: &Resources{Food: 90, Population: 20},
+		},
+		{
+			name: "population does not grow beyond the city hall capacity",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 100, Population: 38},
+			},
+			wantResources: &Resources{Food: 62, Population: 40},
+		},
+		{
+			name: "population starves without food",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 20, Population: 30},
+			},
+			wantResources: &Resources{Food: 0, Population: 27},
+		},
+		{
+			name: "units desert without food",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 10, Population: 0},
+				Units:     map[string]int{"spearman": 50, "horseman": 5},
+			},
+			wantResources: &Resources{Food: 0, Population: 0},
+			wantUnits:     map[string]int{"spearman": 45, "horseman": 4},
+		},
+		{
+			name: "population grows faster in a faster world",
+			city: &City{
+				Buildings: &Buildings{CityHall: 3, Tavern: 1},
+				Resources: &Resources{Food: 500, Population: 10},
+			},
+			speed:         10,
+			wantResources: &Resources{Food: 400, Population: 110},
+		},
+		{
+			name: "population starves faster in a faster world",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 200, Population: 30},
+			},
+			speed:         10,
+			wantResources: &Resources{Food: 0, Population: 0},
+		},
+		{
+			// the stock does not cover the upkeep of the tick, but the production of the faster world does
+			name: "production covers the upkeep in a faster world",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 50, Population: 10},
+			},
+			speed:         10,
+			wantResources: &Resources{Food: 0, Population: 10},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			engine := &TickEngine{TickDuration: time.Hour}
+			state := &TickState{Tick: 1, Cities: map[string]*City{"city": testcase.city}}
+			if testcase.speed > 0 {
+				state.Worlds = map[string]*World{"": {Speed: testcase.speed}}
+			}
+
+			// when
+			engine.feed(state)
+
+			// then
+			if diff := cmp.Diff(testcase.wantResources, state.Cities["city"].Resources); diff != "" {
+				t.Errorf("unexpected resources diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantUnits, state.Cities["city"].Units); diff != "" {
+				t.Errorf("unexpected units diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_CityRates(t *testing.T) {
+	testcases := []struct {
+		name      string
+		city      *City
+		speed     int
+		wantRates Resources
+	}{
+		{
+			name: "growing city",
+			city: &City{
+				Biome:     BiomeBeach,
+				Buildings: &Buildings{Farm: 1, Tavern: 2},
+				Resources: &Resources{Food: 10, Population: 10},
+			},
+			wantRates: Resources{Food: 45, Sticks: 20, Stones: 9, Population: 15},
+		},
+		{
+			name: "starving city",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 0, Population: 30},
+			},
+			wantRates: Resources{Food: -10, Sticks: 20, Stones: 10, Population: -3},
+		},
+		{
+			name: "starving city in a faster world",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 0, Population: 30},
+			},
+			speed:     10,
+			wantRates: Resources{Food: -100, Sticks: 200, Stones: 100, Population: -30},
+		},
+		{
+			// as fed in a tick of the faster world, where the production covers the upkeep
+			name: "city without food in a faster world",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 0, Population: 10},
+			},
+			speed:     10,
+			wantRates: Resources{Food: 100, Sticks: 200, Stones: 100},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// when
+			rates := cityRates(testcase.city, max(1, testcase.speed))
+
+			// then
+			if diff := cmp.Diff(testcase.wantRates, rates); diff != "" {
+				t.Errorf("unexpected rates diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package game
+
+import (
+	"math"
+	"time"
+)
+
+// productionSpec defines the hourly production of a resource by its building.
+//
+// The production at level L is Base + PerLevel * L * Growth^(L-1), such that a city always produces
+// a little bit of every basic resource even without the building.
+type productionSpec struct {
+	Base     int
+	PerLevel int
+	Growth   float64
+}
+
+func (s *productionSpec) rate(level int) int {
+	if level <= 0 {
+		return s.Base
+	}
+	return s.Base + int(math.Round(float64(s.PerLevel*level)*math.Pow(s.Growth, float64(level-1))))
+}
+
+var (
+	farmProduction        = &productionSpec{Base: 20, PerLevel: 30, Growth: 1.1}
+	lumbermillProduction  = &productionSpec{Base: 20, PerLevel: 30, Growth: 1.1}
+	quarryProduction      = &productionSpec{Base: 10, PerLevel: 25, Growth: 1.1}
+	crystalMineProduction = &productionSpec{Base: 0, PerLevel: 10, Growth: 1.1}
+
+	// biomeModifiers are the production modifiers of each biome, in percent
+	biomeModifiers = map[int]Resources{
+		BiomeBeach:    {Food: 110, Sticks: 100, Stones: 90, Gems: 100},
+		BiomePlains:   {Food: 125, Sticks: 110, Stones: 90, Gems: 90},
+		BiomeMountain: {Food: 80, Sticks: 90, Stones: 125, Gems: 125},
+	}
+)
+
+// productionRates returns the hourly production of a city.
+func productionRates(c *City) Resources {
+	rates := Resources{
+		Food:   farmProduction.rate(c.Buildings.Farm),
+		Sticks: lumbermillProduction.rate(c.Buildings.Lumbermill),
+		Stones: quarryProduction.rate(c.Buildings.Quarry),
+		Gems:   crystalMineProduction.rate(c.Buildings.CrystalMine),
+	}
+	if modifier, ok := biomeModifiers[c.Biome]; ok {
+		rates.Food = rates.Food * modifier.Food / 100
+		rates.Sticks = rates.Sticks * modifier.Sticks / 100
+		rates.Stones = rates.Stones * modifier.Stones / 100
+		rates.Gems = rates.Gems * modifier.Gems / 100
+	}
+	return rates
+}
+
+// perTick returns the amount produced during a tick for an hourly rate.
+//
+// Instead of accumulating fractions, the amount is the difference of the total produced up to the end
+// and up to the start of the tick, which is deterministic and adds up to the exact hourly rate over time.
+func perTick(ratePerHour int, tick int64, tickDuration time.Duration) int {
+	ms := tickDuration.Milliseconds()
+	hour := time.Hour.Milliseconds()
+	rate := int64(ratePerHour)
+	return int(rate*(tick+1)*ms/hour - rate*tick*ms/hour)
+}
+
+// produce adds the resources produced during the tick to all cities, at the speed of their world, discarding
+// what overflows the warehouse.
+func (e *TickEngine) produce(state *TickState) {
+	for _, city := range state.Cities {
+		rates := productionRates(city)
+		rates.multiply(state.world(city.WorldID).speed())
+		capacity := storageCapacity(city.Buildings.Warehouse)
+		store := func(amount *int, rate, capacity int) {
+			if *amount >= capacity {
+				// never discard what is already stored, e.g., plundered resources
+				return
+			}
+			*amount = min(*amount+perTick(rate, state.Tick, e.TickDuration), capacity)
+		}
+		store(&city.Resources.Food, rates.Food, capacity.Food)
+		store(&city.Resources.Sticks, rates.Sticks, capacity.Sticks)
+		store(&city.Resources.Stones, rates.Stones, capacity.Stones)
+		store(&city.Resources.Gems, rates.Gems, capacity.Gems)
+	}
+}
@@ -0,0 +1,97 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_PerTick(t *testing.T) {
+	testcases := []struct {
+		name         string
+		ratePerHour  int
+		tickDuration time.Duration
+	}{
+		{name: "one second ticks", ratePerHour: 50, tickDuration: time.Second},
+		{name: "uneven ticks", ratePerHour: 137, tickDuration: 7 * time.Second},
+		{name: "rate above ticks per hour", ratePerHour: 10000, tickDuration: time.Second},
+		{name: "no production", ratePerHour: 0, tickDuration: time.Second},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			ticks := int64(time.Hour / testcase.tickDuration)
+			start := int64(12345)
+
+			// when
+			total := 0
+			for tick := start; tick < start+ticks; tick++ {
+				total += perTick(testcase.ratePerHour, tick, testcase.tickDuration)
+			}
+
+			// then
+			// the total over an hour is off by at most one due to the truncation at the hour boundaries
+			if diff := total - testcase.ratePerHour; diff < -1 || diff > 1 {
+				t.Errorf("unexpected hourly production: want %v, got %v", testcase.ratePerHour, total)
+			}
+		})
+	}
+}
+
+func Test_Produce(t *testing.T) {
+	testcases := []struct {
+		name          string
+		city          *City
+		worlds        map[string]*World
+		wantResources *Resources
+	}{
+		{
+			name: "production by building levels and biome",
+			city: &City{
+				Biome:     BiomePlains,
+				Buildings: &Buildings{Farm: 1, Lumbermill: 2, Quarry: 0, CrystalMine: 1},
+				Resources: &Resources{Food: 100, Sticks: 100, Stones: 100, Gems: 100},
+			},
+			// food: 50 * 125%, sticks: 86 * 110%, stones: 10 * 90%, gems: 10 * 90%
+			wantResources: &Resources{Food: 162, Sticks: 194, Stones: 109, Gems: 109},
+		},
+		{
+			name: "production by world speed",
+			city: &City{
+				WorldID:   "blitz",
+				Biome:     BiomePlains,
+				Buildings: &Buildings{Farm: 1, Lumbermill: 2, Quarry: 0, CrystalMine: 1},
+				Resources: &Resources{Food: 100, Sticks: 100, Stones: 100, Gems: 100},
+			},
+			worlds:        map[string]*World{"blitz": {ID: "blitz", Speed: 3}},
+			wantResources: &Resources{Food: 286, Sticks: 382, Stones: 127, Gems: 127},
+		},
+		{
+			name: "overflow is discarded",
+			city: &City{
+				Biome:     BiomeBeach,
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 990, Sticks: 1000, Stones: 5000},
+			},
+			wantResources: &Resources{Food: 1000, Sticks: 1000, Stones: 5000},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			engine := &TickEngine{TickDuration: time.Hour}
+			state := &TickState{Tick: 1, Cities: map[string]*City{"city": testcase.city}, Worlds: testcase.worlds}
+
+			// when
+			engine.produce(state)
+
+			// then
+			if diff := cmp.Diff(testcase.wantResources, state.Cities["city"].Resources); diff != "" {
+				t.Errorf("unexpected resources diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package game
+
+import "math"
+
+// Storage defines the storage limits of a city for its stockpiled resources, i.e., food, sticks, stones
+// and gems, and the housing for its population. Faith is not stockpiled, so it is always zero.
+type Storage struct {
+	// Capacity is the maximum amount of each resource the city can store, given by the Warehouse level,
+	// and the maximum population, given by the City Hall level
+	Capacity Resources `json:"capacity"`
+	// Protected is the amount of each resource that can not be plundered, given by the Treasury level
+	Protected Resources `json:"protected"`
+}
+
+// storageCapacity returns how much of each resource a warehouse of the given level can store.
+//
+// Gems are scarcer than the other resources, so they have a lower capacity.
+func storageCapacity(warehouseLevel int) Resources {
+	capacity := int(math.Round(1000 * math.Pow(1.3, float64(warehouseLevel))))
+	return Resources{
+		Food:   capacity,
+		Sticks: capacity,
+		Stones: capacity,
+		Gems:   capacity / 4,
+	}
+}
+
+// protectedResources returns how much of each resource a treasury of the given level protects from plunder.
+//
+// The protection never exceeds the storage capacity.
+func protectedResources(treasuryLevel, warehouseLevel int) Resources {
+	capacity := storageCapacity(warehouseLevel)
+	protected := 100
+	if treasuryLevel > 0 {
+		protected = int(math.Round(200 * float64(treasuryLevel) * math.Pow(1.2, float64(treasuryLevel-1))))
+	}
+	return Resources{
+		Food:   min(protected, capacity.Food),
+		Sticks: min(protected, capacity.Sticks),
+		Stones: min(protected, capacity.Stones),
+		Gems:   min(protected/4, capacity.Gems),
+	}
+}
+
+// cityStorage returns the storage limits of a city.
+func cityStorage(c *City) *Storage {
+	capacity := storageCapacity(c.Buildings.Warehouse)
+	capacity.Population = populationCapacity(c.Buildings.CityHall)
+	return &Storage{
+		Capacity:  capacity,
+		Protected: protectedResources(c.Buildings.Treasury, c.Buildings.Warehouse),
+	}
+}
+
+// plunderable returns the resources of a city that raiders can steal, i.e., everything above the
+// amount protected by the Treasury.
+func plunderable(c *City) Resources {
+	protected := protectedResources(c.Buildings.Treasury, c.Buildings.Warehouse)
+	return Resources{
+		Food:   max(0, c.Resources.Food-protected.Food),
+		Sticks: max(0, c.Resources.Sticks-protected.Sticks),
+		Stones: max(0, c.Resources.Stones-protected.Stones),
+		Gems:   max(0, c.Resources.Gems-protected.Gems),
+	}
+}
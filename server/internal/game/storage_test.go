@@ -0,0 +1,57 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Plunderable(t *testing.T) {
+	testcases := []struct {
+		name      string
+		city      *City
+		wantLoot  Resources
+		wantSafe  Resources
+		wantLimit Resources
+	}{
+		{
+			name: "without treasury",
+			city: &City{
+				Buildings: &Buildings{},
+				Resources: &Resources{Food: 500, Sticks: 50, Stones: 100, Gems: 30},
+			},
+			wantLoot:  Resources{Food: 400, Sticks: 0, Stones: 0, Gems: 5},
+			wantSafe:  Resources{Food: 100, Sticks: 100, Stones: 100, Gems: 25},
+			wantLimit: Resources{Food: 1000, Sticks: 1000, Stones: 1000, Gems: 250, Population: 40},
+		},
+		{
+			name: "treasury protection is limited by the warehouse",
+			city: &City{
+				Buildings: &Buildings{Treasury: 10},
+				Resources: &Resources{Food: 2000, Sticks: 1000, Stones: 100, Gems: 300},
+			},
+			wantLoot:  Resources{Food: 1000, Sticks: 0, Stones: 0, Gems: 50},
+			wantSafe:  Resources{Food: 1000, Sticks: 1000, Stones: 1000, Gems: 250},
+			wantLimit: Resources{Food: 1000, Sticks: 1000, Stones: 1000, Gems: 250, Population: 40},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// when
+			loot := plunderable(testcase.city)
+			storage := cityStorage(testcase.city)
+
+			// then
+			if diff := cmp.Diff(testcase.wantLoot, loot); diff != "" {
+				t.Errorf("unexpected plunderable diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantSafe, storage.Protected); diff != "" {
+				t.Errorf("unexpected protected diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantLimit, storage.Capacity); diff != "" {
+				t.Errorf("unexpected capacity diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	// ErrTickOutOfOrder is returned by the database when asked to process a tick that does not
+	// immediately follow the last processed one, e.g., if another server already processed it.
+	ErrTickOutOfOrder = errors.New("tick out of order")
+)
+
+// TickListener is implemented by the databases shared by several instances, to tell each of them about the ticks
+// processed by any of them.
+type TickListener interface {
+	// ListenTicks calls publish with the result of every tick committed from then on, with the players notified
+	// but without resource updates, blocking until the context is cancelled or the listening fails.
+	ListenTicks(ctx context.Context, publish func(*TickResult)) error
+}
+
+// TickEngine is the game loop of a world, and the single consumer of its event queue.
+//
+// Endpoints only submit events to the queue, and it is up to the engine to apply their effects. Each
+// tick is processed inside a single database transaction, and the last committed tick is persisted,
+// such that the engine resumes exactly where it stopped after a restart.
+type TickEngine struct {
+	Database     GameDatabase
+	TickDuration time.Duration
+	// Broker, if set, receives the result of every processed tick
+	Broker *Broker
+
+	// result of the last processed tick, only published once the tick is committed
+	result *TickResult
+}
+
+// Run starts the tick engine loop, blocking until the context is cancelled.
+//
+// If the database is a TickListener, the broker also receives the results of the ticks processed by the other
+// instances, since only one of them processes each tick.
+func (e *TickEngine) Run(ctx context.Context) {
+	if listener, ok := e.Database.(TickListener); ok && e.Broker != nil {
+		go e.listen(ctx, listener)
+	}
+
+	var (
+		clock *Clock
+		err   error
+	)
+	for clock == nil {
+		clock, err = e.Database.GetClock(ctx)
+		if err != nil {
+			log.Printf("failed to get game clock: %v", err)
+			if !e.sleep(ctx, e.TickDuration) {
+				return
+			}
+		}
+	}
+
+	for {
+		// only process ticks whose window is closed, i.e., no more events can be submitted for them,
+		// this also catches up on any ticks that were missed while the server was down
+		for ctx.Err() == nil && clock.LastTick+1 < clock.tickAt(time.Now(), e.TickDuration) {
+			e.result = nil
+			err := e.Database.ProcessTick(ctx, clock.LastTick+1, e.Process)
+			if errors.Is(err, ErrTickOutOfOrder) {
+				// someone else moved the clock, re-sync with it before continuing
+				c, err := e.Database.GetClock(ctx)
+				if err != nil {
+					log.Printf("failed to get game clock: %v", err)
+					break
+				}
+				clock = c
+				continue
+			}
+			if err != nil {
+				// a failed tick is not committed, so we can simply retry processing the same tick
+				log.Printf("tick %d err: %v", clock.LastTick+1, err)
+				break
+			}
+			clock.LastTick++
+			if e.Broker != nil && e.result != nil {
+				e.Broker.Publish(e.result)
+			}
+		}
+
+		// sleep until the next tick window closes
+		next := clock.StartedAt.Add(time.Duration(clock.LastTick+2) * e.TickDuration)
+		if !e.sleep(ctx, time.Until(next)) {
+			return
+		}
+	}
+}
+
+// listen publishes the results of the ticks of all instances to the broker until the context is cancelled,
+// listening again one tick after a failure.
+func (e *TickEngine) listen(ctx context.Context, listener TickListener) {
+	for {
+		err := listener.ListenTicks(ctx, e.Broker.Publish)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("failed to listen to ticks: %v", err)
+		if !e.sleep(ctx, e.TickDuration) {
+			return
+		}
+	}
+}
+
+// sleep waits for the duration d, and returns false if the context was cancelled meanwhile.
+func (e *TickEngine) sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Process applies the effects of all events of a tick to the world state, followed by the arrival of
+// movements, and the production, the upkeep and the loyalty recovery of all cities during the tick.
+//
+// An error should only be returned if the tick can not be processed at all, since it will fail and be
+// retried as a whole. It is the process of the ProcessTick of the database.
+func (e *TickEngine) Process(state *TickState) error {
+	before := make(map[string]Resources, len(state.Cities))
+	for id, city := range state.Cities {
+		if city.Resources != nil {
+			before[id] = *city.Resources
+		}
+	}
+
+	for _, event := range state.Events {
+		if err := e.processEvent(state, event); err != nil {
+			return fmt.Errorf("event %s: %w", event.Key, err)
+		}
+	}
+
+	e.move(state)
+	e.produce(state)
+	e.feed(state)
+	e.restoreLoyalty(state)
+
+	e.result = tickResult(state, before)
+	return nil
+}
+
+// processEvent applies the effects of a single event to the world state.
+//
+// Events that can no longer be fulfilled (e.g., not enough resources) are skipped, it is up to the
+// front-end to fetch the latest accurate state to revert its optimistic updates.
+func (e *TickEngine) processEvent(state *TickState, event *Event) error {
+	switch event.Type {
+	case EventUpgradeOrdered:
+		return e.processUpgradeOrdered(state, event)
+	case EventConstructionCompleted:
+		return e.processConstructionCompleted(state, event)
+	case EventTrainingOrdered:
+		return e.processTrainingOrdered(state, event)
+	case EventUnitsTrained:
+		return e.processUnitsTrained(state, event)
+	case EventMovementOrdered:
+		return e.processMovementOrdered(state, event)
+	case EventMovementRecalled:
+		return e.processMovementRecalled(state, event)
+	default:
+		// do not fail the tick, or a single bad event would stall the whole world
+		log.Printf("skipping unknown event type %q for event %s", event.Type, event.Key)
+		return nil
+	}
+}
+
+// ticks converts a game duration into a number of ticks, rounded up such that it always takes at least one tick.
+func (e *TickEngine) ticks(d time.Duration) int64 {
+	return max(1, int64((d+e.TickDuration-1)/e.TickDuration))
+}
@@ -0,0 +1,115 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_TickEngineRun(t *testing.T) {
+	testcases := []struct {
+		name      string
+		clocks    []*Clock
+		tickErrs  map[int64]error
+		wantTicks []int64
+	}{
+		{
+			name:      "catches up on closed ticks",
+			clocks:    []*Clock{{StartedAt: time.Now().Add(-5*time.Hour - 30*time.Minute), LastTick: 1}},
+			wantTicks: []int64{2, 3, 4},
+		},
+		{
+			name: "re-syncs the clock on out of order ticks",
+			clocks: []*Clock{
+				{StartedAt: time.Now().Add(-5*time.Hour - 30*time.Minute), LastTick: 1},
+				{StartedAt: time.Now().Add(-5*time.Hour - 30*time.Minute), LastTick: 2},
+			},
+			tickErrs:  map[int64]error{2: ErrTickOutOfOrder},
+			wantTicks: []int64{2, 3, 4},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			// given
+			gotTicks := []int64{}
+			clockCalls := 0
+			mockDB := &mockDatabase{
+				GetClockFunc: func() (*Clock, error) {
+					clock := testcase.clocks[min(clockCalls, len(testcase.clocks)-1)]
+					clockCalls++
+					return clock, nil
+				},
+				ProcessTickFunc: func(tick int64, process func(*TickState) error) error {
+					gotTicks = append(gotTicks, tick)
+					if err := testcase.tickErrs[tick]; err != nil {
+						return err
+					}
+					if tick == testcase.wantTicks[len(testcase.wantTicks)-1] {
+						cancel()
+					}
+					return process(&TickState{Tick: tick, Cities: map[string]*City{}})
+				},
+			}
+			engine := &TickEngine{Database: mockDB, TickDuration: time.Hour}
+
+			// when
+			done := make(chan struct{})
+			go func() {
+				engine.Run(ctx)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("engine did not stop")
+			}
+
+			// then
+			if diff := cmp.Diff(testcase.wantTicks, gotTicks); diff != "" {
+				t.Errorf("unexpected processed ticks (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_TickEngineRunRetriesFailedTick(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	// given
+	gotTicks := []int64{}
+	mockDB := &mockDatabase{
+		GetClockFunc: func() (*Clock, error) {
+			return &Clock{StartedAt: time.Now().Add(-2*time.Millisecond - 500*time.Microsecond), LastTick: -1}, nil
+		},
+		ProcessTickFunc: func(tick int64, process func(*TickState) error) error {
+			gotTicks = append(gotTicks, tick)
+			if len(gotTicks) == 1 {
+				return errors.New("a database error")
+			}
+			if tick == 1 {
+				cancel()
+			}
+			return nil
+		},
+	}
+	engine := &TickEngine{Database: mockDB, TickDuration: time.Millisecond}
+
+	// when
+	engine.Run(ctx)
+
+	// then
+	if len(gotTicks) < 3 {
+		t.Fatalf("unexpected processed ticks: %v", gotTicks)
+	}
+	if diff := cmp.Diff([]int64{0, 0, 1}, gotTicks[:3]); diff != "" {
+		t.Errorf("unexpected processed ticks (-want, +got): %v", diff)
+	}
+}
@@ -0,0 +1,330 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+const (
+	// maxTrainingQueue is the maximum number of training orders a building can have queued
+	maxTrainingQueue = 5
+	// maxTrainingCount is the maximum number of units in a single training order
+	maxTrainingCount = 1000
+)
+
+// unitSpec defines the stats of a unit, and what it takes to train it.
+type unitSpec struct {
+	// Naval units are trained at the Docks, while land units are trained at the Barracks
+	Naval bool
+	// MinLevel is the minimum level of the training building required to train the unit
+	MinLevel     int
+	Cost         Resources
+	TrainingTime time.Duration
+	Attack       int
+	Defense      int
+	// Speed is the number of tiles the unit travels per hour
+	Speed int
+	// Carry is the amount of resources the unit can carry
+	Carry int
+	// Upkeep is the hourly food consumption of the unit
+	Upkeep int
+	// Loyalty is the maximum loyalty the unit takes from a city in a won attack, see conquest.go
+	Loyalty int
+	// Settler units found new cities on colonization missions
+	Settler bool
+	// Vision is the number of tiles around them the scouting units reveal to their player, see vision.go
+	Vision int
+}
+
+// unitSpecs is the unit catalogue, keyed by the unit name in the API.
+var unitSpecs = map[string]*unitSpec{
+	"spearman": {
+		MinLevel: 1, Cost: Resources{Food: 30, Sticks: 40, Stones: 10, Population: 1}, TrainingTime: 2 * time.Minute,
+		Attack: 10, Defense: 25, Speed: 6, Carry: 15, Upkeep: 1,
+	},
+	"swordsman": {
+		MinLevel: 3, Cost: Resources{Food: 40, Sticks: 20, Stones: 60, Population: 1}, TrainingTime: 3 * time.Minute,
+		Attack: 25, Defense: 15, Speed: 5, Carry: 20, Upkeep: 1,
+	},
+	"archer": {
+		MinLevel: 5, Cost: Resources{Food: 35, Sticks: 60, Stones: 10, Population: 1}, TrainingTime: 3 * time.Minute,
+		Attack: 15, Defense: 30, Speed: 6, Carry: 10, Upkeep: 1,
+	},
+	"horseman": {
+		MinLevel: 10, Cost: Resources{Food: 100, Sticks: 40, Stones: 40, Gems: 10, Population: 2}, TrainingTime: 5 * time.Minute,
+		Attack: 50, Defense: 20, Speed: 12, Carry: 60, Upkeep: 2, Vision: 2,
+	},
+	"catapult": {
+		MinLevel: 15, Cost: Resources{Food: 80, Sticks: 200, Stones: 150, Gems: 20, Population: 3}, TrainingTime: 10 * time.Minute,
+		Attack: 80, Defense: 10, Speed: 3, Carry: 0, Upkeep: 3,
+	},
+	"settler": {
+		MinLevel: 5, Cost: Resources{Food: 1000, Sticks: 1000, Stones: 1000, Gems: 100, Population: 20}, TrainingTime: 20 * time.Minute,
+		Attack: 0, Defense: 5, Speed: 4, Carry: 0, Upkeep: 2, Settler: true,
+	},
+	"envoy": {
+		MinLevel: 10, Cost: Resources{Food: 2000, Sticks: 2000, Stones: 2000, Gems: 500, Population: 10}, TrainingTime: 30 * time.Minute,
+		Attack: 0, Defense: 10, Speed: 4, Carry: 0, Upkeep: 5, Loyalty: 35,
+	},
+	"galley": {
+		Naval: true, MinLevel: 1, Cost: Resources{Food: 60, Sticks: 150, Stones: 20, Population: 2}, TrainingTime: 6 * time.Minute,
+		Attack: 30, Defense: 30, Speed: 10, Carry: 100, Upkeep: 2, Vision: 3,
+	},
+	"warship": {
+		Naval: true, MinLevel: 8, Cost: Resources{Food: 100, Sticks: 250, Stones: 80, Gems: 30, Population: 4}, TrainingTime: 12 * time.Minute,
+		Attack: 80, Defense: 60, Speed: 8, Carry: 20, Upkeep: 4, Vision: 2,
+	},
+}
+
+// trainingBuilding returns the name and level of the building where the unit is trained.
+func (s *unitSpec) trainingBuilding(b *Buildings) (string, int) {
+	if s.Naval {
+		return "docks", b.Docks
+	}
+	return "barracks", b.Barracks
+}
+
+// trainingCost returns the resources required to train count units.
+func (s *unitSpec) trainingCost(count int) Resources {
+	return Resources{
+		Food:       s.Cost.Food * count,
+		Sticks:     s.Cost.Sticks * count,
+		Stones:     s.Cost.Stones * count,
+		Gems:       s.Cost.Gems * count,
+		Population: s.Cost.Population * count,
+		Faith:      s.Cost.Faith * count,
+	}
+}
+
+// Training defines a training order in the training queue of a city.
+type Training struct {
+	Unit      string    `json:"unit"`
+	Count     int       `json:"count"`
+	StartTick int64     `json:"startTick"`
+	EndTick   int64     `json:"endTick"`
+	EndsAt    time.Time `json:"endsAt,omitzero"`
+}
+
+// trainingOrderedPayload is the payload of an EventTrainingOrdered.
+type trainingOrderedPayload struct {
+	Unit  string `json:"unit"`
+	Count int    `json:"count"`
+}
+
+// trainingPayload is the payload of an EventUnitsTrained.
+type trainingPayload struct {
+	Unit      string `json:"unit"`
+	Count     int    `json:"count"`
+	StartTick int64  `json:"startTick"`
+	// PlayerID is the owner of the city who ordered the training
+	PlayerID string `json:"playerID,omitempty"`
+}
+
+// trainingFromEvent reads the training scheduled by an EventUnitsTrained.
+func trainingFromEvent(e *Event) (*Training, error) {
+	var p trainingPayload
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return nil, fmt.Errorf("invalid training payload: %w", err)
+	}
+	return &Training{Unit: p.Unit, Count: p.Count, StartTick: p.StartTick, EndTick: e.Tick}, nil
+}
+
+// trainingQueue returns the training orders queued at the same building as the unit.
+func (c *City) trainingQueue(spec *unitSpec) []*Training {
+	var queue []*Training
+	for _, training := range c.Training {
+		if queued, ok := unitSpecs[training.Unit]; ok && queued.Naval == spec.Naval {
+			queue = append(queue, training)
+		}
+	}
+	return queue
+}
+
+// validTraining checks if the city can order the training of units, returning the reason if not.
+func validTraining(c *City, spec *unitSpec, count int) string {
+	if count <= 0 || count > maxTrainingCount {
+		return fmt.Sprintf("count must be between 1 and %d", maxTrainingCount)
+	}
+	building, level := spec.trainingBuilding(c.Buildings)
+	if level < spec.MinLevel {
+		return fmt.Sprintf("requires %s level %d", building, spec.MinLevel)
+	}
+	if len(c.trainingQueue(spec)) >= maxTrainingQueue {
+		return "training queue is full"
+	}
+	cost := spec.trainingCost(count)
+	if !c.Resources.covers(&cost) {
+		return "not enough resources"
+	}
+	return ""
+}
+
+type TrainUnitsRequest struct {
+	Unit  string `json:"unit"`
+	Count int    `json:"count"`
+}
+
+type TrainUnitsResponse struct {
+	Unit  string    `json:"unit"`
+	Count int       `json:"count"`
+	Cost  Resources `json:"cost"`
+}
+
+// TrainUnits orders the training of units at the Barracks or Docks of a city.
+//
+// The endpoint does a non-binding validation and submits the order to the event queue, the resources are
+// only spent once the order is processed, and the units join the city army at the end of the training.
+func (g *GameService) TrainUnits(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
+	defer func() {
+		_ = bodyReader.Close()
+	}()
+
+	req := TrainUnitsRequest{}
+	if err := json.NewDecoder(bodyReader).Decode(&req); err != nil {
+		utils.WithError(w, fmt.Errorf("%w: invalid request body: %w", utils.ErrUserError, err))
+		return
+	}
+	spec, ok := unitSpecs[req.Unit]
+	if !ok {
+		utils.WithError(w, fmt.Errorf("%w: unknown unit: %s", utils.ErrUserError, req.Unit))
+		return
+	}
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		utils.WithError(w, utils.ErrUnauthorized)
+		return
+	}
+
+	city, err := g.Database.GetCity(r.Context(), id)
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if !principal.Owns(city.PlayerID) {
+		utils.WithError(w, utils.ErrForbidden)
+		return
+	}
+
+	if errReason := validTraining(city, spec, req.Count); errReason != "" {
+		utils.WithError(w, fmt.Errorf("%w: %s", utils.ErrUserError, errReason))
+		return
+	}
+
+	event, err := newEvent(EventTrainingOrdered, city.ID, trainingOrderedPayload(req))
+	if err != nil {
+		utils.WithError(w, err)
+		return
+	}
+	if err := g.Database.AddEvent(r.Context(), event); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to order training: %w", err))
+		return
+	}
+
+	rsp := TrainUnitsResponse{Unit: req.Unit, Count: req.Count, Cost: spec.trainingCost(req.Count)}
+	utils.WithDefaultAcceptedHeaders(w)
+	if err := json.NewEncoder(w).Encode(rsp); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+}
+
+// processTrainingOrdered spends the resources of a training order and appends it to the training queue of
+// its building, scheduling the units to be trained at the end of the queue.
+func (e *TickEngine) processTrainingOrdered(state *TickState, event *Event) error {
+	city, ok := state.Cities[event.CityID]
+	if !ok {
+		return nil
+	}
+	var p trainingOrderedPayload
+	if err := json.Unmarshal(event.Payload, &p); err != nil {
+		log.Printf("skipping training order %s: invalid payload: %v", event.Key, err)
+		return nil
+	}
+	spec, ok := unitSpecs[p.Unit]
+	if !ok {
+		log.Printf("skipping training order %s: unknown unit %q", event.Key, p.Unit)
+		return nil
+	}
+	if validTraining(city, spec, p.Count) != "" {
+		return nil
+	}
+
+	cost := spec.trainingCost(p.Count)
+	city.Resources.spend(&cost)
+
+	start := state.Tick
+	if queue := city.trainingQueue(spec); len(queue) > 0 {
+		start = max(start, queue[len(queue)-1].EndTick)
+	}
+	end := start + e.ticks(state.world(city.WorldID).duration(spec.TrainingTime*time.Duration(p.Count)))
+
+	trained, err := event.followUp(EventUnitsTrained, end, trainingPayload{
+		Unit:      p.Unit,
+		Count:     p.Count,
+		StartTick: start,
+		PlayerID:  city.PlayerID,
+	})
+	if err != nil {
+		return err
+	}
+	state.Schedule(trained)
+	city.Training = append(city.Training, &Training{
+		Unit:      p.Unit,
+		Count:     p.Count,
+		StartTick: start,
+		EndTick:   end,
+	})
+	return nil
+}
+
+// processUnitsTrained adds the trained units to the city army and removes the order from the training queue.
+func (e *TickEngine) processUnitsTrained(state *TickState, event *Event) error {
+	city, ok := state.Cities[event.CityID]
+	if !ok || !city.queued(event) {
+		return nil
+	}
+	training, err := trainingFromEvent(event)
+	if err != nil {
+		log.Printf("skipping training %s: %v", event.Key, err)
+		return nil
+	}
+	if _, ok := unitSpecs[training.Unit]; !ok {
+		log.Printf("skipping training %s: unknown unit %q", event.Key, training.Unit)
+		return nil
+	}
+
+	if city.Units == nil {
+		city.Units = make(map[string]int)
+	}
+	city.Units[training.Unit] += training.Count
+	state.Notify(city.PlayerID, NotificationUnitsTrained, city.ID, training)
+
+	for i, queued := range city.Training {
+		if queued.EndTick == training.EndTick && queued.Unit == training.Unit && queued.StartTick == training.StartTick {
+			city.Training = append(city.Training[:i], city.Training[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// unitsUpkeep returns the hourly food consumption of an army.
+func unitsUpkeep(units map[string]int) int {
+	upkeep := 0
+	for unit, count := range units {
+		if spec, ok := unitSpecs[unit]; ok {
+			upkeep += spec.Upkeep * count
+		}
+	}
+	return upkeep
+}
@@ -0,0 +1,229 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+func Test_TrainUnits(t *testing.T) {
+	testcases := []struct {
+		name       string
+		body       string
+		city       *City
+		wantEvent  bool
+		wantStatus int
+		wantBody   []byte
+	}{
+		{
+			name: "success",
+			body: `{"unit":"spearman","count":2}`,
+			city: makeCity(func(c *City) {
+				c.Buildings = &Buildings{Barracks: 1}
+				c.Resources = &Resources{Food: 100, Sticks: 100, Stones: 100, Population: 10}
+			}),
+			wantEvent:  true,
+			wantStatus: 202,
+			wantBody: unsafeToResponseBody(TrainUnitsResponse{
+				Unit: "spearman", Count: 2, Cost: Resources{Food: 60, Sticks: 80, Stones: 20, Population: 2},
+			}),
+		},
+		{
+			name:       "unknown unit",
+			body:       `{"unit":"dragon","count":1}`,
+			wantStatus: 400,
+			wantBody:   []byte("user error: unknown unit: dragon\n"),
+		},
+		{
+			name:       "forbidden",
+			body:       `{"unit":"spearman","count":1}`,
+			city:       makeCity(func(c *City) { c.PlayerID = "another-user" }),
+			wantStatus: 403,
+			wantBody:   []byte("forbidden\n"),
+		},
+		{
+			name: "missing building",
+			body: `{"unit":"galley","count":1}`,
+			city: makeCity(func(c *City) {
+				c.Buildings = &Buildings{Barracks: 1}
+				c.Resources = &Resources{Food: 1000, Sticks: 1000, Stones: 1000, Population: 10}
+			}),
+			wantStatus: 400,
+			wantBody:   []byte("user error: requires docks level 1\n"),
+		},
+		{
+			name: "invalid count",
+			body: `{"unit":"spearman","count":0}`,
+			city: makeCity(func(c *City) {
+				c.Buildings = &Buildings{Barracks: 1}
+				c.Resources = &Resources{}
+			}),
+			wantStatus: 400,
+			wantBody:   []byte("user error: count must be between 1 and 1000\n"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			var gotEvent *Event
+			// given
+			mockDB := &mockDatabase{
+				GetCityFunc: func(id string) (*City, error) {
+					return testcase.city, nil
+				},
+				AddEventFunc: func(e *Event) error {
+					gotEvent = e
+					return nil
+				},
+			}
+			service := &GameService{Database: mockDB}
+
+			// when
+			req := httptest.NewRequest("POST", "/api/cities/123/train", strings.NewReader(testcase.body))
+			req.SetPathValue("id", "123")
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: "test-user"}))
+			service.TrainUnits(rec, req)
+
+			// then
+			if testcase.wantEvent != (gotEvent != nil) {
+				t.Errorf("unexpected event: want %v, got %+v", testcase.wantEvent, gotEvent)
+			}
+			if gotEvent != nil && (gotEvent.Type != EventTrainingOrdered || gotEvent.Tick != 0) {
+				t.Errorf("unexpected event: %+v", gotEvent)
+			}
+			if testcase.wantStatus != rec.Code {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+			if diff := cmp.Diff(testcase.wantBody, rec.Body.Bytes()); diff != "" {
+				t.Errorf("unexpected body diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ProcessTraining(t *testing.T) {
+	order := func(key, unit string, count int) *Event {
+		payload, _ := json.Marshal(trainingOrderedPayload{Unit: unit, Count: count})
+		return &Event{Key: key, Tick: 10, Type: EventTrainingOrdered, CityID: "city", Payload: payload}
+	}
+	trained := func(key, unit string, count int, start, end int64) *Event {
+		payload, _ := json.Marshal(trainingPayload{Unit: unit, Count: count, StartTick: start})
+		return &Event{Key: key + "/" + EventUnitsTrained, Tick: end, Type: EventUnitsTrained, CityID: "city", Payload: payload}
+	}
+
+	testcases := []struct {
+		name          string
+		events        []*Event
+		city          *City
+		wantCity      *City
+		wantNewEvents []*Event
+	}{
+		{
+			name:   "barracks and docks have their own queues",
+			events: []*Event{order("a", "spearman", 2), order("b", "spearman", 1), order("c", "galley", 1)},
+			city: &City{
+				ID:        "city",
+				Buildings: &Buildings{Barracks: 1, Docks: 1},
+				Resources: &Resources{Food: 200, Sticks: 500, Stones: 100, Population: 10},
+			},
+			wantCity: &City{
+				ID:        "city",
+				Buildings: &Buildings{Barracks: 1, Docks: 1},
+				Resources: &Resources{Food: 50, Sticks: 230, Stones: 50, Population: 5},
+				Training: []*Training{
+					{Unit: "spearman", Count: 2, StartTick: 10, EndTick: 14},
+					{Unit: "spearman", Count: 1, StartTick: 14, EndTick: 16},
+					{Unit: "galley", Count: 1, StartTick: 10, EndTick: 16},
+				},
+			},
+			wantNewEvents: []*Event{
+				trained("a", "spearman", 2, 10, 14),
+				trained("b", "spearman", 1, 14, 16),
+				trained("c", "galley", 1, 10, 16),
+			},
+		},
+		{
+			name:   "training requires the building level",
+			events: []*Event{order("a", "horseman", 1)},
+			city: &City{
+				ID:        "city",
+				Buildings: &Buildings{Barracks: 9},
+				Resources: &Resources{Food: 1000, Sticks: 1000, Stones: 1000, Gems: 1000, Population: 10},
+			},
+			wantCity: &City{
+				ID:        "city",
+				Buildings: &Buildings{Barracks: 9},
+				Resources: &Resources{Food: 1000, Sticks: 1000, Stones: 1000, Gems: 1000, Population: 10},
+			},
+		},
+		{
+			name:   "trained units join the army",
+			events: []*Event{trained("a", "spearman", 2, 6, 10)},
+			city: &City{
+				ID:        "city",
+				Buildings: &Buildings{},
+				Resources: &Resources{},
+				Units:     map[string]int{"spearman": 3},
+				Training:  []*Training{{Unit: "spearman", Count: 2, StartTick: 6, EndTick: 10}},
+			},
+			wantCity: &City{
+				ID:        "city",
+				Buildings: &Buildings{},
+				Resources: &Resources{},
+				Units:     map[string]int{"spearman": 5},
+				Training:  []*Training{},
+			},
+		},
+		{
+			name: "units ordered by a previous owner are lost",
+			events: []*Event{{
+				Key: "a/" + EventUnitsTrained, Tick: 10, Type: EventUnitsTrained, CityID: "city",
+				Payload: json.RawMessage(`{"unit":"spearman","count":2,"startTick":6,"playerID":"former-owner"}`),
+			}},
+			city: &City{
+				ID:        "city",
+				PlayerID:  "player",
+				Buildings: &Buildings{},
+				Resources: &Resources{},
+				Units:     map[string]int{"spearman": 3},
+			},
+			wantCity: &City{
+				ID:        "city",
+				PlayerID:  "player",
+				Buildings: &Buildings{},
+				Resources: &Resources{},
+				Units:     map[string]int{"spearman": 3},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			engine := &TickEngine{TickDuration: time.Minute}
+			state := &TickState{Tick: 10, Events: testcase.events, Cities: map[string]*City{"city": testcase.city}}
+
+			// when
+			for _, event := range state.Events {
+				if err := engine.processEvent(state, event); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			// then
+			if diff := cmp.Diff(testcase.wantCity, state.Cities["city"]); diff != "" {
+				t.Errorf("unexpected city diff (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(testcase.wantNewEvents, state.NewEvents); diff != "" {
+				t.Errorf("unexpected new events diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/game/hex"
+)
+
+// The fog of war: a player sees the tiles of a world around their cities and their scouting units on the move,
+// and their map only has the tiles they explored, i.e., the ones they saw at least once. The cities outside of
+// their vision are shown as they were when last seen.
+
+const (
+	// cityVision is the radius of the tiles every city sees around it
+	cityVision = 3
+	// observatoryLevelsPerVision is the number of Observatory levels that extend the vision of a city by a tile
+	observatoryLevelsPerVision = 2
+	// sightingInterval is how often a city in sight is sighted again when nothing changed, i.e., how precise the
+	// time it was last seen is
+	sightingInterval = time.Minute
+)
+
+// sight is the tiles within the radius of the centre.
+type sight struct {
+	center hex.Hex
+	radius int
+}
+
+// vision is what a player sees of a world.
+type vision struct {
+	world *World
+	// all is set for the players who see the whole world without exploring it, i.e., the admins
+	all    bool
+	sights []sight
+}
+
+// visionCache keeps the vision of the players until the next tick, along with the tiles they explored, so the
+// vision is neither computed nor explored again by every request of the tick.
+type visionCache struct {
+	mu      sync.Mutex
+	visions map[[2]string]cachedVision
+}
+
+// cachedVision is the vision of a player at a tick, from the cities they had in the world.
+type cachedVision struct {
+	tick     int64
+	cities   []string
+	vision   *vision
+	explored []byte
+}
+
+// get returns the cached vision of the player in the world, if any.
+func (c *visionCache) get(worldID, playerID string) (cachedVision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.visions[[2]string{worldID, playerID}]
+	return cached, ok
+}
+
+// put caches the vision of the player in the world, replacing the one of an earlier tick along with the tiles
+// explored then, since other instances may have explored more since.
+func (c *visionCache) put(worldID, playerID string, cached cachedVision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.visions == nil {
+		c.visions = map[[2]string]cachedVision{}
+	}
+	c.visions[[2]string{worldID, playerID}] = cached
+}
+
+// explored returns the tiles the player explored in the world as cached, nil if not cached.
+func (c *visionCache) explored(worldID, playerID string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.visions[[2]string{worldID, playerID}].explored
+}
+
+// explore caches the tiles the player explored along with their vision of the tick.
+func (c *visionCache) explore(worldID, playerID string, tiles []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := [2]string{worldID, playerID}
+	if cached, ok := c.visions[key]; ok {
+		cached.explored = tiles
+		c.visions[key] = cached
+	}
+}
+
+// sees returns whether the player sees the tile.
+func (v *vision) sees(q, r int) bool {
+	if !v.world.contains(q, r) {
+		return false
+	}
+	if v.all {
+		return true
+	}
+	for _, s := range v.sights {
+		if hex.Distance(s.center, hex.Hex{Q: q, R: r}) <= s.radius {
+			return true
+		}
+	}
+	return false
+}
+
+// explore returns the explored tiles along with the ones the player sees.
+func (v *vision) explore(explored []byte) []byte {
+	tiles := make([]byte, (v.world.Size*v.world.Size+7)/8)
+	copy(tiles, explored)
+	for _, s := range v.sights {
+		for _, h := range hex.Range(s.center, s.radius) {
+			if v.world.contains(h.Q, h.R) {
+				i, bit := exploredBit(v.world, h.Q, h.R)
+				tiles[i] |= bit
+			}
+		}
+	}
+	return tiles
+}
+
+// exploredBit returns the byte and the bit of a tile in the explored tiles of a player, a bitset of the tiles
+// of the world by q and then r, most significant bit first as a BIT VARYING of Postgres.
+func exploredBit(world *World, q, r int) (int, byte) {
+	i := q*world.Size + r
+	return i / 8, 1 << (7 - i%8)
+}
+
+// explored returns whether the tile of the world is one of the explored tiles.
+func explored(world *World, tiles []byte, q, r int) bool {
+	if !world.contains(q, r) {
+		return false
+	}
+	i, bit := exploredBit(world, q, r)
+	return i < len(tiles) && tiles[i]&bit != 0
+}
+
+// unitsVision returns the vision of the units, that of the scouting unit that sees the furthest.
+func unitsVision(units map[string]int) int {
+	vision := 0
+	for unit, count := range units {
+		if spec, ok := unitSpecs[unit]; ok && count > 0 {
+			vision = max(vision, spec.Vision)
+		}
+	}
+	return vision
+}
+
+// cityVisionRadius returns the radius of the tiles the city sees, extended by its Observatory and by the
+// scouting units stationed in it.
+func cityVisionRadius(c *City) int {
+	radius := cityVision + unitsVision(c.Units)
+	if c.Buildings != nil {
+		radius += c.Buildings.Observatory / observatoryLevelsPerVision
+	}
+	return radius
+}
+
+// position returns the tile the movement is on at the tick, along the straight line between its origin and its
+// target. A returning movement is taken to have turned back at its target.
+func (m *Movement) position(origin hex.Hex, tick int64) hex.Hex {
+	line := hex.Line(origin, hex.Hex{Q: m.Q, R: m.R})
+	if m.Returning {
+		slices.Reverse(line)
+	}
+	total := m.ArrivalTick - m.StartTick
+	if total <= 0 {
+		return line[len(line)-1]
+	}
+	travelled := min(max(tick-m.StartTick, 0), total)
+	return line[int64(len(line)-1)*travelled/total]
+}
+
+// playerVision returns what the player sees of the world, from their cities and from the scouting units they
+// sent out of them. The vision is cached until the next tick, or until the player has other cities.
+func (g *GameService) playerVision(ctx context.Context, world *World, principal *auth.Principal) (*vision, error) {
+	v := &vision{world: world}
+	if principal.HasAnyRole(auth.RoleAdmin) {
+		v.all = true
+		return v, nil
+	}
+
+	cities, err := g.Database.GetPlayerCities(ctx, principal.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player cities: %w", err)
+	}
+	var ids []string
+	for _, c := range cities {
+		if c.WorldID == world.ID {
+			ids = append(ids, c.ID)
+		}
+	}
+	slices.Sort(ids)
+	clock, err := g.Database.GetClock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clock: %w", err)
+	}
+	cached, ok := g.visions.get(world.ID, principal.UserID)
+	if ok && cached.tick == clock.LastTick && slices.Equal(cached.cities, ids) {
+		return cached.vision, nil
+	}
+
+	for _, id := range ids {
+		city, err := g.Database.GetCity(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get city: %w", err)
+		}
+		origin := hex.Hex{Q: city.Q, R: city.R}
+		v.sights = append(v.sights, sight{center: origin, radius: cityVisionRadius(city)})
+
+		movements, err := g.Database.GetCityMovements(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get movements: %w", err)
+		}
+		for _, m := range movements {
+			radius := unitsVision(m.Units)
+			if m.CityID != id || radius == 0 {
+				continue
+			}
+			v.sights = append(v.sights, sight{center: m.position(origin, clock.LastTick), radius: radius})
+		}
+	}
+	g.visions.put(world.ID, principal.UserID, cachedVision{tick: clock.LastTick, cities: ids, vision: v})
+	return v, nil
+}
+
+// exploreVision records the tiles the player sees as explored, along with the cities of other players they see
+// as sighted now, and returns all the tiles they explored so far. The sighted cities are the last sightings the
+// player has of them, and only the new or changed ones, or the ones sighted longer than the sightingInterval ago,
+// are recorded again. Nothing is recorded for the players who see everything.
+func (g *GameService) exploreVision(ctx context.Context, v *vision, playerID string, seen, sighted []*City) ([]byte, error) {
+	if v.all {
+		return nil, nil
+	}
+	known := g.visions.explored(v.world.ID, playerID)
+	if known == nil {
+		var err error
+		if known, err = g.Database.GetExplored(ctx, v.world.ID, playerID); err != nil {
+			return nil, fmt.Errorf("failed to get explored tiles: %w", err)
+		}
+	}
+	tiles := v.explore(known)
+
+	now := time.Now()
+	last := make(map[string]*City, len(sighted))
+	for _, c := range sighted {
+		last[c.ID] = c
+	}
+	var sightings []*City
+	for _, c := range seen {
+		if c.PlayerID == playerID {
+			continue
+		}
+		sighting := cityRow(c)
+		if s, ok := last[c.ID]; ok && s.LastSeen != nil && now.Sub(*s.LastSeen) < sightingInterval &&
+			reflect.DeepEqual(sighting, cityRow(s)) {
+			continue
+		}
+		sighting.LastSeen = &now
+		sightings = append(sightings, sighting)
+	}
+
+	if !bytes.Equal(known, tiles) || len(sightings) > 0 {
+		if err := g.Database.Explore(ctx, v.world.ID, playerID, tiles, sightings); err != nil {
+			return nil, fmt.Errorf("failed to explore: %w", err)
+		}
+	}
+	g.visions.explore(v.world.ID, playerID, tiles)
+	return tiles, nil
+}
@@ -0,0 +1,211 @@
+package game
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/game/hex"
+)
+
+// mockVision sets up the database of a player with the cities, and no movements, who explored the tiles. The clock
+// is at the first tick unless set.
+func mockVision(db *mockDatabase, tiles []byte, cities ...*City) {
+	if db.GetClockFunc == nil {
+		db.GetClockFunc = func() (*Clock, error) {
+			return &Clock{}, nil
+		}
+	}
+	db.GetPlayerCitiesFunc = func(playerID string) ([]*City, error) {
+		return cities, nil
+	}
+	db.GetCityFunc = func(id string) (*City, error) {
+		for _, c := range cities {
+			if c.ID == id {
+				return c, nil
+			}
+		}
+		return nil, errors.New("unexpected city")
+	}
+	db.GetCityMovementsFunc = func(cityID string) ([]*Movement, error) {
+		return nil, nil
+	}
+	db.GetExploredFunc = func(playerID string) ([]byte, error) {
+		return tiles, nil
+	}
+}
+
+func Test_PlayerVision(t *testing.T) {
+	capital := makeCity(func(c *City) {
+		c.ID, c.WorldID = "capital", DefaultWorldID
+		c.Buildings = &Buildings{Observatory: 5}
+		c.Units = map[string]int{"spearman": 10, "horseman": 1}
+	})
+	colony := makeCity(func(c *City) { c.ID, c.WorldID, c.Q, c.R = "colony", "another-world", 1, 1 })
+	scouts := &Movement{
+		ID: "scouts", CityID: "capital", PlayerID: "test-user", Mission: MissionAttack, Q: 15, R: 5,
+		Units: map[string]int{"horseman": 5}, StartTick: 0, ArrivalTick: 10,
+	}
+	testcases := []struct {
+		name      string
+		principal *auth.Principal
+		movements []*Movement
+		want      *vision
+	}{
+		{
+			name:      "cities of the world",
+			principal: &auth.Principal{UserID: "test-user"},
+			// the base vision, two tiles from the Observatory and two from the horseman
+			want: &vision{world: DefaultWorld, sights: []sight{{center: hex.Hex{Q: 5, R: 5}, radius: 7}}},
+		},
+		{
+			name:      "scouting units on the move",
+			principal: &auth.Principal{UserID: "test-user"},
+			movements: []*Movement{
+				scouts,
+				{ID: "spearmen", CityID: "capital", Q: 5, R: 15, Units: map[string]int{"spearman": 10}, ArrivalTick: 10},
+				{ID: "enemy", CityID: "enemy", TargetCityID: "capital", Q: 5, R: 5, Units: map[string]int{"horseman": 10}, ArrivalTick: 10},
+			},
+			want: &vision{world: DefaultWorld, sights: []sight{
+				{center: hex.Hex{Q: 5, R: 5}, radius: 7},
+				// halfway to the target at tick 5
+				{center: hex.Hex{Q: 10, R: 5}, radius: 2},
+			}},
+		},
+		{
+			name:      "admins see everything",
+			principal: &auth.Principal{UserID: "admin", Roles: []string{auth.RoleAdmin}},
+			want:      &vision{world: DefaultWorld, all: true},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			mockDB := &mockDatabase{
+				GetClockFunc: func() (*Clock, error) {
+					return &Clock{LastTick: 5}, nil
+				},
+			}
+			mockVision(mockDB, nil, capital, colony)
+			mockDB.GetCityMovementsFunc = func(cityID string) ([]*Movement, error) {
+				return testcase.movements, nil
+			}
+			service := &GameService{Database: mockDB}
+
+			// when
+			got, err := service.playerVision(t.Context(), DefaultWorld, testcase.principal)
+
+			// then
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testcase.want, got, cmp.AllowUnexported(vision{}, sight{})); diff != "" {
+				t.Errorf("unexpected vision diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ExploreVision(t *testing.T) {
+	// given
+	capital := makeCity(func(c *City) { c.ID, c.WorldID = "capital", DefaultWorldID })
+	clock := &Clock{LastTick: 5}
+	var gotCities, gotExplored, gotExplores int
+	mockDB := &mockDatabase{
+		GetClockFunc: func() (*Clock, error) {
+			return clock, nil
+		},
+	}
+	var explored []byte
+	mockVision(mockDB, nil, capital)
+	getCity := mockDB.GetCityFunc
+	mockDB.GetCityFunc = func(id string) (*City, error) {
+		gotCities++
+		return getCity(id)
+	}
+	mockDB.GetExploredFunc = func(playerID string) ([]byte, error) {
+		gotExplored++
+		return explored, nil
+	}
+	mockDB.ExploreFunc = func(playerID string, tiles []byte, sightings []*City) error {
+		gotExplores++
+		explored = tiles
+		return nil
+	}
+	service := &GameService{Database: mockDB}
+	principal := &auth.Principal{UserID: "test-user"}
+	explore := func() {
+		v, err := service.playerVision(t.Context(), DefaultWorld, principal)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := service.exploreVision(t.Context(), v, principal.UserID, nil, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// when
+	explore()
+	explore()
+
+	// then
+	if gotCities != 1 || gotExplored != 1 || gotExplores != 1 {
+		t.Errorf("unexpected queries in a tick: %v cities, %v explored, %v explores", gotCities, gotExplored, gotExplores)
+	}
+
+	// when
+	clock = &Clock{LastTick: 6}
+	explore()
+
+	// then
+	if gotCities != 2 || gotExplored != 2 || gotExplores != 1 {
+		t.Errorf("unexpected queries in the next tick: %v cities, %v explored, %v explores", gotCities, gotExplored, gotExplores)
+	}
+}
+
+func Test_Explore(t *testing.T) {
+	small := &World{ID: "small", Size: 4}
+	v := &vision{world: small, sights: []sight{{center: hex.Hex{Q: 0, R: 0}, radius: 1}}}
+
+	// the tiles by q and then r, where (0, 0), (0, 1) and (1, 0) are in sight, and (3, 3) was explored before
+	got := v.explore([]byte{0b00000000, 0b00000001})
+
+	if diff := cmp.Diff([]byte{0b11001000, 0b00000001}, got); diff != "" {
+		t.Errorf("unexpected explored tiles diff (-want, +got): %v", diff)
+	}
+	for _, tile := range []hex.Hex{{Q: 0, R: 0}, {Q: 0, R: 1}, {Q: 1, R: 0}, {Q: 3, R: 3}} {
+		if !explored(small, got, tile.Q, tile.R) {
+			t.Errorf("tile %v is not explored", tile)
+		}
+	}
+	if explored(small, got, 1, 1) || explored(small, got, 4, 0) {
+		t.Errorf("unexpected explored tiles %08b", got)
+	}
+}
+
+func Test_Position(t *testing.T) {
+	testcases := []struct {
+		name      string
+		returning bool
+		tick      int64
+		want      hex.Hex
+	}{
+		{name: "at the start", tick: 10, want: hex.Hex{Q: 0, R: 0}},
+		{name: "on the way", tick: 13, want: hex.Hex{Q: 1, R: 0}},
+		{name: "at the target", tick: 16, want: hex.Hex{Q: 3, R: 0}},
+		{name: "not ordered yet", tick: 5, want: hex.Hex{Q: 0, R: 0}},
+		{name: "on the way back", returning: true, tick: 13, want: hex.Hex{Q: 2, R: 0}},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			m := &Movement{Q: 3, R: 0, StartTick: 10, ArrivalTick: 16, Returning: testcase.returning}
+			if got := m.position(hex.Hex{Q: 0, R: 0}, testcase.tick); got != testcase.want {
+				t.Errorf("unexpected position: want %v, got %v", testcase.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+// DefaultWorldID is the ID of the classic world, the one of the server before there were several worlds.
+const DefaultWorldID = "00000000-0000-0000-0000-000000000001"
+
+const (
+	// WorldOpen is the status of a world accepting new players
+	WorldOpen = "open"
+	// WorldClosed is the status of a running world that does not accept new players
+	WorldClosed = "closed"
+	// WorldEnded is the status of a world whose game is over, kept for its history
+	WorldEnded = "ended"
+)
+
+// ErrWorldExists is returned by the database when creating the tiles of a world that already has them.
+var ErrWorldExists = errors.New("world already exists")
+
+// World defines a game world, with its own map and cities, running alongside the other worlds of the server.
+type World struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	Speed  int    `json:"speed"`
+	Status string `json:"status"`
+	// StartsAt is when players can start joining the world, which is listed beforehand
+	StartsAt time.Time  `json:"startsAt"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+	// Placement is the name of the strategy that places the first city of new players, see ParsePlacementStrategy
+	Placement string `json:"placement"`
+}
+
+// DefaultWorld is the classic world, at normal speed.
+var DefaultWorld = &World{ID: DefaultWorldID, Name: "classic", Size: 256, Speed: 1, Status: WorldOpen, Placement: "spawn"}
+
+// listed returns whether the world is open for new players, now or once it starts.
+func (w *World) listed(now time.Time) bool {
+	return w.Status == WorldOpen && (w.EndsAt == nil || now.Before(*w.EndsAt))
+}
+
+// joinable returns whether new players can join the world.
+func (w *World) joinable(now time.Time) bool {
+	return w.listed(now) && !now.Before(w.StartsAt)
+}
+
+// placement returns the strategy that places the first city of new players in the world, DefaultPlacement if
+// the world does not name one.
+func (w *World) placement() (PlacementStrategy, error) {
+	if w.Placement == "" {
+		return DefaultPlacement, nil
+	}
+	return ParsePlacementStrategy(w.Placement)
+}
+
+// contains returns whether the tile is inside the world.
+func (w *World) contains(q, r int) bool {
+	return q >= 0 && q < w.Size && r >= 0 && r < w.Size
+}
+
+// speed returns the speed multiplier of the world, at least the normal speed.
+func (w *World) speed() int {
+	return max(1, w.Speed)
+}
+
+// duration returns how long something that takes d at normal speed takes in the world.
+func (w *World) duration(d time.Duration) time.Duration {
+	return d / time.Duration(w.speed())
+}
+
+// capitalID returns the ID of the first city of a player in a world. In the classic world it is the player ID
+// itself, as it was before there were several worlds, while in the others it is derived from both IDs.
+func capitalID(worldID, playerID string) string {
+	if worldID == DefaultWorldID {
+		return playerID
+	}
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(worldID+"/"+playerID)).String()
+}
+
+// worldParam returns the world of the world query parameter of the request, the classic world if not set.
+func (g *GameService) worldParam(r *http.Request) (*World, error) {
+	id := r.URL.Query().Get("world")
+	if id == "" {
+		id = DefaultWorldID
+	}
+	return g.Database.GetWorld(r.Context(), id)
+}
+
+// GetWorlds lists the worlds open for new players, including the ones that did not start yet.
+func (g *GameService) GetWorlds(w http.ResponseWriter, r *http.Request) {
+	worlds, err := g.Database.GetWorlds(r.Context())
+	if err != nil {
+		utils.WithError(w, fmt.Errorf("failed to get worlds: %w", err))
+		return
+	}
+
+	now := time.Now()
+	open := []*World{}
+	for _, world := range worlds {
+		if world.listed(now) {
+			open = append(open, world)
+		}
+	}
+
+	utils.WithDefaultOKHeaders(w)
+	if err := json.NewEncoder(w).Encode(open); err != nil {
+		utils.WithError(w, fmt.Errorf("failed to encode worlds: %w", err))
+		return
+	}
+}
@@ -0,0 +1,69 @@
+package game
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_GetWorlds(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	blitz := &World{ID: "blitz", Name: "blitz", Size: 64, Speed: 10, Status: WorldOpen, EndsAt: &future}
+	upcoming := &World{ID: "upcoming", Name: "upcoming", Size: 64, Speed: 1, Status: WorldOpen, StartsAt: future}
+	closed := &World{ID: "closed", Name: "closed", Size: 64, Speed: 1, Status: WorldClosed}
+	over := &World{ID: "over", Name: "over", Size: 64, Speed: 1, Status: WorldOpen, EndsAt: &past}
+
+	testcases := []struct {
+		name       string
+		mockRes    []*World
+		mockErr    error
+		wantStatus int
+		wantBody   []byte
+	}{
+		{
+			name:       "open worlds",
+			mockRes:    []*World{DefaultWorld, blitz, upcoming, closed, over},
+			wantStatus: 200,
+			wantBody:   unsafeToResponseBody([]*World{DefaultWorld, blitz, upcoming}),
+		},
+		{
+			name:       "no open worlds",
+			mockRes:    []*World{closed},
+			wantStatus: 200,
+			wantBody:   unsafeToResponseBody([]*World{}),
+		},
+		{
+			name:       "database error",
+			mockErr:    errors.New("a database error"),
+			wantStatus: 500,
+			wantBody:   []byte("failed to get worlds: a database error\n"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			// given
+			mockDB := &mockDatabase{
+				GetWorldsFunc: func() ([]*World, error) {
+					return testcase.mockRes, testcase.mockErr
+				},
+			}
+			service := &GameService{Database: mockDB}
+
+			// when
+			service.GetWorlds(rec, httptest.NewRequest("GET", "/api/worlds", nil))
+
+			// then
+			if testcase.wantStatus != rec.Code {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+			if diff := cmp.Diff(testcase.wantBody, rec.Body.Bytes()); diff != "" {
+				t.Errorf("unexpected body diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package mail sends emails to the users, e.g., to verify their email address.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message defines a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// SMTPMailer sends the emails through an SMTP server, authenticating with PLAIN auth if a username is set.
+type SMTPMailer struct {
+	// Address of the SMTP server, e.g., smtp.example.com:587
+	Address  string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPMailer) Send(_ context.Context, m *Message) error {
+	if strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := strings.Cut(s.Address, ":")
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	msg := "From: " + s.From + "\r\n" +
+		"To: " + m.To + "\r\n" +
+		"Subject: " + m.Subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(m.Body, "\n", "\r\n")
+	return smtp.SendMail(s.Address, auth, s.From, []string{m.To}, []byte(msg))
+}
+
+// WriterMailer writes the emails to a writer (e.g., the stdout or a file) instead of sending them, for local
+// development.
+type WriterMailer struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+func (s *WriterMailer) Send(_ context.Context, m *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.W, "To: %s\nSubject: %s\n\n%s\n\n", m.To, m.Subject, m.Body)
+	return err
+}
@@ -0,0 +1,94 @@
+// Package ratelimit limits how often something may happen per key (e.g., requests per IP) with token buckets.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows a burst of Requests, refilled evenly over Per, e.g., 10 requests per minute refills one request
+// every 6 seconds.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses a limit written as requests/duration, e.g., 10/1m.
+func ParseLimit(s string) (Limit, error) {
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected requests/duration", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q requests", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q duration", s)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per key. Buckets are created full, and the full ones are dropped over time, such
+// that the memory only grows with the keys that are currently limited.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     Limit
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	// now is replaced by the tests
+	now func() time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// refill returns the tokens of the bucket at the given time.
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	refilled := float64(now.Sub(b.last)) / float64(l.limit.Per) * float64(l.limit.Requests)
+	return min(b.tokens+refilled, float64(l.limit.Requests))
+}
+
+// Allow takes a token of the key, if there is one, otherwise it returns how long until there will be one.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= l.limit.Per {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Requests), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens, b.last = l.refill(b, now), now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	missing := (1 - b.tokens) / float64(l.limit.Requests) * float64(l.limit.Per)
+	return false, time.Duration(missing)
+}
+
+// sweep drops the buckets that are full again, since they are the same as new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
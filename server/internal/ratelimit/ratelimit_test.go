@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ParseLimit(t *testing.T) {
+	testcases := []struct {
+		name      string
+		limit     string
+		wantLimit Limit
+		wantErr   bool
+	}{
+		{
+			name:      "requests per minute",
+			limit:     "10/1m",
+			wantLimit: Limit{Requests: 10, Per: time.Minute},
+		},
+		{
+			name:    "missing duration",
+			limit:   "10",
+			wantErr: true,
+		},
+		{
+			name:    "no requests",
+			limit:   "0/1m",
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			limit:   "10/minute",
+			wantErr: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			got, err := ParseLimit(testcase.limit)
+			if (err != nil) != testcase.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testcase.wantLimit, got); diff != "" {
+				t.Errorf("unexpected limit diff (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+func Test_Limiter(t *testing.T) {
+	// given
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(Limit{Requests: 2, Per: time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	allow := func(key string, wantOK bool, wantRetryAfter time.Duration) {
+		t.Helper()
+		ok, retryAfter := limiter.Allow(key)
+		if ok != wantOK || retryAfter != wantRetryAfter {
+			t.Errorf("unexpected allow of %s: want %v, %v, got %v, %v", key, wantOK, wantRetryAfter, ok, retryAfter)
+		}
+	}
+
+	// when, then
+	allow("a", true, 0)
+	allow("a", true, 0)
+	allow("a", false, 30*time.Second)
+	allow("b", true, 0)
+
+	now = now.Add(20 * time.Second)
+	allow("a", false, 10*time.Second)
+
+	now = now.Add(10 * time.Second)
+	allow("a", true, 0)
+	allow("a", false, 30*time.Second)
+
+	now = now.Add(time.Hour)
+	allow("c", true, 0)
+	if len(limiter.buckets) != 1 {
+		t.Errorf("unexpected buckets after the sweep: %v", limiter.buckets)
+	}
+}
@@ -4,40 +4,288 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var (
-	errUserNotFound = fmt.Errorf("user not found")
+	// ErrUserNotFound is returned by the databases when there is no user with the given email
+	ErrUserNotFound = fmt.Errorf("user not found")
+	// ErrSessionNotFound is returned by the databases when there is no session with the given refresh token hash
+	ErrSessionNotFound = fmt.Errorf("session not found")
+	// ErrSessionInactive is returned by the databases when rotating a session that was already rotated or revoked
+	ErrSessionInactive = fmt.Errorf("session inactive")
+	// ErrTokenInvalid is returned by the databases when a user token does not exist, was already used, or expired
+	ErrTokenInvalid = fmt.Errorf("invalid token")
 )
 
 type UserDatabase interface {
 	WriteUser(ctx context.Context, u *User) error
 	GetUser(ctx context.Context, email string) (*User, error)
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	// SetUserRoles replaces the roles of the user, returning ErrUserNotFound if there is no such user.
+	SetUserRoles(ctx context.Context, id string, roles []string) error
+	// AttemptLogin counts a login attempt on the email from the client and returns zero, unless the email is locked
+	// out from the client (see lockoutAfter), in which case it returns how long until the next attempt instead.
+	// The attempts that get past the lockout of the client are counted from anyClient as well, which locks out the
+	// email from every client after maxAccountLoginAttempts. Checking and counting is atomic, such that concurrent
+	// attempts cannot get past the lockout.
+	AttemptLogin(ctx context.Context, email, client string, at time.Time) (time.Duration, error)
+	// ResetLoginAttempts forgets the login attempts on the email from the client, and from anyClient, after
+	// logging in.
+	ResetLoginAttempts(ctx context.Context, email, client string) error
+
+	CreateSession(ctx context.Context, s *Session) error
+	GetSession(ctx context.Context, tokenHash []byte) (*Session, error)
+	// RotateSession marks the session as rotated at the creation time of the next session, and creates the next
+	// one, atomically. If the session was already rotated or revoked, it returns ErrSessionInactive.
+	RotateSession(ctx context.Context, id string, next *Session) error
+	RevokeSessionFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeUserSessions(ctx context.Context, userID string, at time.Time) error
+
+	CreateUserToken(ctx context.Context, t *UserToken) error
+	// VerifyEmail uses the email verification token and validates the email of its user, atomically, returning
+	// the user ID. If the token is not usable at the given time, it returns ErrTokenInvalid.
+	VerifyEmail(ctx context.Context, tokenHash []byte, at time.Time) (string, error)
+	// ResetPassword uses the password reset token and changes the password of its user as ChangePassword, atomically,
+	// returning the user ID. If the token is not usable at the given time, it returns ErrTokenInvalid.
+	ResetPassword(ctx context.Context, tokenHash, hashedPassword []byte, at time.Time) (string, error)
+	// ChangePassword changes the password of the user and revokes all of their sessions and password reset tokens,
+	// and unlocks the account.
+	ChangePassword(ctx context.Context, userID string, hashedPassword []byte, at time.Time) error
 }
 
 type PostgresDatabase struct {
-	DB *pgx.Conn
+	DB *pgxpool.Pool
 }
 
-const writeUserQuery = "INSERT INTO users (id, email, validated_email, username, hashed_password) VALUES ($1, $2, $3, $4, $5)"
+const writeUserQuery = "INSERT INTO users (id, email, validated_email, username, hashed_password, roles) VALUES ($1, $2, $3, $4, $5, COALESCE($6::TEXT[], '{}'))"
 
 func (db *PostgresDatabase) WriteUser(ctx context.Context, u *User) error {
-	_, err := db.DB.Exec(ctx, writeUserQuery, u.ID, u.Email, u.ValidatedEmail, u.Username, u.HashedPassword)
+	_, err := db.DB.Exec(ctx, writeUserQuery, u.ID, u.Email, u.ValidatedEmail, u.Username, u.HashedPassword, u.Roles)
 	return err
 }
 
-const getUserQuery = "SELECT id, email, validated_email, username, hashed_password FROM users WHERE email = $1"
+const getUserQuery = "SELECT id, email, validated_email, username, hashed_password, roles FROM users WHERE email = $1"
 
 func (db *PostgresDatabase) GetUser(ctx context.Context, email string) (*User, error) {
-	row := db.DB.QueryRow(ctx, getUserQuery, email)
+	return scanUser(db.DB.QueryRow(ctx, getUserQuery, email))
+}
+
+const getUserByIDQuery = "SELECT id, email, validated_email, username, hashed_password, roles FROM users WHERE id = $1"
+
+func (db *PostgresDatabase) GetUserByID(ctx context.Context, id string) (*User, error) {
+	return scanUser(db.DB.QueryRow(ctx, getUserByIDQuery, id))
+}
+
+func scanUser(row pgx.Row) (*User, error) {
 	var u User
-	err := row.Scan(&u.ID, &u.Email, &u.ValidatedEmail, &u.Username, &u.HashedPassword)
+	err := row.Scan(&u.ID, &u.Email, &u.ValidatedEmail, &u.Username, &u.HashedPassword, &u.Roles)
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, errUserNotFound
+		return nil, ErrUserNotFound
 	} else if err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
+
+const setUserRolesQuery = "UPDATE users SET roles = $2 WHERE id = $1"
+
+func (db *PostgresDatabase) SetUserRoles(ctx context.Context, id string, roles []string) error {
+	if roles == nil {
+		roles = []string{}
+	}
+	tag, err := db.DB.Exec(ctx, setUserRolesQuery, id, roles)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// attemptLoginQuery counts the attempt unless the email is locked out from the client, setting the lockout of the
+// next attempt as in lockoutAfter with the allowed attempts, in a single statement
+const attemptLoginQuery = `INSERT INTO login_attempts AS a (email, client, attempts, locked_until) VALUES ($1, $2, 1, $3)
+	ON CONFLICT (email, client) DO UPDATE SET
+	attempts = a.attempts + 1,
+	locked_until = $3 + make_interval(secs => CASE WHEN a.attempts + 1 < $4::int THEN 0
+		ELSE LEAST($5::float8 * power(2, LEAST(a.attempts + 1 - $4::int, 6)), $6::float8) END)
+	WHERE a.locked_until <= $3
+	RETURNING attempts`
+
+const getLoginLockoutQuery = "SELECT locked_until FROM login_attempts WHERE email = $1 AND client = $2"
+
+func (db *PostgresDatabase) AttemptLogin(ctx context.Context, email, client string, at time.Time) (time.Duration, error) {
+	// the client goes first, such that the attempts of a client locked out do not count towards the account
+	retryAfter, err := db.attemptLogin(ctx, email, client, maxLoginAttempts, at)
+	if err != nil || retryAfter > 0 {
+		return retryAfter, err
+	}
+	return db.attemptLogin(ctx, email, anyClient, maxAccountLoginAttempts, at)
+}
+
+func (db *PostgresDatabase) attemptLogin(ctx context.Context, email, client string, allowed int, at time.Time) (time.Duration, error) {
+	var attempts int
+	err := db.DB.QueryRow(ctx, attemptLoginQuery,
+		email, client, at, allowed, loginLockout.Seconds(), maxLoginLockout.Seconds(),
+	).Scan(&attempts)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+
+	var lockedUntil time.Time
+	if err := db.DB.QueryRow(ctx, getLoginLockoutQuery, email, client).Scan(&lockedUntil); err != nil {
+		return 0, err
+	}
+	return max(lockedUntil.Sub(at), time.Second), nil
+}
+
+const resetLoginAttemptsQuery = "DELETE FROM login_attempts WHERE email = $1 AND client IN ($2, $3)"
+
+func (db *PostgresDatabase) ResetLoginAttempts(ctx context.Context, email, client string) error {
+	_, err := db.DB.Exec(ctx, resetLoginAttemptsQuery, email, client, anyClient)
+	return err
+}
+
+const createSessionQuery = "INSERT INTO sessions (id, family_id, user_id, token_hash, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)"
+
+func (db *PostgresDatabase) CreateSession(ctx context.Context, s *Session) error {
+	_, err := db.DB.Exec(ctx, createSessionQuery, s.ID, s.FamilyID, s.UserID, s.TokenHash, s.CreatedAt, s.ExpiresAt)
+	return err
+}
+
+const getSessionQuery = "SELECT id, family_id, user_id, token_hash, created_at, expires_at, rotated_at, revoked_at FROM sessions WHERE token_hash = $1"
+
+func (db *PostgresDatabase) GetSession(ctx context.Context, tokenHash []byte) (*Session, error) {
+	row := db.DB.QueryRow(ctx, getSessionQuery, tokenHash)
+	var s Session
+	err := row.Scan(&s.ID, &s.FamilyID, &s.UserID, &s.TokenHash, &s.CreatedAt, &s.ExpiresAt, &s.RotatedAt, &s.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+const rotateSessionQuery = "UPDATE sessions SET rotated_at = $2 WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL"
+
+func (db *PostgresDatabase) RotateSession(ctx context.Context, id string, next *Session) error {
+	return pgx.BeginFunc(ctx, db.DB, func(tx pgx.Tx) error {
+		// the conditional update locks the row, such that concurrent rotations of the same session cannot both succeed
+		tag, err := tx.Exec(ctx, rotateSessionQuery, id, next.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrSessionInactive
+		}
+		_, err = tx.Exec(ctx, createSessionQuery, next.ID, next.FamilyID, next.UserID, next.TokenHash, next.CreatedAt, next.ExpiresAt)
+		return err
+	})
+}
+
+const revokeSessionFamilyQuery = "UPDATE sessions SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL"
+
+func (db *PostgresDatabase) RevokeSessionFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := db.DB.Exec(ctx, revokeSessionFamilyQuery, familyID, at)
+	return err
+}
+
+const revokeUserSessionsQuery = "UPDATE sessions SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL"
+
+func (db *PostgresDatabase) RevokeUserSessions(ctx context.Context, userID string, at time.Time) error {
+	_, err := db.DB.Exec(ctx, revokeUserSessionsQuery, userID, at)
+	return err
+}
+
+const createUserTokenQuery = "INSERT INTO user_tokens (token_hash, user_id, purpose, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)"
+
+func (db *PostgresDatabase) CreateUserToken(ctx context.Context, t *UserToken) error {
+	_, err := db.DB.Exec(ctx, createUserTokenQuery, t.TokenHash, t.UserID, t.Purpose, t.CreatedAt, t.ExpiresAt)
+	return err
+}
+
+const useUserTokenQuery = "UPDATE user_tokens SET used_at = $3 WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3 RETURNING user_id"
+
+// useUserToken marks the token as used, returning its user ID, such that it can only be used once.
+func useUserToken(ctx context.Context, tx pgx.Tx, tokenHash []byte, purpose string, at time.Time) (string, error) {
+	var userID string
+	err := tx.QueryRow(ctx, useUserTokenQuery, tokenHash, purpose, at).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrTokenInvalid
+	}
+	return userID, err
+}
+
+const validateEmailQuery = "UPDATE users SET validated_email = TRUE WHERE id = $1"
+
+func (db *PostgresDatabase) VerifyEmail(ctx context.Context, tokenHash []byte, at time.Time) (string, error) {
+	var userID string
+	err := pgx.BeginFunc(ctx, db.DB, func(tx pgx.Tx) error {
+		var err error
+		userID, err = useUserToken(ctx, tx, tokenHash, TokenPurposeEmailVerification, at)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, validateEmailQuery, userID)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+const (
+	changePasswordQuery    = "UPDATE users SET hashed_password = $2 WHERE id = $1"
+	unlockUserQuery        = "DELETE FROM login_attempts WHERE email = (SELECT email FROM users WHERE id = $1)"
+	usePasswordTokensQuery = "UPDATE user_tokens SET used_at = $3 WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL"
+)
+
+func changePassword(ctx context.Context, tx pgx.Tx, userID string, hashedPassword []byte, at time.Time) error {
+	tag, err := tx.Exec(ctx, changePasswordQuery, userID, hashedPassword)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	if _, err := tx.Exec(ctx, usePasswordTokensQuery, userID, TokenPurposePasswordReset, at); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, unlockUserQuery, userID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, revokeUserSessionsQuery, userID, at)
+	return err
+}
+
+func (db *PostgresDatabase) ResetPassword(ctx context.Context, tokenHash, hashedPassword []byte, at time.Time) (string, error) {
+	var userID string
+	err := pgx.BeginFunc(ctx, db.DB, func(tx pgx.Tx) error {
+		var err error
+		userID, err = useUserToken(ctx, tx, tokenHash, TokenPurposePasswordReset, at)
+		if err != nil {
+			return err
+		}
+		return changePassword(ctx, tx, userID, hashedPassword, at)
+	})
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func (db *PostgresDatabase) ChangePassword(ctx context.Context, userID string, hashedPassword []byte, at time.Time) error {
+	return pgx.BeginFunc(ctx, db.DB, func(tx pgx.Tx) error {
+		return changePassword(ctx, tx, userID, hashedPassword, at)
+	})
+}
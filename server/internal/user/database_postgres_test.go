@@ -0,0 +1,17 @@
+//go:build postgres
+
+package user_test
+
+import (
+	"testing"
+
+	"github.com/luisferreira32/stickian/server/internal/pgtest"
+	"github.com/luisferreira32/stickian/server/internal/user"
+	"github.com/luisferreira32/stickian/server/internal/user/usertest"
+)
+
+func Test_PostgresDatabaseContract(t *testing.T) {
+	usertest.Run(t, func(t *testing.T) user.UserDatabase {
+		return &user.PostgresDatabase{DB: pgtest.New(t)}
+	})
+}
@@ -0,0 +1,14 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/luisferreira32/stickian/server/internal/user"
+	"github.com/luisferreira32/stickian/server/internal/user/usertest"
+)
+
+func Test_MemoryDatabaseContract(t *testing.T) {
+	usertest.Run(t, func(t *testing.T) user.UserDatabase {
+		return user.NewMemoryDatabase()
+	})
+}
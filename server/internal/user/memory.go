@@ -0,0 +1,294 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryDatabase is an in-memory implementation of the UserDatabase with the same semantics as the
+// PostgresDatabase, e.g., for tests or to run the server without a database. Nothing is persisted.
+type MemoryDatabase struct {
+	mu       sync.RWMutex
+	users    map[string]User
+	sessions map[string]Session
+	tokens   map[string]UserToken
+	// logins are the login attempts, by email and client
+	logins map[[2]string]loginAttempts
+}
+
+type loginAttempts struct {
+	attempts    int
+	lockedUntil time.Time
+}
+
+func NewMemoryDatabase() *MemoryDatabase {
+	return &MemoryDatabase{
+		users:    make(map[string]User),
+		sessions: make(map[string]Session),
+		tokens:   make(map[string]UserToken),
+		logins:   make(map[[2]string]loginAttempts),
+	}
+}
+
+func cloneUser(u User) *User {
+	u.HashedPassword = append([]byte(nil), u.HashedPassword...)
+	u.Roles = slices.Clone(u.Roles)
+	return &u
+}
+
+func cloneSession(s Session) *Session {
+	s.TokenHash = append([]byte(nil), s.TokenHash...)
+	if s.RotatedAt != nil {
+		rotatedAt := *s.RotatedAt
+		s.RotatedAt = &rotatedAt
+	}
+	if s.RevokedAt != nil {
+		revokedAt := *s.RevokedAt
+		s.RevokedAt = &revokedAt
+	}
+	return &s
+}
+
+func (db *MemoryDatabase) WriteUser(_ context.Context, u *User) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.users[u.ID]; ok {
+		return fmt.Errorf("user %s already exists", u.ID)
+	}
+	db.users[u.ID] = *cloneUser(*u)
+	return nil
+}
+
+func (db *MemoryDatabase) GetUser(_ context.Context, email string) (*User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, u := range db.users {
+		if u.Email == email {
+			return cloneUser(u), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (db *MemoryDatabase) GetUserByID(_ context.Context, id string) (*User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	u, ok := db.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(u), nil
+}
+
+func (db *MemoryDatabase) SetUserRoles(_ context.Context, id string, roles []string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	u, ok := db.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	u.Roles = slices.Clone(roles)
+	db.users[id] = u
+	return nil
+}
+
+func (db *MemoryDatabase) AttemptLogin(_ context.Context, email, client string, at time.Time) (time.Duration, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// the client goes first, such that the attempts of a client locked out do not count towards the account
+	if retryAfter := db.attemptLogin(email, client, maxLoginAttempts, at); retryAfter > 0 {
+		return retryAfter, nil
+	}
+	return db.attemptLogin(email, anyClient, maxAccountLoginAttempts, at), nil
+}
+
+func (db *MemoryDatabase) attemptLogin(email, client string, allowed int, at time.Time) time.Duration {
+	key := [2]string{email, client}
+	a := db.logins[key]
+	if a.lockedUntil.After(at) {
+		return max(a.lockedUntil.Sub(at), time.Second)
+	}
+	a.attempts++
+	a.lockedUntil = at.Add(lockoutAfter(a.attempts, allowed))
+	db.logins[key] = a
+	return 0
+}
+
+func (db *MemoryDatabase) ResetLoginAttempts(_ context.Context, email, client string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.logins, [2]string{email, client})
+	delete(db.logins, [2]string{email, anyClient})
+	return nil
+}
+
+func (db *MemoryDatabase) CreateSession(_ context.Context, s *Session) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.createSession(s)
+}
+
+func (db *MemoryDatabase) createSession(s *Session) error {
+	if _, ok := db.users[s.UserID]; !ok {
+		return fmt.Errorf("user %s does not exist", s.UserID)
+	}
+	for _, session := range db.sessions {
+		if session.ID == s.ID || bytes.Equal(session.TokenHash, s.TokenHash) {
+			return fmt.Errorf("session %s already exists", s.ID)
+		}
+	}
+	session := cloneSession(*s)
+	session.RotatedAt, session.RevokedAt = nil, nil
+	db.sessions[s.ID] = *session
+	return nil
+}
+
+func (db *MemoryDatabase) GetSession(_ context.Context, tokenHash []byte) (*Session, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, s := range db.sessions {
+		if bytes.Equal(s.TokenHash, tokenHash) {
+			return cloneSession(s), nil
+		}
+	}
+	return nil, ErrSessionNotFound
+}
+
+func (db *MemoryDatabase) RotateSession(_ context.Context, id string, next *Session) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s, ok := db.sessions[id]
+	if !ok || s.RotatedAt != nil || s.RevokedAt != nil {
+		return ErrSessionInactive
+	}
+	if err := db.createSession(next); err != nil {
+		return err
+	}
+	rotatedAt := next.CreatedAt
+	s.RotatedAt = &rotatedAt
+	db.sessions[id] = s
+	return nil
+}
+
+func (db *MemoryDatabase) RevokeSessionFamily(_ context.Context, familyID string, at time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.revokeSessions(func(s Session) bool { return s.FamilyID == familyID }, at)
+	return nil
+}
+
+func (db *MemoryDatabase) RevokeUserSessions(_ context.Context, userID string, at time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.revokeSessions(func(s Session) bool { return s.UserID == userID }, at)
+	return nil
+}
+
+func (db *MemoryDatabase) revokeSessions(match func(Session) bool, at time.Time) {
+	for id, s := range db.sessions {
+		if match(s) && s.RevokedAt == nil {
+			revokedAt := at
+			s.RevokedAt = &revokedAt
+			db.sessions[id] = s
+		}
+	}
+}
+
+func (db *MemoryDatabase) CreateUserToken(_ context.Context, t *UserToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.users[t.UserID]; !ok {
+		return fmt.Errorf("user %s does not exist", t.UserID)
+	}
+	if _, ok := db.tokens[string(t.TokenHash)]; ok {
+		return fmt.Errorf("token already exists")
+	}
+	token := *t
+	token.TokenHash = append([]byte(nil), t.TokenHash...)
+	token.UsedAt = nil
+	db.tokens[string(t.TokenHash)] = token
+	return nil
+}
+
+// useUserToken marks the token as used, returning its user ID, such that it can only be used once.
+func (db *MemoryDatabase) useUserToken(tokenHash []byte, purpose string, at time.Time) (string, error) {
+	t, ok := db.tokens[string(tokenHash)]
+	if !ok || t.Purpose != purpose || t.UsedAt != nil || !at.Before(t.ExpiresAt) {
+		return "", ErrTokenInvalid
+	}
+	usedAt := at
+	t.UsedAt = &usedAt
+	db.tokens[string(tokenHash)] = t
+	return t.UserID, nil
+}
+
+func (db *MemoryDatabase) VerifyEmail(_ context.Context, tokenHash []byte, at time.Time) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	userID, err := db.useUserToken(tokenHash, TokenPurposeEmailVerification, at)
+	if err != nil {
+		return "", err
+	}
+	u := db.users[userID]
+	u.ValidatedEmail = true
+	db.users[userID] = u
+	return userID, nil
+}
+
+func (db *MemoryDatabase) ResetPassword(_ context.Context, tokenHash, hashedPassword []byte, at time.Time) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, ok := db.tokens[string(tokenHash)]
+	if !ok || t.Purpose != TokenPurposePasswordReset || t.UsedAt != nil || !at.Before(t.ExpiresAt) {
+		return "", ErrTokenInvalid
+	}
+	return t.UserID, db.changePassword(t.UserID, hashedPassword, at)
+}
+
+func (db *MemoryDatabase) ChangePassword(_ context.Context, userID string, hashedPassword []byte, at time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.changePassword(userID, hashedPassword, at)
+}
+
+func (db *MemoryDatabase) changePassword(userID string, hashedPassword []byte, at time.Time) error {
+	u, ok := db.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	u.HashedPassword = append([]byte(nil), hashedPassword...)
+	db.users[userID] = u
+	for key := range db.logins {
+		if key[0] == u.Email {
+			delete(db.logins, key)
+		}
+	}
+	for hash, t := range db.tokens {
+		if t.UserID == userID && t.Purpose == TokenPurposePasswordReset && t.UsedAt == nil {
+			usedAt := at
+			t.UsedAt = &usedAt
+			db.tokens[hash] = t
+		}
+	}
+	db.revokeSessions(func(s Session) bool { return s.UserID == userID }, at)
+	return nil
+}
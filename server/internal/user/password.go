@@ -0,0 +1,191 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/mail"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+// passwordResetDuration is how long a user has to reset their password after asking for it
+const passwordResetDuration = time.Hour
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ForgotPassword emails a password reset link to the user. The response is always the same, such that it does not
+// reveal whether there is a user with the email.
+func (h *UserService) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
+	defer func() {
+		_ = bodyReader.Close()
+	}()
+
+	req := ForgotPasswordRequest{}
+	err := json.NewDecoder(bodyReader).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Database.GetUser(r.Context(), req.Email)
+	if err == nil {
+		err = h.sendPasswordResetEmail(r.Context(), user)
+	}
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		// the same response as a success, otherwise failures would tell apart the existing users
+		log.Printf("failed to send password reset email: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *UserService) sendPasswordResetEmail(ctx context.Context, u *User) error {
+	secret, err := h.newUserToken(ctx, u.ID, TokenPurposePasswordReset, passwordResetDuration)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+	link := h.PublicURL + "/reset-password?" + url.Values{"token": {secret}}.Encode()
+	return h.Mailer.Send(ctx, &mail.Message{
+		To:      u.Email,
+		Subject: "Reset your Stickian password",
+		Body: fmt.Sprintf("Hello %s,\n\nreset your password by opening the link below, within %v:\n\n%s\n\n"+
+			"If you did not ask for it, you can ignore this email.\n",
+			u.Username, passwordResetDuration, link),
+	})
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ResetPassword sets a new password with the token of the password reset email, logging the user out of every device.
+func (h *UserService) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
+	defer func() {
+		_ = bodyReader.Close()
+	}()
+
+	req := ResetPasswordRequest{}
+	err := json.NewDecoder(bodyReader).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	if errReason := validPassword(req.Password, h.Development); errReason != "" {
+		http.Error(w, errReason, http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	_, err = h.Database.ResetPassword(r.Context(), hashToken(req.Token), hashedPassword, time.Now().UTC())
+	if errors.Is(err, ErrTokenInvalid) {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type ChangePasswordResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// ChangePassword sets a new password for the authenticated user, who must know the current one. Every session of the
+// user is revoked, and the new tokens are returned such that only the current device stays logged in.
+func (h *UserService) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
+	defer func() {
+		_ = bodyReader.Close()
+	}()
+
+	req := ChangePasswordRequest{}
+	err := json.NewDecoder(bodyReader).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.CurrentPassword == "" {
+		http.Error(w, "current password is required", http.StatusBadRequest)
+		return
+	}
+	if errReason := validPassword(req.NewPassword, h.Development); errReason != "" {
+		http.Error(w, errReason, http.StatusBadRequest)
+		return
+	}
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, err := h.Database.GetUserByID(r.Context(), principal.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to get user", http.StatusInternalServerError)
+		return
+	}
+	// forbidden rather than unauthorized, since the user is authenticated and must not be logged out
+	if err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(req.CurrentPassword)); err != nil {
+		http.Error(w, "invalid password", http.StatusForbidden)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Database.ChangePassword(r.Context(), user.ID, hashedPassword, time.Now().UTC()); err != nil {
+		http.Error(w, "failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, refreshToken, err := h.login(r.Context(), user)
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(ChangePasswordResponse{AccessToken: accessToken, RefreshToken: refreshToken})
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,178 @@
+package user
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/mail"
+)
+
+var resetLinkRegexp = regexp.MustCompile(`http://stickian\.test/reset-password\?token=(\S+)`)
+
+// withPassword sets the password of the user.
+func withPassword(t *testing.T, password string) func(*UserService, *User) {
+	t.Helper()
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return func(_ *UserService, u *User) {
+		u.HashedPassword = hashedPassword
+	}
+}
+
+// withMailer sends the mails of the service to the buffer, with the links to the public URL of the tests.
+func withMailer(mails *bytes.Buffer) func(*UserService, *User) {
+	return func(service *UserService, _ *User) {
+		service.Mailer = &mail.WriterMailer{W: mails}
+		service.Development = true
+		service.PublicURL = "http://stickian.test"
+	}
+}
+
+func login(service *UserService, password string) int {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"player@stickian.com","password":"`+password+`"}`))
+	service.Login(rec, req)
+	return rec.Code
+}
+
+func Test_ForgotPassword(t *testing.T) {
+	testcases := []struct {
+		name      string
+		email     string
+		wantEmail bool
+	}{
+		{
+			name:      "existing user",
+			email:     "player@stickian.com",
+			wantEmail: true,
+		},
+		{
+			name:  "unknown user",
+			email: "unknown@stickian.com",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			var mails bytes.Buffer
+			service, _ := newTestService(t, withPassword(t, "old-password"), withMailer(&mails))
+
+			// when
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/password/forgot", strings.NewReader(`{"email":"`+testcase.email+`"}`))
+			service.ForgotPassword(rec, req)
+
+			// then
+			if rec.Code != 202 || rec.Body.Len() != 0 {
+				t.Errorf("unexpected response: %v, %s", rec.Code, rec.Body)
+			}
+			if gotEmail := resetLinkRegexp.MatchString(mails.String()); gotEmail != testcase.wantEmail {
+				t.Errorf("unexpected email: want %v, got %s", testcase.wantEmail, mails.String())
+			}
+		})
+	}
+}
+
+func Test_ResetPassword(t *testing.T) {
+	// given
+	var mails bytes.Buffer
+	service, u := newTestService(t, withPassword(t, "old-password"), withMailer(&mails))
+	refreshToken := mustLogin(t, service, u)
+	rec := httptest.NewRecorder()
+	service.ForgotPassword(rec, httptest.NewRequest("POST", "/api/password/forgot", strings.NewReader(`{"email":"player@stickian.com"}`)))
+	match := resetLinkRegexp.FindStringSubmatch(mails.String())
+	if match == nil {
+		t.Fatalf("missing reset link in the email: %s", mails.String())
+	}
+	body := `{"token":"` + match[1] + `","password":"new-password"}`
+
+	// when
+	rec = httptest.NewRecorder()
+	service.ResetPassword(rec, httptest.NewRequest("POST", "/api/password/reset", strings.NewReader(body)))
+
+	// then
+	if rec.Code != 204 {
+		t.Errorf("unexpected status code: %v, %s", rec.Code, rec.Body)
+	}
+	if code := login(service, "new-password"); code != 200 {
+		t.Errorf("unexpected status code logging in with the new password: %v", code)
+	}
+	if code := login(service, "old-password"); code != 401 {
+		t.Errorf("unexpected status code logging in with the old password: %v", code)
+	}
+	if code, _ := refresh(service, refreshToken); code != 401 {
+		t.Errorf("unexpected status code refreshing an old session: %v", code)
+	}
+	rec = httptest.NewRecorder()
+	service.ResetPassword(rec, httptest.NewRequest("POST", "/api/password/reset", strings.NewReader(body)))
+	if rec.Code != 400 {
+		t.Errorf("unexpected status code reusing the token: %v", rec.Code)
+	}
+}
+
+func Test_ChangePassword(t *testing.T) {
+	testcases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantLogin  string
+	}{
+		{
+			name:       "success",
+			body:       `{"currentPassword":"old-password","newPassword":"new-password"}`,
+			wantStatus: 200,
+			wantLogin:  "new-password",
+		},
+		{
+			name:       "wrong current password",
+			body:       `{"currentPassword":"wrong-password","newPassword":"new-password"}`,
+			wantStatus: 403,
+			wantLogin:  "old-password",
+		},
+		{
+			name:       "missing new password",
+			body:       `{"currentPassword":"old-password"}`,
+			wantStatus: 400,
+			wantLogin:  "old-password",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			var mails bytes.Buffer
+			service, u := newTestService(t, withPassword(t, "old-password"), withMailer(&mails))
+			otherDevice := mustLogin(t, service, u)
+
+			// when
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/password/change", strings.NewReader(testcase.body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: u.ID}))
+			service.ChangePassword(rec, req)
+
+			// then
+			if rec.Code != testcase.wantStatus {
+				t.Errorf("unexpected status code: want %v, got %v", testcase.wantStatus, rec.Code)
+			}
+			if code := login(service, testcase.wantLogin); code != 200 {
+				t.Errorf("unexpected status code logging in: %v", code)
+			}
+			wantRefresh := 200
+			if testcase.wantStatus == 200 {
+				wantRefresh = 401
+			}
+			if code, _ := refresh(service, otherDevice); code != wantRefresh {
+				t.Errorf("unexpected status code refreshing another device: want %v, got %v", wantRefresh, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+// knownRoles are the roles that can be granted
+var knownRoles = []string{auth.RoleAdmin, auth.RoleModerator}
+
+type SetRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+func validSetRolesRequest(req *SetRolesRequest) string {
+	for _, role := range req.Roles {
+		if !slices.Contains(knownRoles, role) {
+			return "unknown role: " + role
+		}
+	}
+	return ""
+}
+
+// SetRoles replaces the roles of a user, and must be restricted to admins with auth.RequireRoles. The user only gets
+// the new roles in their access token once it is renewed.
+func (h *UserService) SetRoles(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
+	defer func() {
+		_ = bodyReader.Close()
+	}()
+
+	req := SetRolesRequest{}
+	err := json.NewDecoder(bodyReader).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if errReason := validSetRolesRequest(&req); errReason != "" {
+		http.Error(w, errReason, http.StatusBadRequest)
+		return
+	}
+	slices.Sort(req.Roles)
+	req.Roles = slices.Compact(req.Roles)
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// otherwise the last admin could lock everyone out of the admin endpoints
+	if principal.UserID == id && !slices.Contains(req.Roles, auth.RoleAdmin) {
+		http.Error(w, "cannot remove your own admin role", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Database.SetUserRoles(r.Context(), id, req.Roles)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to set roles", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
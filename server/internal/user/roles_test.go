@@ -0,0 +1,99 @@
+package user
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+func Test_SetRoles(t *testing.T) {
+	admin := &auth.Principal{UserID: "00000000-0000-0000-0000-0000000000ad", Roles: []string{auth.RoleAdmin}}
+
+	testcases := []struct {
+		name       string
+		id         string
+		body       string
+		wantStatus int
+		wantRoles  []string
+	}{
+		{
+			name:       "grant roles",
+			id:         "00000000-0000-0000-0000-00000000000a",
+			body:       `{"roles":["moderator","admin","moderator"]}`,
+			wantStatus: 204,
+			wantRoles:  []string{auth.RoleAdmin, auth.RoleModerator},
+		},
+		{
+			name:       "unknown role",
+			id:         "00000000-0000-0000-0000-00000000000a",
+			body:       `{"roles":["king"]}`,
+			wantStatus: 400,
+		},
+		{
+			name:       "unknown user",
+			id:         "00000000-0000-0000-0000-00000000000b",
+			body:       `{"roles":["moderator"]}`,
+			wantStatus: 404,
+		},
+		{
+			name:       "remove own admin role",
+			id:         admin.UserID,
+			body:       `{"roles":[]}`,
+			wantStatus: 400,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			service, u := newTestService(t)
+
+			// when
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/api/users/"+testcase.id+"/roles", strings.NewReader(testcase.body))
+			req.SetPathValue("id", testcase.id)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), admin))
+			service.SetRoles(rec, req)
+
+			// then
+			if rec.Code != testcase.wantStatus {
+				t.Errorf("unexpected status code: want %v, got %v, %s", testcase.wantStatus, rec.Code, rec.Body)
+			}
+			got, err := service.Database.GetUserByID(context.Background(), u.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(testcase.wantRoles, got.Roles) {
+				t.Errorf("unexpected roles: want %v, got %v", testcase.wantRoles, got.Roles)
+			}
+		})
+	}
+}
+
+func Test_generateToken(t *testing.T) {
+	// given
+	u := &User{ID: "00000000-0000-0000-0000-00000000000a", Username: "player", ValidatedEmail: true, Roles: []string{auth.RoleModerator}}
+
+	// when
+	token, err := generateToken(u, "family", "secret", DefaultAccessTokenDuration)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// then
+	claims := &auth.Claims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) { return []byte("secret"), nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := claims.Principal()
+	if got.UserID != u.ID || got.Username != u.Username || got.SessionID != "family" || !got.EmailVerified ||
+		!slices.Equal(got.Roles, u.Roles) {
+		t.Errorf("unexpected principal: %+v", got)
+	}
+}
@@ -0,0 +1,295 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/utils"
+)
+
+const (
+	// DefaultAccessTokenDuration is the lifetime of the access tokens, short since they cannot be revoked
+	DefaultAccessTokenDuration = 15 * time.Minute
+	// DefaultRefreshTokenDuration is the lifetime of the refresh tokens, i.e., how long a user stays logged in
+	// without using the application
+	DefaultRefreshTokenDuration = 30 * 24 * time.Hour
+	// refreshGracePeriod is how long a rotated refresh token can still be used, since the tabs of a browser share
+	// the refresh token and may refresh it at the same time
+	refreshGracePeriod = 10 * time.Second
+	// streamTokenDuration is the lifetime of the stream tokens, only long enough to open the stream with them
+	streamTokenDuration = time.Minute
+)
+
+// Session defines a refresh token issued to a user. Every refresh rotates the token, creating a new session in the
+// same family (i.e., the same login in the same device) and marking the previous one as rotated.
+//
+// Only the hash of the refresh token is stored, such that a leaked database does not leak valid tokens.
+type Session struct {
+	ID        string
+	FamilyID  string
+	UserID    string
+	TokenHash []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+func generateSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b) // never returns an error
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (h *UserService) accessTokenDuration() time.Duration {
+	if h.AccessTokenDuration > 0 {
+		return h.AccessTokenDuration
+	}
+	return DefaultAccessTokenDuration
+}
+
+func (h *UserService) refreshTokenDuration() time.Duration {
+	if h.RefreshTokenDuration > 0 {
+		return h.RefreshTokenDuration
+	}
+	return DefaultRefreshTokenDuration
+}
+
+// newSession returns a session of the family with a new refresh token, which is only returned here.
+func (h *UserService) newSession(familyID, userID string) (*Session, string) {
+	refreshToken := generateSecret()
+	now := time.Now().UTC().Truncate(time.Microsecond) // the precision of the database timestamps
+	return &Session{
+		ID:        uuid.New().String(),
+		FamilyID:  familyID,
+		UserID:    userID,
+		TokenHash: hashToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.refreshTokenDuration()),
+	}, refreshToken
+}
+
+// login starts a new session family for the user, returning its access and refresh tokens.
+func (h *UserService) login(ctx context.Context, u *User) (string, string, error) {
+	session, refreshToken := h.newSession(uuid.New().String(), u.ID)
+	if err := h.Database.CreateSession(ctx, session); err != nil {
+		return "", "", err
+	}
+	accessToken, err := generateToken(u, session.FamilyID, h.SecretKey, h.accessTokenDuration())
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+type StreamTokenResponse struct {
+	StreamToken string `json:"streamToken"`
+}
+
+// StreamToken issues a stream token for the principal of the access token, which opens their stream in the token
+// query parameter (see auth.AudienceStream) and nothing else. It is short-lived since it ends up in the URL of the
+// stream, e.g., in the logs of the proxies, and the stream only checks it when it is opened.
+func (h *UserService) StreamToken(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	claims := &auth.Claims{
+		Name:          principal.Username,
+		SessionID:     principal.SessionID,
+		EmailVerified: principal.EmailVerified,
+		Roles:         principal.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   principal.UserID,
+			Audience:  jwt.ClaimStrings{auth.AudienceStream},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(streamTokenDuration)),
+		},
+	}
+	streamToken, err := signToken(claims, h.SecretKey)
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(StreamTokenResponse{StreamToken: streamToken})
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Refresh exchanges a refresh token for a new pair of access and refresh tokens, invalidating the used one.
+//
+// A refresh token that was already used means it was stolen, either by whoever used it first or by whoever is
+// using it now. Since there is no telling which, the whole session family is revoked and the user must log in again.
+// The exception is a token rotated within the refreshGracePeriod, e.g., by another tab refreshing at the same time,
+// which gets a new session of the family alongside the one of the first refresh.
+func (h *UserService) Refresh(w http.ResponseWriter, r *http.Request) {
+	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
+	defer func() {
+		_ = bodyReader.Close()
+	}()
+
+	req := RefreshRequest{}
+	err := json.NewDecoder(bodyReader).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.RefreshToken == "" {
+		http.Error(w, "refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.Database.GetSession(r.Context(), hashToken(req.RefreshToken))
+	if errors.Is(err, ErrSessionNotFound) {
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to get session", http.StatusInternalServerError)
+		return
+	}
+	if session.RotatedAt != nil && !recentlyRotated(session, time.Now()) {
+		h.revokeReusedSession(r.Context(), session)
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if session.RevokedAt != nil || !time.Now().Before(session.ExpiresAt) {
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.Database.GetUserByID(r.Context(), session.UserID)
+	if err != nil {
+		http.Error(w, "failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	next, refreshToken := h.newSession(session.FamilyID, session.UserID)
+	if session.RotatedAt == nil {
+		err = h.Database.RotateSession(r.Context(), session.ID, next)
+	}
+	if errors.Is(err, ErrSessionInactive) {
+		// lost a race against another refresh (or logout) with the same token
+		session, err = h.Database.GetSession(r.Context(), hashToken(req.RefreshToken))
+		if err == nil && !recentlyRotated(session, time.Now()) {
+			h.revokeReusedSession(r.Context(), session)
+			http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+	}
+	if err == nil && session.RotatedAt != nil {
+		err = h.Database.CreateSession(r.Context(), next)
+	}
+	if err != nil {
+		http.Error(w, "failed to rotate session", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := generateToken(user, next.FamilyID, h.SecretKey, h.accessTokenDuration())
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(RefreshResponse{AccessToken: accessToken, RefreshToken: refreshToken})
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// recentlyRotated returns whether the session was rotated within the refreshGracePeriod, and is still active.
+func recentlyRotated(s *Session, now time.Time) bool {
+	return s.RotatedAt != nil && s.RevokedAt == nil && now.Sub(*s.RotatedAt) < refreshGracePeriod
+}
+
+func (h *UserService) revokeReusedSession(ctx context.Context, s *Session) {
+	log.Printf("refresh token reuse detected for user %s, revoking session family %s", s.UserID, s.FamilyID)
+	if err := h.Database.RevokeSessionFamily(ctx, s.FamilyID, time.Now().UTC()); err != nil {
+		log.Printf("failed to revoke session family %s: %v", s.FamilyID, err)
+	}
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+	// AllDevices revokes every session of the user, instead of only the one of the refresh token
+	AllDevices bool `json:"allDevices"`
+}
+
+// Logout revokes the session family of the refresh token, or every session of the user.
+//
+// The access tokens already issued stay valid until they expire, which is why they are short-lived.
+func (h *UserService) Logout(w http.ResponseWriter, r *http.Request) {
+	bodyReader := http.MaxBytesReader(w, r.Body, utils.MaxRead)
+	defer func() {
+		_ = bodyReader.Close()
+	}()
+
+	req := LogoutRequest{}
+	err := json.NewDecoder(bodyReader).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.RefreshToken == "" {
+		http.Error(w, "refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.Database.GetSession(r.Context(), hashToken(req.RefreshToken))
+	if errors.Is(err, ErrSessionNotFound) {
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to get session", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	if req.AllDevices {
+		// only a live session may log out the other devices, not a leaked old token
+		if session.RotatedAt != nil || session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+			http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		err = h.Database.RevokeUserSessions(r.Context(), session.UserID, now)
+	} else {
+		err = h.Database.RevokeSessionFamily(r.Context(), session.FamilyID, now)
+	}
+	if err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,264 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+)
+
+// mustLogin returns the refresh token of a new session family of the user.
+func mustLogin(t *testing.T, service *UserService, u *User) string {
+	t.Helper()
+	_, refreshToken, err := service.login(context.Background(), u)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return refreshToken
+}
+
+func refresh(service *UserService, refreshToken string) (int, *RefreshResponse) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/refresh", strings.NewReader(`{"refreshToken":"`+refreshToken+`"}`))
+	service.Refresh(rec, req)
+	var rsp RefreshResponse
+	_ = json.NewDecoder(rec.Body).Decode(&rsp)
+	return rec.Code, &rsp
+}
+
+func logout(service *UserService, refreshToken string, allDevices bool) int {
+	rec := httptest.NewRecorder()
+	body, _ := json.Marshal(LogoutRequest{RefreshToken: refreshToken, AllDevices: allDevices})
+	req := httptest.NewRequest("POST", "/api/logout", strings.NewReader(string(body)))
+	service.Logout(rec, req)
+	return rec.Code
+}
+
+// newTestService returns a service on a memory database with a single user, after applying the options to both.
+func newTestService(t *testing.T, opts ...func(*UserService, *User)) (*UserService, *User) {
+	t.Helper()
+	db := NewMemoryDatabase()
+	service := &UserService{Database: db, SecretKey: "secret"}
+	u := &User{ID: "00000000-0000-0000-0000-00000000000a", Email: "player@stickian.com", Username: "player"}
+	for _, opt := range opts {
+		opt(service, u)
+	}
+	if err := db.WriteUser(context.Background(), u); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return service, u
+}
+
+func Test_Refresh(t *testing.T) {
+	t.Run("rotates the refresh token", func(t *testing.T) {
+		// given
+		service, u := newTestService(t)
+		refreshToken := mustLogin(t, service, u)
+
+		// when
+		code, rsp := refresh(service, refreshToken)
+
+		// then
+		if code != 200 || rsp.AccessToken == "" || rsp.RefreshToken == "" || rsp.RefreshToken == refreshToken {
+			t.Fatalf("unexpected refresh: %v, %+v", code, rsp)
+		}
+		if code, _ := refresh(service, rsp.RefreshToken); code != 200 {
+			t.Errorf("unexpected status code refreshing the rotated token: %v", code)
+		}
+	})
+
+	t.Run("reuse revokes the session family", func(t *testing.T) {
+		// given
+		service, u := newTestService(t)
+		refreshToken := mustLogin(t, service, u)
+		otherDevice := mustLogin(t, service, u)
+		rotatedToken := mustRotate(t, service, refreshToken, time.Now().Add(-refreshGracePeriod))
+
+		// when
+		code, _ := refresh(service, refreshToken)
+
+		// then
+		if code != 401 {
+			t.Errorf("unexpected status code reusing a refresh token: %v", code)
+		}
+		if code, _ := refresh(service, rotatedToken); code != 401 {
+			t.Errorf("unexpected status code after the family was revoked: %v", code)
+		}
+		if code, _ := refresh(service, otherDevice); code != 200 {
+			t.Errorf("unexpected status code of another device: %v", code)
+		}
+	})
+
+	t.Run("refreshes with the same token close together", func(t *testing.T) {
+		// given
+		service, u := newTestService(t)
+		refreshToken := mustLogin(t, service, u)
+		_, first := refresh(service, refreshToken)
+
+		// when
+		code, second := refresh(service, refreshToken)
+
+		// then
+		if code != 200 || second.RefreshToken == first.RefreshToken {
+			t.Fatalf("unexpected refresh: %v, %+v", code, second)
+		}
+		for _, token := range []string{first.RefreshToken, second.RefreshToken} {
+			if code, _ := refresh(service, token); code != 200 {
+				t.Errorf("unexpected status code refreshing a rotated token: %v", code)
+			}
+		}
+	})
+
+	t.Run("expired refresh token", func(t *testing.T) {
+		// given
+		service, u := newTestService(t)
+		session, refreshToken := service.newSession("00000000-0000-0000-0000-0000000000f1", u.ID)
+		session.ExpiresAt = time.Now().Add(-time.Minute)
+		if err := service.Database.CreateSession(context.Background(), session); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// when
+		code, _ := refresh(service, refreshToken)
+
+		// then
+		if code != 401 {
+			t.Errorf("unexpected status code: %v", code)
+		}
+	})
+
+	t.Run("unknown refresh token", func(t *testing.T) {
+		service, _ := newTestService(t)
+		if code, _ := refresh(service, "unknown"); code != 401 {
+			t.Errorf("unexpected status code: %v", code)
+		}
+	})
+}
+
+// mustRotate rotates the session of the refresh token as of the time, and returns the rotated refresh token.
+func mustRotate(t *testing.T, service *UserService, refreshToken string, at time.Time) string {
+	t.Helper()
+	session, err := service.Database.GetSession(context.Background(), hashToken(refreshToken))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	next, rotatedToken := service.newSession(session.FamilyID, session.UserID)
+	next.CreatedAt = at
+	if err := service.Database.RotateSession(context.Background(), session.ID, next); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return rotatedToken
+}
+
+func Test_Logout(t *testing.T) {
+	t.Run("revokes only the session family", func(t *testing.T) {
+		// given
+		service, u := newTestService(t)
+		refreshToken := mustLogin(t, service, u)
+		otherDevice := mustLogin(t, service, u)
+
+		// when
+		code := logout(service, refreshToken, false)
+
+		// then
+		if code != 204 {
+			t.Errorf("unexpected status code: %v", code)
+		}
+		if code, _ := refresh(service, refreshToken); code != 401 {
+			t.Errorf("unexpected status code after logout: %v", code)
+		}
+		if code, _ := refresh(service, otherDevice); code != 200 {
+			t.Errorf("unexpected status code of another device: %v", code)
+		}
+	})
+
+	t.Run("revokes all devices", func(t *testing.T) {
+		// given
+		service, u := newTestService(t)
+		refreshToken := mustLogin(t, service, u)
+		otherDevice := mustLogin(t, service, u)
+
+		// when
+		code := logout(service, refreshToken, true)
+
+		// then
+		if code != 204 {
+			t.Errorf("unexpected status code: %v", code)
+		}
+		if code, _ := refresh(service, otherDevice); code != 401 {
+			t.Errorf("unexpected status code of another device: %v", code)
+		}
+	})
+
+	t.Run("rotated refresh token cannot revoke all devices", func(t *testing.T) {
+		// given
+		service, u := newTestService(t)
+		refreshToken := mustLogin(t, service, u)
+		_, _ = refresh(service, refreshToken)
+
+		// when
+		code := logout(service, refreshToken, true)
+
+		// then
+		if code != 401 {
+			t.Errorf("unexpected status code: %v", code)
+		}
+	})
+}
+
+func Test_StreamToken(t *testing.T) {
+	t.Run("issues a short-lived token for the stream", func(t *testing.T) {
+		// given
+		service, u := newTestService(t)
+		principal := &auth.Principal{UserID: u.ID, Username: u.Username, SessionID: "family"}
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/stream/token", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+
+		// when
+		service.StreamToken(rec, req)
+
+		// then
+		if rec.Code != 200 {
+			t.Fatalf("unexpected status code: %v", rec.Code)
+		}
+		var rsp StreamTokenResponse
+		if err := json.NewDecoder(rec.Body).Decode(&rsp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		claims := &auth.Claims{}
+		_, err := jwt.ParseWithClaims(rsp.StreamToken, claims, func(token *jwt.Token) (any, error) {
+			return []byte("secret"), nil
+		}, jwt.WithAudience(auth.AudienceStream))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if claims.Subject != u.ID || claims.SessionID != "family" {
+			t.Errorf("unexpected claims: %+v", claims)
+		}
+		if claims.ExpiresAt.After(time.Now().Add(streamTokenDuration)) {
+			t.Errorf("unexpected expiration: %v", claims.ExpiresAt)
+		}
+	})
+
+	t.Run("requires an access token", func(t *testing.T) {
+		// given
+		service, _ := newTestService(t)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/stream/token", nil)
+
+		// when
+		service.StreamToken(rec, req)
+
+		// then
+		if rec.Code != 401 {
+			t.Errorf("unexpected status code: %v", rec.Code)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
@@ -11,13 +12,25 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/mail"
 	"github.com/luisferreira32/stickian/server/internal/utils"
 )
 
 type UserService struct {
 	Database    UserDatabase
+	Mailer      mail.Mailer
 	SecretKey   string
 	Development bool
+	// PublicURL is the address of the application for the links sent by email, e.g., https://stickian.com
+	PublicURL string
+	// AccessTokenDuration and RefreshTokenDuration default to DefaultAccessTokenDuration and
+	// DefaultRefreshTokenDuration if not set
+	AccessTokenDuration  time.Duration
+	RefreshTokenDuration time.Duration
+	// ClientIP returns the key of the client of a request, such that the failed logins lock out an email only from
+	// that client; the remote address if not set
+	ClientIP func(r *http.Request) string
 }
 
 // User defines the structure of a user in the system.
@@ -27,14 +40,28 @@ type User struct {
 	ValidatedEmail bool
 	Username       string
 	HashedPassword []byte
+	// Roles grant access to restricted endpoints, see the auth roles
+	Roles []string
 }
 
-func generateToken(u *User, secretKey string) (string, error) {
-	claims := jwt.MapClaims{
-		"sub":  u.ID,
-		"name": u.Username,
-		"exp":  jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+// generateToken returns an access token of the user, whose claims are only updated when the token is renewed
+// (e.g., the email_verified claim after verifying the email, or the roles after granting them).
+func generateToken(u *User, sessionFamilyID, secretKey string, duration time.Duration) (string, error) {
+	claims := &auth.Claims{
+		Name:          u.Username,
+		SessionID:     sessionFamilyID,
+		EmailVerified: u.ValidatedEmail,
+		Roles:         u.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   u.ID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
+		},
 	}
+	return signToken(claims, secretKey)
+}
+
+// signToken returns the signed token with the claims.
+func signToken(claims *auth.Claims, secretKey string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(secretKey))
 	if err != nil {
@@ -50,7 +77,8 @@ type SignupRequest struct {
 }
 
 type SignupResponse struct {
-	AccessToken string `json:"accessToken"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
 }
 
 func validSignupRequest(req *SignupRequest, isDevelopment bool) string {
@@ -60,10 +88,15 @@ func validSignupRequest(req *SignupRequest, isDevelopment bool) string {
 	if req.Email == "" {
 		return "email is required"
 	}
-	if req.Password == "" {
+	return validPassword(req.Password, isDevelopment)
+}
+
+// validPassword checks the password strength, which is only enforced outside of development.
+func validPassword(password string, isDevelopment bool) string {
+	if password == "" {
 		return "password is required"
 	}
-	if len([]byte(req.Password)) > 72 { // bcrypt has a maximum password length of 72 bytes
+	if len([]byte(password)) > 72 { // bcrypt has a maximum password length of 72 bytes
 		return "password too long"
 	}
 
@@ -77,11 +110,11 @@ func validSignupRequest(req *SignupRequest, isDevelopment bool) string {
 	// - at least one lowercase letter
 	// - at least one number
 	// - at least one special character
-	if len(req.Password) < 8 {
+	if len(password) < 8 {
 		return "password must be at least 8 characters long"
 	}
 	var hasUpper, hasLower, hasNumber, hasSpecial bool
-	for _, c := range req.Password {
+	for _, c := range password {
 		switch {
 		case 'A' <= c && c <= 'Z':
 			hasUpper = true
@@ -126,7 +159,7 @@ func (h *UserService) Signup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_, err = h.Database.GetUser(r.Context(), req.Email)
-	if err != nil && !errors.Is(err, errUserNotFound) {
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
 		http.Error(w, "failed to check existing user", http.StatusInternalServerError)
 		return
 	}
@@ -145,7 +178,7 @@ func (h *UserService) Signup(w http.ResponseWriter, r *http.Request) {
 	user := &User{
 		ID:             userID,
 		Email:          req.Email,
-		ValidatedEmail: false, // TODO: implement email validation
+		ValidatedEmail: false, // validated with the token sent by email
 		Username:       req.Username,
 		HashedPassword: hashedPassword,
 	}
@@ -154,28 +187,70 @@ func (h *UserService) Signup(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to create user", http.StatusInternalServerError)
 		return
 	}
+	// the user can still ask for a new verification email, so a failure to send it does not fail the signup
+	if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("failed to send verification email to user %s: %v", user.ID, err)
+	}
 
-	token, err := generateToken(user, h.SecretKey)
+	accessToken, refreshToken, err := h.login(r.Context(), user)
 	if err != nil {
 		http.Error(w, "failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(SignupResponse{AccessToken: token})
+	err = json.NewEncoder(w).Encode(SignupResponse{AccessToken: accessToken, RefreshToken: refreshToken})
 	if err != nil {
 		http.Error(w, "failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
+const (
+	// after maxLoginAttempts consecutive attempts without logging in the email is locked out from the client for
+	// loginLockout, doubled with every further attempt up to maxLoginLockout, which slows down guessing the password
+	maxLoginAttempts = 5
+	// after maxAccountLoginAttempts consecutive attempts from all the clients together the email is locked out
+	// from every client alike, which slows down guessing the password from many clients
+	maxAccountLoginAttempts = 20
+	loginLockout            = time.Minute
+	maxLoginLockout         = time.Hour
+)
+
+// anyClient is the client of the login attempts on an email from all the clients together.
+const anyClient = "*"
+
+// lockoutAfter returns how long an email is locked out after the consecutive login attempts, when it is locked out
+// after the allowed ones.
+func lockoutAfter(attempts, allowed int) time.Duration {
+	if attempts < allowed {
+		return 0
+	}
+	if doublings := attempts - allowed; doublings < 6 { // 2^6 minutes is over the maximum
+		return min(loginLockout<<doublings, maxLoginLockout)
+	}
+	return maxLoginLockout
+}
+
+// clientIP returns the key of the client of the request.
+func (h *UserService) clientIP(r *http.Request) string {
+	if h.ClientIP != nil {
+		return h.ClientIP(r)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
 type LoginResponse struct {
-	AccessToken string `json:"accessToken"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
 }
 
 func validLoginRequest(req *LoginRequest) string {
@@ -205,6 +280,20 @@ func (h *UserService) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the attempts are counted before checking the password, and for unknown emails alike, such that concurrent
+	// attempts cannot get past the lockout and the lockout does not tell which emails are registered
+	client := h.clientIP(r)
+	retryAfter, err := h.Database.AttemptLogin(r.Context(), req.Email, client, time.Now().UTC())
+	if err != nil {
+		log.Printf("failed to count login attempt: %v", err)
+		http.Error(w, "failed to log in", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		utils.WithTooManyRequests(w, retryAfter)
+		return
+	}
+
 	user, err := h.Database.GetUser(r.Context(), req.Email)
 	if err != nil {
 		log.Printf("failed to get user: %v", err)
@@ -217,15 +306,18 @@ func (h *UserService) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid username or password", http.StatusUnauthorized)
 		return
 	}
+	if err := h.Database.ResetLoginAttempts(r.Context(), req.Email, client); err != nil {
+		log.Printf("failed to reset login attempts of user %s: %v", user.ID, err)
+	}
 
-	token, err := generateToken(user, h.SecretKey)
+	accessToken, refreshToken, err := h.login(r.Context(), user)
 	if err != nil {
 		http.Error(w, "failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(LoginResponse{AccessToken: token})
+	err = json.NewEncoder(w).Encode(LoginResponse{AccessToken: accessToken, RefreshToken: refreshToken})
 	if err != nil {
 		http.Error(w, "failed to encode response", http.StatusInternalServerError)
 		return
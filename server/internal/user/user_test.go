@@ -0,0 +1,108 @@
+package user
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_lockoutAfter(t *testing.T) {
+	testcases := []struct {
+		name     string
+		attempts int
+		allowed  int
+		want     time.Duration
+	}{
+		{name: "no attempts", allowed: maxLoginAttempts},
+		{name: "a few attempts", attempts: maxLoginAttempts - 1, allowed: maxLoginAttempts},
+		{name: "locked", attempts: maxLoginAttempts, allowed: maxLoginAttempts, want: loginLockout},
+		{name: "lockout doubles", attempts: maxLoginAttempts + 2, allowed: maxLoginAttempts, want: 4 * time.Minute},
+		{name: "maximum lockout", attempts: 100, allowed: maxLoginAttempts, want: maxLoginLockout},
+		{name: "account allows more attempts", attempts: maxLoginAttempts, allowed: maxAccountLoginAttempts},
+		{name: "account locked", attempts: maxAccountLoginAttempts, allowed: maxAccountLoginAttempts, want: loginLockout},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			if got := lockoutAfter(testcase.attempts, testcase.allowed); got != testcase.want {
+				t.Errorf("unexpected lockout: want %v, got %v", testcase.want, got)
+			}
+		})
+	}
+}
+
+func Test_LoginLockout(t *testing.T) {
+	loginFrom := func(service *UserService, remoteAddr, email, password string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/login", bytes.NewBufferString(`{"email":"`+email+`","password":"`+password+`"}`))
+		req.RemoteAddr = remoteAddr
+		service.Login(rec, req)
+		return rec
+	}
+
+	testcases := []struct {
+		name  string
+		email string
+	}{
+		{name: "registered email", email: "player@stickian.com"},
+		// the same as a registered one, such that the lockout does not tell which emails are registered
+		{name: "unknown email", email: "nobody@stickian.com"},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// given
+			service, _ := newTestService(t, withPassword(t, "old-password"))
+			for range maxLoginAttempts {
+				if rec := loginFrom(service, "192.0.2.1:1234", testcase.email, "wrong-password"); rec.Code != 401 {
+					t.Fatalf("unexpected status code of a failed login: %v", rec.Code)
+				}
+			}
+
+			// when
+			rec := loginFrom(service, "192.0.2.1:1234", testcase.email, "old-password")
+
+			// then
+			if rec.Code != 429 || rec.Header().Get("Retry-After") != "60" {
+				t.Errorf("unexpected response of a locked out email: %v, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+			}
+		})
+	}
+
+	t.Run("other clients are not locked out", func(t *testing.T) {
+		// given
+		service, _ := newTestService(t, withPassword(t, "old-password"))
+		for range maxLoginAttempts {
+			loginFrom(service, "192.0.2.1:1234", "player@stickian.com", "wrong-password")
+		}
+
+		// when
+		rec := loginFrom(service, "198.51.100.1:1234", "player@stickian.com", "old-password")
+
+		// then
+		if rec.Code != 200 {
+			t.Errorf("unexpected status code from another client: %v, %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("many clients lock out the account", func(t *testing.T) {
+		// given
+		service, _ := newTestService(t, withPassword(t, "old-password"))
+		for i := range maxAccountLoginAttempts {
+			remoteAddr := fmt.Sprintf("192.0.2.%d:1234", i+1)
+			if rec := loginFrom(service, remoteAddr, "player@stickian.com", "wrong-password"); rec.Code != 401 {
+				t.Fatalf("unexpected status code of a failed login: %v", rec.Code)
+			}
+		}
+
+		// when
+		rec := loginFrom(service, "198.51.100.1:1234", "player@stickian.com", "old-password")
+
+		// then
+		if rec.Code != 429 || rec.Header().Get("Retry-After") != "60" {
+			t.Errorf("unexpected response of a locked out account: %v, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+		}
+	})
+}
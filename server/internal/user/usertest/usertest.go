@@ -0,0 +1,469 @@
+// Package usertest is a conformance test suite for the implementations of the user.UserDatabase, such that
+// every storage backend behaves exactly like the others.
+package usertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/luisferreira32/stickian/server/internal/user"
+)
+
+// NewDatabase returns an empty database under test.
+type NewDatabase func(t *testing.T) user.UserDatabase
+
+func testUser() *user.User {
+	return &user.User{
+		ID:             "00000000-0000-0000-0000-00000000000a",
+		Email:          "player@stickian.com",
+		Username:       "player",
+		HashedPassword: []byte("hashed"),
+	}
+}
+
+func testSession(id, familyID string) *user.Session {
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	return &user.Session{
+		ID:        id,
+		FamilyID:  familyID,
+		UserID:    testUser().ID,
+		TokenHash: []byte("hash-" + id),
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.Add(time.Hour),
+	}
+}
+
+func mustCreateUser(t *testing.T, db user.UserDatabase) {
+	t.Helper()
+	if err := db.WriteUser(context.Background(), testUser()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func mustGetSession(t *testing.T, db user.UserDatabase, tokenHash []byte) *user.Session {
+	t.Helper()
+	s, err := db.GetSession(context.Background(), tokenHash)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+// Run runs the conformance test suite against fresh databases returned by newDB.
+func Run(t *testing.T, newDB NewDatabase) {
+	ctx := context.Background()
+
+	t.Run("GetUser returns not found", func(t *testing.T) {
+		db := newDB(t)
+		if _, err := db.GetUser(ctx, "player@stickian.com"); !errors.Is(err, user.ErrUserNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrUserNotFound, err)
+		}
+	})
+
+	t.Run("WriteUser round trips through GetUser", func(t *testing.T) {
+		db := newDB(t)
+		want := testUser()
+		if err := db.WriteUser(ctx, want); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := db.GetUser(ctx, want.Email)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("unexpected user diff (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("WriteUser fails for an existing user", func(t *testing.T) {
+		db := newDB(t)
+		if err := db.WriteUser(ctx, testUser()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		duplicate := testUser()
+		duplicate.Email = "another@stickian.com"
+		if err := db.WriteUser(ctx, duplicate); err == nil {
+			t.Errorf("expected an error")
+		}
+		if _, err := db.GetUser(ctx, duplicate.Email); !errors.Is(err, user.ErrUserNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrUserNotFound, err)
+		}
+	})
+
+	t.Run("GetUserByID round trips", func(t *testing.T) {
+		db := newDB(t)
+		if _, err := db.GetUserByID(ctx, testUser().ID); !errors.Is(err, user.ErrUserNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrUserNotFound, err)
+		}
+		mustCreateUser(t, db)
+
+		got, err := db.GetUserByID(ctx, testUser().ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(testUser(), got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("unexpected user diff (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("CreateSession round trips through GetSession", func(t *testing.T) {
+		db := newDB(t)
+		if _, err := db.GetSession(ctx, []byte("hash-1")); !errors.Is(err, user.ErrSessionNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrSessionNotFound, err)
+		}
+		mustCreateUser(t, db)
+		want := testSession("00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-0000000000f1")
+		if err := db.CreateSession(ctx, want); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got := mustGetSession(t, db, want.TokenHash)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected session diff (-want, +got): %v", diff)
+		}
+	})
+
+	t.Run("CreateSession fails for an unknown user", func(t *testing.T) {
+		db := newDB(t)
+		if err := db.CreateSession(ctx, testSession("00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-0000000000f1")); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("RotateSession replaces the session once", func(t *testing.T) {
+		db := newDB(t)
+		mustCreateUser(t, db)
+		first := testSession("00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-0000000000f1")
+		if err := db.CreateSession(ctx, first); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		next := testSession("00000000-0000-0000-0000-000000000002", first.FamilyID)
+		next.CreatedAt = first.CreatedAt.Add(time.Minute)
+
+		if err := db.RotateSession(ctx, first.ID, next); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		again := testSession("00000000-0000-0000-0000-000000000003", first.FamilyID)
+		if err := db.RotateSession(ctx, first.ID, again); !errors.Is(err, user.ErrSessionInactive) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrSessionInactive, err)
+		}
+
+		if got := mustGetSession(t, db, first.TokenHash); got.RotatedAt == nil || !got.RotatedAt.Equal(next.CreatedAt) {
+			t.Errorf("unexpected rotated at: %v", got.RotatedAt)
+		}
+		if diff := cmp.Diff(next, mustGetSession(t, db, next.TokenHash)); diff != "" {
+			t.Errorf("unexpected session diff (-want, +got): %v", diff)
+		}
+		if _, err := db.GetSession(ctx, again.TokenHash); !errors.Is(err, user.ErrSessionNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrSessionNotFound, err)
+		}
+	})
+
+	t.Run("RevokeSessionFamily revokes only the family", func(t *testing.T) {
+		db := newDB(t)
+		mustCreateUser(t, db)
+		family := testSession("00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-0000000000f1")
+		other := testSession("00000000-0000-0000-0000-000000000002", "00000000-0000-0000-0000-0000000000f2")
+		for _, s := range []*user.Session{family, other} {
+			if err := db.CreateSession(ctx, s); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		at := family.CreatedAt.Add(time.Minute)
+
+		if err := db.RevokeSessionFamily(ctx, family.FamilyID, at); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := mustGetSession(t, db, family.TokenHash); got.RevokedAt == nil || !got.RevokedAt.Equal(at) {
+			t.Errorf("unexpected revoked at: %v", got.RevokedAt)
+		}
+		if got := mustGetSession(t, db, other.TokenHash); got.RevokedAt != nil {
+			t.Errorf("unexpected revoked at: %v", got.RevokedAt)
+		}
+		if err := db.RotateSession(ctx, family.ID, testSession("00000000-0000-0000-0000-000000000003", family.FamilyID)); !errors.Is(err, user.ErrSessionInactive) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrSessionInactive, err)
+		}
+	})
+
+	t.Run("RevokeUserSessions revokes every family", func(t *testing.T) {
+		db := newDB(t)
+		mustCreateUser(t, db)
+		sessions := []*user.Session{
+			testSession("00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-0000000000f1"),
+			testSession("00000000-0000-0000-0000-000000000002", "00000000-0000-0000-0000-0000000000f2"),
+		}
+		for _, s := range sessions {
+			if err := db.CreateSession(ctx, s); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		at := sessions[0].CreatedAt.Add(time.Minute)
+
+		if err := db.RevokeUserSessions(ctx, testUser().ID, at); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, s := range sessions {
+			if got := mustGetSession(t, db, s.TokenHash); got.RevokedAt == nil || !got.RevokedAt.Equal(at) {
+				t.Errorf("unexpected revoked at of %s: %v", s.ID, got.RevokedAt)
+			}
+		}
+	})
+
+	t.Run("VerifyEmail uses the token once", func(t *testing.T) {
+		db := newDB(t)
+		mustCreateUser(t, db)
+		token := &user.UserToken{
+			TokenHash: []byte("hash-verify"),
+			UserID:    testUser().ID,
+			Purpose:   user.TokenPurposeEmailVerification,
+			CreatedAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			ExpiresAt: time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC),
+		}
+		if err := db.CreateUserToken(ctx, token); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		userID, err := db.VerifyEmail(ctx, token.TokenHash, token.CreatedAt.Add(time.Hour))
+		if err != nil || userID != testUser().ID {
+			t.Fatalf("unexpected verification: %v, %v", userID, err)
+		}
+		if _, err := db.VerifyEmail(ctx, token.TokenHash, token.CreatedAt.Add(time.Hour)); !errors.Is(err, user.ErrTokenInvalid) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrTokenInvalid, err)
+		}
+
+		got, err := db.GetUserByID(ctx, testUser().ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !got.ValidatedEmail {
+			t.Errorf("expected the email to be validated")
+		}
+	})
+
+	t.Run("VerifyEmail rejects expired and unknown tokens", func(t *testing.T) {
+		db := newDB(t)
+		mustCreateUser(t, db)
+		token := &user.UserToken{
+			TokenHash: []byte("hash-verify"),
+			UserID:    testUser().ID,
+			Purpose:   user.TokenPurposeEmailVerification,
+			CreatedAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			ExpiresAt: time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC),
+		}
+		if err := db.CreateUserToken(ctx, token); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := db.VerifyEmail(ctx, token.TokenHash, token.ExpiresAt); !errors.Is(err, user.ErrTokenInvalid) {
+			t.Errorf("unexpected error for an expired token: want %v, got %v", user.ErrTokenInvalid, err)
+		}
+		if _, err := db.VerifyEmail(ctx, []byte("unknown"), token.CreatedAt); !errors.Is(err, user.ErrTokenInvalid) {
+			t.Errorf("unexpected error for an unknown token: want %v, got %v", user.ErrTokenInvalid, err)
+		}
+		got, err := db.GetUserByID(ctx, testUser().ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ValidatedEmail {
+			t.Errorf("unexpected validated email")
+		}
+	})
+
+	t.Run("ResetPassword changes the password and revokes the sessions", func(t *testing.T) {
+		db := newDB(t)
+		mustCreateUser(t, db)
+		session := testSession("00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-0000000000f1")
+		if err := db.CreateSession(ctx, session); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tokens := []*user.UserToken{
+			{TokenHash: []byte("hash-reset-1"), Purpose: user.TokenPurposePasswordReset},
+			{TokenHash: []byte("hash-reset-2"), Purpose: user.TokenPurposePasswordReset},
+			{TokenHash: []byte("hash-verify"), Purpose: user.TokenPurposeEmailVerification},
+		}
+		for _, token := range tokens {
+			token.UserID = testUser().ID
+			token.CreatedAt = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			token.ExpiresAt = time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+			if err := db.CreateUserToken(ctx, token); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		at := time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+
+		if _, err := db.ResetPassword(ctx, tokens[2].TokenHash, []byte("new-hash"), at); !errors.Is(err, user.ErrTokenInvalid) {
+			t.Errorf("unexpected error for another purpose: want %v, got %v", user.ErrTokenInvalid, err)
+		}
+		userID, err := db.ResetPassword(ctx, tokens[0].TokenHash, []byte("new-hash"), at)
+		if err != nil || userID != testUser().ID {
+			t.Fatalf("unexpected reset: %v, %v", userID, err)
+		}
+
+		got, err := db.GetUserByID(ctx, testUser().ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got.HashedPassword) != "new-hash" {
+			t.Errorf("unexpected hashed password: %s", got.HashedPassword)
+		}
+		if got := mustGetSession(t, db, session.TokenHash); got.RevokedAt == nil || !got.RevokedAt.Equal(at) {
+			t.Errorf("unexpected revoked at: %v", got.RevokedAt)
+		}
+		for _, token := range tokens[:2] {
+			if _, err := db.ResetPassword(ctx, token.TokenHash, []byte("another-hash"), at); !errors.Is(err, user.ErrTokenInvalid) {
+				t.Errorf("unexpected error for a used token: want %v, got %v", user.ErrTokenInvalid, err)
+			}
+		}
+		if _, err := db.VerifyEmail(ctx, tokens[2].TokenHash, at); err != nil {
+			t.Errorf("unexpected error verifying the email: %v", err)
+		}
+	})
+
+	t.Run("ChangePassword changes the password and revokes the sessions", func(t *testing.T) {
+		db := newDB(t)
+		if err := db.ChangePassword(ctx, testUser().ID, []byte("new-hash"), time.Now()); !errors.Is(err, user.ErrUserNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrUserNotFound, err)
+		}
+		mustCreateUser(t, db)
+		session := testSession("00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-0000000000f1")
+		if err := db.CreateSession(ctx, session); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		at := session.CreatedAt.Add(time.Minute)
+
+		if err := db.ChangePassword(ctx, testUser().ID, []byte("new-hash"), at); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := db.GetUserByID(ctx, testUser().ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got.HashedPassword) != "new-hash" {
+			t.Errorf("unexpected hashed password: %s", got.HashedPassword)
+		}
+		if got := mustGetSession(t, db, session.TokenHash); got.RevokedAt == nil || !got.RevokedAt.Equal(at) {
+			t.Errorf("unexpected revoked at: %v", got.RevokedAt)
+		}
+	})
+
+	t.Run("AttemptLogin locks out the email from the client", func(t *testing.T) {
+		db := newDB(t)
+		email, first := testUser().Email, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		// the attempts are counted for unknown emails alike
+		for i := range 5 {
+			if got, err := db.AttemptLogin(ctx, email, "ip:a", first.Add(time.Duration(i)*time.Second)); err != nil || got != 0 {
+				t.Fatalf("unexpected attempt %d: %v, %v", i, got, err)
+			}
+		}
+
+		// locked out for a minute since the fifth attempt, which is not counted
+		if got, err := db.AttemptLogin(ctx, email, "ip:a", first.Add(34*time.Second)); err != nil || got != 30*time.Second {
+			t.Errorf("unexpected locked out attempt: %v, %v", got, err)
+		}
+		// but not from another client
+		if got, err := db.AttemptLogin(ctx, email, "ip:b", first.Add(34*time.Second)); err != nil || got != 0 {
+			t.Errorf("unexpected attempt from another client: %v, %v", got, err)
+		}
+		// the sixth attempt doubles the lockout
+		if got, err := db.AttemptLogin(ctx, email, "ip:a", first.Add(64*time.Second)); err != nil || got != 0 {
+			t.Errorf("unexpected attempt after the lockout: %v, %v", got, err)
+		}
+		if got, err := db.AttemptLogin(ctx, email, "ip:a", first.Add(65*time.Second)); err != nil || got != 119*time.Second {
+			t.Errorf("unexpected doubled lockout: %v, %v", got, err)
+		}
+
+		if err := db.ResetLoginAttempts(ctx, email, "ip:a"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, err := db.AttemptLogin(ctx, email, "ip:a", first.Add(65*time.Second)); err != nil || got != 0 {
+			t.Errorf("unexpected attempt after the reset: %v, %v", got, err)
+		}
+	})
+
+	t.Run("AttemptLogin locks out the email from all the clients together", func(t *testing.T) {
+		db := newDB(t)
+		email, first := testUser().Email, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		// four attempts from each of five clients, none of them locked out on their own
+		for i := range 20 {
+			client := fmt.Sprintf("ip:%d", i%5)
+			if got, err := db.AttemptLogin(ctx, email, client, first.Add(time.Duration(i)*time.Second)); err != nil || got != 0 {
+				t.Fatalf("unexpected attempt %d: %v, %v", i, got, err)
+			}
+		}
+
+		// locked out from every client for a minute since the twentieth attempt
+		if got, err := db.AttemptLogin(ctx, email, "ip:new", first.Add(49*time.Second)); err != nil || got != 30*time.Second {
+			t.Errorf("unexpected locked out attempt: %v, %v", got, err)
+		}
+		// but not the other emails
+		if got, err := db.AttemptLogin(ctx, "another@stickian.com", "ip:new", first.Add(49*time.Second)); err != nil || got != 0 {
+			t.Errorf("unexpected attempt on another email: %v, %v", got, err)
+		}
+
+		// logging in from a client unlocks the account
+		if err := db.ResetLoginAttempts(ctx, email, "ip:0"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, err := db.AttemptLogin(ctx, email, "ip:new", first.Add(49*time.Second)); err != nil || got != 0 {
+			t.Errorf("unexpected attempt after the reset: %v, %v", got, err)
+		}
+	})
+
+	t.Run("ChangePassword resets the login attempts", func(t *testing.T) {
+		db := newDB(t)
+		mustCreateUser(t, db)
+		at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		for range 5 {
+			if _, err := db.AttemptLogin(ctx, testUser().Email, "ip:a", at); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if err := db.ChangePassword(ctx, testUser().ID, []byte("new-hash"), at); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, err := db.AttemptLogin(ctx, testUser().Email, "ip:a", at); err != nil || got != 0 {
+			t.Errorf("unexpected attempt after changing the password: %v, %v", got, err)
+		}
+	})
+
+	t.Run("SetUserRoles replaces the roles", func(t *testing.T) {
+		db := newDB(t)
+		if err := db.SetUserRoles(ctx, testUser().ID, []string{"admin"}); !errors.Is(err, user.ErrUserNotFound) {
+			t.Errorf("unexpected error: want %v, got %v", user.ErrUserNotFound, err)
+		}
+		want := testUser()
+		want.Roles = []string{"moderator"}
+		if err := db.WriteUser(ctx, want); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, err := db.GetUser(ctx, want.Email); err != nil || !slices.Equal(got.Roles, want.Roles) {
+			t.Errorf("unexpected roles: %v, %v", got, err)
+		}
+
+		for _, roles := range [][]string{{"admin", "moderator"}, nil} {
+			if err := db.SetUserRoles(ctx, want.ID, roles); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := db.GetUserByID(ctx, want.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(roles, got.Roles, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected roles diff (-want, +got): %v", diff)
+			}
+		}
+	})
+}
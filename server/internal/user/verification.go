@@ -0,0 +1,117 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/mail"
+)
+
+const (
+	// the purposes of the user tokens, such that a token can only be used for what it was sent
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+
+	// emailVerificationDuration is how long a user has to click the link of the verification email
+	emailVerificationDuration = 24 * time.Hour
+)
+
+// UserToken defines a single-use secret sent to a user for a purpose, e.g., to verify their email. As with the
+// sessions, only the hash of the token is stored.
+type UserToken struct {
+	TokenHash []byte
+	UserID    string
+	Purpose   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// newUserToken creates a token of the user for the purpose, returning the secret to send them.
+func (h *UserService) newUserToken(ctx context.Context, userID, purpose string, duration time.Duration) (string, error) {
+	secret := generateSecret()
+	now := time.Now().UTC().Truncate(time.Microsecond) // the precision of the database timestamps
+	err := h.Database.CreateUserToken(ctx, &UserToken{
+		TokenHash: hashToken(secret),
+		UserID:    userID,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(duration),
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (h *UserService) sendVerificationEmail(ctx context.Context, u *User) error {
+	secret, err := h.newUserToken(ctx, u.ID, TokenPurposeEmailVerification, emailVerificationDuration)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+	link := h.PublicURL + "/api/verify-email?" + url.Values{"token": {secret}}.Encode()
+	return h.Mailer.Send(ctx, &mail.Message{
+		To:      u.Email,
+		Subject: "Verify your Stickian email",
+		Body: fmt.Sprintf("Hello %s,\n\nverify your email by opening the link below, within %v:\n\n%s\n",
+			u.Username, emailVerificationDuration, link),
+	})
+}
+
+// VerifyEmail validates the email of the user with the token sent by email. Since it is opened from the email,
+// it does not require authentication, and the access tokens only show the email as verified once refreshed.
+func (h *UserService) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	_, err := h.Database.VerifyEmail(r.Context(), hashToken(token), time.Now().UTC())
+	if errors.Is(err, ErrTokenInvalid) {
+		http.Error(w, "invalid or expired token", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("email verified\n"))
+}
+
+// ResendVerificationEmail sends a new verification email to the authenticated user.
+func (h *UserService) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.Database.GetUserByID(r.Context(), principal.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to get user", http.StatusInternalServerError)
+		return
+	}
+	if user.ValidatedEmail {
+		http.Error(w, "email already verified", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("failed to send verification email to user %s: %v", user.ID, err)
+		http.Error(w, "failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
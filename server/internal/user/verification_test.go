@@ -0,0 +1,57 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/luisferreira32/stickian/server/internal/mail"
+)
+
+var verificationLinkRegexp = regexp.MustCompile(`http://stickian\.test/api/verify-email\?token=(\S+)`)
+
+func Test_VerifyEmail(t *testing.T) {
+	// given
+	var mails bytes.Buffer
+	service := &UserService{
+		Database:    NewMemoryDatabase(),
+		Mailer:      &mail.WriterMailer{W: &mails},
+		SecretKey:   "secret",
+		Development: true,
+		PublicURL:   "http://stickian.test",
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/signup", strings.NewReader(`{"username":"player","email":"player@stickian.com","password":"password"}`))
+	service.Signup(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("unexpected signup status code: %v, %s", rec.Code, rec.Body)
+	}
+	match := verificationLinkRegexp.FindStringSubmatch(mails.String())
+	if match == nil {
+		t.Fatalf("missing verification link in the email: %s", mails.String())
+	}
+
+	// when
+	rec = httptest.NewRecorder()
+	service.VerifyEmail(rec, httptest.NewRequest("GET", "/api/verify-email?token="+match[1], nil))
+
+	// then
+	if rec.Code != 200 {
+		t.Errorf("unexpected status code: %v, %s", rec.Code, rec.Body)
+	}
+	u, err := service.Database.GetUser(context.Background(), "player@stickian.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !u.ValidatedEmail {
+		t.Errorf("expected the email to be validated")
+	}
+	rec = httptest.NewRecorder()
+	service.VerifyEmail(rec, httptest.NewRequest("GET", "/api/verify-email?token="+match[1], nil))
+	if rec.Code != 400 {
+		t.Errorf("unexpected status code reusing the token: %v", rec.Code)
+	}
+}
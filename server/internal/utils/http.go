@@ -3,6 +3,9 @@ package utils
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -14,10 +17,56 @@ const (
 )
 
 func WithDefaultOKHeaders(w http.ResponseWriter) {
+	withDefaultHeaders(w, http.StatusOK)
+}
+
+// WithDefaultAcceptedHeaders should be used by endpoints that only submit events to be processed later.
+func WithDefaultAcceptedHeaders(w http.ResponseWriter) {
+	withDefaultHeaders(w, http.StatusAccepted)
+}
+
+// WithDefaultEventStreamHeaders should be used by endpoints that push server-sent events.
+func WithDefaultEventStreamHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	// disable the response buffering of reverse proxies (e.g. nginx)
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+}
+
+// WithTooManyRequests should be used by endpoints (or middlewares) that reject a request over a rate limit, telling
+// the client how long to wait before retrying.
+func WithTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second) // Retry-After is in whole seconds, rounded up
+	w.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 1), 10))
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+}
+
+// WithCacheableHeaders should be used by endpoints of resources that never change (e.g., the terrain of a world),
+// such that clients keep them for the max age and then revalidate them with their ETag. It returns false, having
+// answered with a 304, if the client already has the resource, in which case there is nothing left to write.
+func WithCacheableHeaders(w http.ResponseWriter, r *http.Request, contentType, etag string, maxAge time.Duration) bool {
+	w.Header().Set("ETag", etag)
+	// private, since the endpoints are authenticated
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(int64(maxAge/time.Second), 10))
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		match = strings.TrimPrefix(strings.TrimSpace(match), "W/")
+		if match == etag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return false
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	return true
+}
+
+func withDefaultHeaders(w http.ResponseWriter, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Pragma", "no-cache")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 }
 
 func WithError(w http.ResponseWriter, err error) {
@@ -0,0 +1,82 @@
+package worldgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/luisferreira32/stickian/server/internal/game"
+)
+
+// The files of a world in a directory: the biomes in world.csv, with one line per q coordinate and a trailing
+// comma, and the [q, r] coordinates of the settleable tiles in world_settleable.json.
+const (
+	biomesFile     = "world.csv"
+	settleableFile = "world_settleable.json"
+)
+
+// WriteFiles writes the tiles of a world, sorted by q and then r, to the files of a world in the directory.
+func WriteFiles(dir string, tiles []*game.MapTile) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var biomes bytes.Buffer
+	settleable := [][2]int{}
+	for i, t := range tiles {
+		if i > 0 && t.Q != tiles[i-1].Q {
+			biomes.WriteByte('\n')
+		}
+		biomes.WriteString(strconv.Itoa(t.Biome))
+		biomes.WriteByte(',')
+		if t.Settleable {
+			settleable = append(settleable, [2]int{t.Q, t.R})
+		}
+	}
+	biomes.WriteByte('\n')
+	if err := os.WriteFile(filepath.Join(dir, biomesFile), biomes.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(settleable)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, settleableFile), b, 0o644)
+}
+
+// ReadFiles reads the tiles of a world from the files of a world in the directory, sorted by q and then r.
+func ReadFiles(dir string) ([]*game.MapTile, error) {
+	data, err := os.ReadFile(filepath.Join(dir, biomesFile))
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(dir, settleableFile))
+	if err != nil {
+		return nil, err
+	}
+	var coords [][2]int
+	if err := json.Unmarshal(b, &coords); err != nil {
+		return nil, fmt.Errorf("invalid settleable tiles: %w", err)
+	}
+	settleable := make(map[[2]int]bool, len(coords))
+	for _, c := range coords {
+		settleable[c] = true
+	}
+
+	var tiles []*game.MapTile
+	for q, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		for r, field := range strings.Split(strings.TrimSuffix(strings.TrimSpace(line), ","), ",") {
+			biome, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid biome at (%d, %d): %w", q, r, err)
+			}
+			tiles = append(tiles, &game.MapTile{Q: q, R: r, Biome: biome, Settleable: settleable[[2]int{q, r}]})
+		}
+	}
+	return tiles, nil
+}
@@ -0,0 +1,31 @@
+package worldgen
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_WriteFiles_ReadFiles(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	tiles, err := Generate(testConfig(7))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// when
+	err = WriteFiles(dir, tiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := ReadFiles(dir)
+
+	// then
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(tiles, got); diff != "" {
+		t.Errorf("unexpected tiles (-want, +got): %s", diff)
+	}
+}
@@ -0,0 +1,65 @@
+package worldgen
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// gradients of the simplex noise, the 8 directions of a square.
+var gradients = [8][2]float64{{1, 1}, {-1, 1}, {1, -1}, {-1, -1}, {1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+
+var (
+	skew   = (math.Sqrt(3) - 1) / 2
+	unskew = (3 - math.Sqrt(3)) / 6
+)
+
+// noise is a 2D simplex noise, with values roughly in [-1, 1], seeded by the permutation of its gradients.
+type noise struct {
+	perm [512]uint8
+}
+
+func newNoise(rng *rand.Rand) *noise {
+	n := &noise{}
+	for i, p := range rng.Perm(256) {
+		n.perm[i] = uint8(p)
+		n.perm[i+256] = uint8(p)
+	}
+	return n
+}
+
+func (n *noise) at(x, y float64) float64 {
+	// the simplex (triangle) of the point, in the skewed grid
+	s := (x + y) * skew
+	i, j := math.Floor(x+s), math.Floor(y+s)
+	t := (i + j) * unskew
+	x0, y0 := x-(i-t), y-(j-t)
+
+	i1, j1 := 0, 1
+	if x0 > y0 {
+		i1, j1 = 1, 0
+	}
+	x1, y1 := x0-float64(i1)+unskew, y0-float64(j1)+unskew
+	x2, y2 := x0-1+2*unskew, y0-1+2*unskew
+
+	ii, jj := int(i)&255, int(j)&255
+	corners := [3]struct {
+		x, y float64
+		g    uint8
+	}{
+		{x0, y0, n.perm[ii+int(n.perm[jj])]},
+		{x1, y1, n.perm[ii+i1+int(n.perm[jj+j1])]},
+		{x2, y2, n.perm[ii+1+int(n.perm[jj+1])]},
+	}
+
+	var value float64
+	for _, c := range corners {
+		falloff := 0.5 - c.x*c.x - c.y*c.y
+		if falloff <= 0 {
+			continue
+		}
+		g := gradients[c.g%8]
+		falloff *= falloff
+		value += falloff * falloff * (g[0]*c.x + g[1]*c.y)
+	}
+	return 70 * value
+}
@@ -0,0 +1,301 @@
+// Package worldgen generates the hex worlds of the game: islands of beaches, plains and mountains spread over the
+// ocean, each surrounded by sea and with some tiles where cities can be settled. The same configuration, seed
+// included, always generates the same world.
+package worldgen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/luisferreira32/stickian/server/internal/game"
+	"github.com/luisferreira32/stickian/server/internal/game/hex"
+)
+
+// maxIslandAttempts is how many shapes are tried for an island before giving up on it, since with an unlucky
+// noise (e.g., an island cut by the world border) its biome counts might never be in range.
+const maxIslandAttempts = 1000
+
+// Config defines the world to generate.
+type Config struct {
+	// Seed of the generator, the same seed generates the same world
+	Seed uint64
+	// Size is the number of tiles of both axes of the world
+	Size int
+	// Border is the number of tiles of the world edges without island centres
+	Border int
+	// Candidates is the number of tries to place each island centre
+	Candidates int
+	// IslandDistance is the minimum distance between island centres
+	IslandDistance int
+	// IslandRadius is the base radius of an island, grown and shrunk by the noise
+	IslandRadius int
+	// NoiseScales are the scales of the three noise layers shaping an island, from the coarsest to the finest
+	NoiseScales [3]float64
+	// Beaches, Plains and Mountains are the (inclusive) ranges of the tiles of each biome in an island, where the
+	// minimum of each range is also the number of settleable tiles of the biome
+	Beaches   [2]int
+	Plains    [2]int
+	Mountains [2]int
+}
+
+// DefaultConfig returns the configuration of the game world, with the given seed.
+func DefaultConfig(seed uint64) Config {
+	return Config{
+		Seed:           seed,
+		Size:           256,
+		Border:         8,
+		Candidates:     30,
+		IslandDistance: 15,
+		IslandRadius:   4,
+		NoiseScales:    [3]float64{0.01, 0.10, 0.30},
+		Beaches:        [2]int{15, 20},
+		Plains:         [2]int{9, 14},
+		Mountains:      [2]int{3, 8},
+	}
+}
+
+// Validate returns an error if no world can be generated with the configuration.
+func (c Config) Validate() error {
+	switch {
+	case c.Size <= 0:
+		return errors.New("size must be positive")
+	case c.Border < 0 || c.Border >= c.Size/2:
+		return errors.New("border must be non-negative and less than half the size")
+	case c.Size <= (c.IslandRadius+c.Border)*2:
+		return errors.New("size must be larger than twice the island radius plus border")
+	case c.Candidates <= 0:
+		return errors.New("candidates must be positive")
+	case c.IslandDistance <= 0 || c.IslandRadius <= 0:
+		return errors.New("island distance and radius must be positive")
+	case c.IslandDistance < c.IslandRadius*2:
+		return errors.New("island distance must be at least twice the island radius")
+	case c.IslandDistance > c.Size/2:
+		return errors.New("island distance must be less than half the size")
+	}
+	for _, scale := range c.NoiseScales {
+		if scale <= 0 {
+			return errors.New("noise scales must be positive")
+		}
+	}
+	for _, r := range [][2]int{c.Beaches, c.Plains, c.Mountains} {
+		if r[0] < 0 || r[0] > r[1] {
+			return fmt.Errorf("invalid tile count range: %v", r)
+		}
+	}
+	return nil
+}
+
+// island are the tiles of an island by biome, each sorted such that sampling them is reproducible.
+type island struct {
+	sea, beaches, plains, mountains []hex.Hex
+}
+
+// classify splits the tiles of an island into its biomes: the beaches touch the water around the island, the
+// mountains are only surrounded by the plains and mountains of the island, and the plains are the rest.
+func classify(tiles map[hex.Hex]bool) *island {
+	sea := map[hex.Hex]bool{}
+	inland := map[hex.Hex]bool{}
+	var i island
+	for t := range tiles {
+		beach := false
+		for _, n := range t.Neighbors() {
+			if !tiles[n] {
+				sea[n] = true
+				beach = true
+			}
+		}
+		if beach {
+			i.beaches = append(i.beaches, t)
+		} else {
+			inland[t] = true
+		}
+	}
+	for t := range inland {
+		mountain := true
+		for _, n := range t.Neighbors() {
+			if !inland[n] {
+				mountain = false
+				break
+			}
+		}
+		if mountain {
+			i.mountains = append(i.mountains, t)
+		} else {
+			i.plains = append(i.plains, t)
+		}
+	}
+	for t := range sea {
+		i.sea = append(i.sea, t)
+	}
+	for _, tiles := range [][]hex.Hex{i.sea, i.beaches, i.plains, i.mountains} {
+		slices.SortFunc(tiles, compareTiles)
+	}
+	return &i
+}
+
+func compareTiles(a, b hex.Hex) int {
+	if a.Q != b.Q {
+		return a.Q - b.Q
+	}
+	return a.R - b.R
+}
+
+type generator struct {
+	Config
+	rng *rand.Rand
+}
+
+// Generate returns all tiles of a new world, sorted by q and then r.
+func Generate(cfg Config) ([]*game.MapTile, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid world config: %w", err)
+	}
+	g := &generator{Config: cfg, rng: rand.New(rand.NewPCG(cfg.Seed, cfg.Seed))}
+
+	biomes := make([][]int, cfg.Size) // all ocean
+	for q := range biomes {
+		biomes[q] = make([]int, cfg.Size)
+	}
+	settleable := map[hex.Hex]bool{}
+	for _, center := range g.islandCenters() {
+		i, ok := g.island(center)
+		if !ok {
+			continue
+		}
+		for _, t := range i.beaches {
+			biomes[t.Q][t.R] = game.BiomeBeach
+		}
+		for _, t := range i.plains {
+			biomes[t.Q][t.R] = game.BiomePlains
+		}
+		for _, t := range i.mountains {
+			biomes[t.Q][t.R] = game.BiomeMountain
+		}
+		for _, t := range i.sea {
+			// the world edge and the (unlikely) land of a neighbouring island are not sea
+			if g.inside(t) && biomes[t.Q][t.R] == game.BiomeOcean {
+				biomes[t.Q][t.R] = game.BiomeSea
+			}
+		}
+		for _, t := range g.sample(i.beaches, cfg.Beaches[0]) {
+			settleable[t] = true
+		}
+		for _, t := range g.sample(i.plains, cfg.Plains[0]) {
+			settleable[t] = true
+		}
+		for _, t := range g.sample(i.mountains, cfg.Mountains[0]) {
+			settleable[t] = true
+		}
+	}
+
+	tiles := make([]*game.MapTile, 0, cfg.Size*cfg.Size)
+	for q := range cfg.Size {
+		for r := range cfg.Size {
+			tiles = append(tiles, &game.MapTile{Q: q, R: r, Biome: biomes[q][r], Settleable: settleable[hex.Hex{Q: q, R: r}]})
+		}
+	}
+	return tiles, nil
+}
+
+func (g *generator) inside(t hex.Hex) bool {
+	return t.Q >= 0 && t.Q < g.Size && t.R >= 0 && t.R < g.Size
+}
+
+// islandCenters places the island centres inside the border with a Poisson disc sampling, i.e., as many as fit
+// without any two being closer than the island distance.
+func (g *generator) islandCenters() []hex.Hex {
+	width := float64(g.Size - 2*g.Border)
+	radius := float64(g.IslandDistance) / width
+	cell := radius / math.Sqrt2 // at most one sample per cell
+	cells := int(math.Ceil(1 / cell))
+	grid := make([]int, cells*cells) // the index of the sample of each cell, plus one
+	cellOf := func(p [2]float64) (int, int) {
+		return min(int(p[0]/cell), cells-1), min(int(p[1]/cell), cells-1)
+	}
+
+	samples := [][2]float64{{g.rng.Float64(), g.rng.Float64()}}
+	x, y := cellOf(samples[0])
+	grid[y*cells+x] = 1
+	active := []int{0}
+	for len(active) > 0 {
+		a := g.rng.IntN(len(active))
+		p := samples[active[a]]
+		found := false
+		for range g.Candidates {
+			// a candidate in the annulus between one and two radii of the sample
+			angle := 2 * math.Pi * g.rng.Float64()
+			distance := radius * math.Sqrt(1+3*g.rng.Float64())
+			c := [2]float64{p[0] + distance*math.Cos(angle), p[1] + distance*math.Sin(angle)}
+			if c[0] < 0 || c[0] >= 1 || c[1] < 0 || c[1] >= 1 {
+				continue
+			}
+			cx, cy := cellOf(c)
+			free := true
+			for ny := max(cy-2, 0); ny <= min(cy+2, cells-1) && free; ny++ {
+				for nx := max(cx-2, 0); nx <= min(cx+2, cells-1) && free; nx++ {
+					if s := grid[ny*cells+nx]; s > 0 && math.Hypot(samples[s-1][0]-c[0], samples[s-1][1]-c[1]) < radius {
+						free = false
+					}
+				}
+			}
+			if free {
+				samples = append(samples, c)
+				grid[cy*cells+cx] = len(samples)
+				active = append(active, len(samples)-1)
+				found = true
+				break
+			}
+		}
+		if !found {
+			active = slices.Delete(active, a, a+1)
+		}
+	}
+
+	centers := make([]hex.Hex, len(samples))
+	for i, s := range samples {
+		centers[i] = hex.Hex{Q: int(s[0]*width) + g.Border, R: int(s[1]*width) + g.Border}
+	}
+	return centers
+}
+
+// island shapes an island around the centre, as the tiles closer to the centre than the island radius grown or
+// shrunk by the noise, until its biome counts are within range.
+func (g *generator) island(center hex.Hex) (*island, bool) {
+	for range maxIslandAttempts {
+		n := newNoise(g.rng)
+		tiles := map[hex.Hex]bool{}
+		for q := max(0, center.Q-g.IslandRadius); q < min(g.Size, center.Q+g.IslandRadius); q++ {
+			for r := max(0, center.R-g.IslandRadius); r < min(g.Size, center.R+g.IslandRadius); r++ {
+				var value float64
+				for _, scale := range g.NoiseScales {
+					value += n.at(float64(q)*scale, float64(r)*scale)
+				}
+				threshold := float64(g.IslandRadius) * (1 + 0.4*value)
+				if t := (hex.Hex{Q: q, R: r}); float64(hex.Distance(center, t)) < threshold {
+					tiles[t] = true
+				}
+			}
+		}
+
+		i := classify(tiles)
+		if inRange(len(i.beaches), g.Beaches) && inRange(len(i.plains), g.Plains) &&
+			inRange(len(i.mountains), g.Mountains) {
+			return i, true
+		}
+	}
+	return nil, false
+}
+
+func inRange(n int, r [2]int) bool {
+	return r[0] <= n && n <= r[1]
+}
+
+// sample returns n random tiles, or all of them if there are not as many.
+func (g *generator) sample(tiles []hex.Hex, n int) []hex.Hex {
+	tiles = slices.Clone(tiles)
+	g.rng.Shuffle(len(tiles), func(i, j int) { tiles[i], tiles[j] = tiles[j], tiles[i] })
+	return tiles[:min(n, len(tiles))]
+}
@@ -0,0 +1,133 @@
+package worldgen
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/luisferreira32/stickian/server/internal/game"
+	"github.com/luisferreira32/stickian/server/internal/game/hex"
+)
+
+func testConfig(seed uint64) Config {
+	cfg := DefaultConfig(seed)
+	cfg.Size = 64
+	return cfg
+}
+
+func Test_Generate_Reproducible(t *testing.T) {
+	// given
+	first, err := Generate(testConfig(42))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// when
+	second, err := Generate(testConfig(42))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other, err := Generate(testConfig(43))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// then
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Errorf("same seed, different worlds (-want, +got): %s", diff)
+	}
+	if cmp.Equal(first, other) {
+		t.Errorf("different seeds, same world")
+	}
+}
+
+func Test_Generate_Biomes(t *testing.T) {
+	for _, seed := range []uint64{1, 2, 3, 4, 5} {
+		cfg := testConfig(seed)
+		tiles, err := Generate(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(tiles) != cfg.Size*cfg.Size {
+			t.Fatalf("unexpected number of tiles: %v", len(tiles))
+		}
+
+		biomes := map[hex.Hex]int{}
+		for i, mt := range tiles {
+			if mt.Q != i/cfg.Size || mt.R != i%cfg.Size {
+				t.Fatalf("unexpected tile order: %v at %v", mt, i)
+			}
+			biomes[hex.Hex{Q: mt.Q, R: mt.R}] = mt.Biome
+		}
+
+		counts := map[int]int{}
+		for _, mt := range tiles {
+			counts[mt.Biome]++
+			land, water := 0, 0
+			for _, n := range (hex.Hex{Q: mt.Q, R: mt.R}).Neighbors() {
+				if b, ok := biomes[n]; !ok || b <= game.BiomeSea {
+					water++
+				} else {
+					land++
+				}
+			}
+			switch mt.Biome {
+			case game.BiomeOcean:
+				if land > 0 {
+					t.Errorf("seed %v: ocean next to land at (%v, %v)", seed, mt.Q, mt.R)
+				}
+			case game.BiomeBeach:
+				if water == 0 {
+					t.Errorf("seed %v: beach without water at (%v, %v)", seed, mt.Q, mt.R)
+				}
+			case game.BiomePlains, game.BiomeMountain:
+				if water > 0 {
+					t.Errorf("seed %v: inland biome next to water at (%v, %v)", seed, mt.Q, mt.R)
+				}
+			case game.BiomeSea:
+			default:
+				t.Errorf("seed %v: unknown biome %v at (%v, %v)", seed, mt.Biome, mt.Q, mt.R)
+			}
+			if mt.Settleable && mt.Biome <= game.BiomeSea {
+				t.Errorf("seed %v: settleable water at (%v, %v)", seed, mt.Q, mt.R)
+			}
+		}
+		for _, biome := range []int{game.BiomeSea, game.BiomeBeach, game.BiomePlains, game.BiomeMountain} {
+			if counts[biome] == 0 {
+				t.Errorf("seed %v: no tiles of biome %v", seed, biome)
+			}
+		}
+	}
+}
+
+func Test_Config_Validate(t *testing.T) {
+	testcases := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{name: "default", modify: func(*Config) {}},
+		{name: "no size", modify: func(c *Config) { c.Size = 0 }, wantErr: true},
+		{name: "border over half the size", modify: func(c *Config) { c.Border = 128 }, wantErr: true},
+		{name: "no candidates", modify: func(c *Config) { c.Candidates = 0 }, wantErr: true},
+		{name: "islands overlap", modify: func(c *Config) { c.IslandDistance = 7 }, wantErr: true},
+		{name: "negative noise scale", modify: func(c *Config) { c.NoiseScales[1] = -1 }, wantErr: true},
+		{name: "inverted range", modify: func(c *Config) { c.Plains = [2]int{14, 9} }, wantErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			cfg := DefaultConfig(1)
+			tc.modify(&cfg)
+
+			// when
+			err := cfg.Validate()
+
+			// then
+			if (err != nil) != tc.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
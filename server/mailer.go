@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/luisferreira32/stickian/server/internal/mail"
+)
+
+// mailerConfig defines how the emails are sent, see the mailer constants.
+type mailerConfig struct {
+	kind string
+	// file is where the emails are appended with the file mailer
+	file string
+	// from, address, username and password configure the SMTP mailer
+	from     string
+	address  string
+	username string
+	password string
+}
+
+// newMailer returns the configured mailer, and a function to release its resources.
+func newMailer(cfg mailerConfig) (mail.Mailer, func(), error) {
+	switch cfg.kind {
+	case mailerSMTP:
+		if cfg.address == "" || cfg.from == "" {
+			return nil, nil, fmt.Errorf("the smtp mailer requires SMTP_ADDRESS and MAIL_FROM")
+		}
+		return &mail.SMTPMailer{Address: cfg.address, Username: cfg.username, Password: cfg.password, From: cfg.from}, func() {}, nil
+	case mailerFile:
+		f, err := os.OpenFile(cfg.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open mail file: %w", err)
+		}
+		return &mail.WriterMailer{W: f}, func() { _ = f.Close() }, nil
+	default:
+		return &mail.WriterMailer{W: os.Stdout}, func() {}, nil
+	}
+}
@@ -5,7 +5,12 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
+
+	"github.com/luisferreira32/stickian/server/internal/ratelimit"
 )
 
 func parseDefault(envVar, defaultValue string) string {
@@ -15,21 +20,121 @@ func parseDefault(envVar, defaultValue string) string {
 	return defaultValue
 }
 
+func parseDuration(envVar, defaultValue string) time.Duration {
+	d, err := time.ParseDuration(parseDefault(envVar, defaultValue))
+	if err != nil || d <= 0 {
+		log.Panicf("invalid %s: %v", envVar, err)
+	}
+	return d
+}
+
+func parseConns(envVar, defaultValue string) int32 {
+	n, err := strconv.ParseInt(parseDefault(envVar, defaultValue), 10, 32)
+	if err != nil || n < 0 {
+		log.Panicf("invalid %s: %v", envVar, err)
+	}
+	return int32(n)
+}
+
+func parseLimit(envVar, defaultValue string) ratelimit.Limit {
+	limit, err := ratelimit.ParseLimit(parseDefault(envVar, defaultValue))
+	if err != nil {
+		log.Panicf("invalid %s: %v", envVar, err)
+	}
+	return limit
+}
+
+// rateLimitConfig defines the rate limits of the API, see the rate limit defaults.
+type rateLimitConfig struct {
+	ip   ratelimit.Limit
+	user ratelimit.Limit
+	auth ratelimit.Limit
+	// trustProxy reads the client IP from the X-Forwarded-For header, only safe behind a proxy that sets it
+	trustProxy bool
+}
+
+// config defines the configuration of the server, read from the environment.
+type config struct {
+	address       string
+	development   bool
+	storage       string
+	databaseURL   string
+	migrationsURL string
+	pool          poolConfig
+	worldData     string
+	secretKey     string
+	tickDuration  time.Duration
+	// accessTokenDuration and refreshTokenDuration are the lifetimes of the issued tokens
+	accessTokenDuration  time.Duration
+	refreshTokenDuration time.Duration
+	publicURL            string
+	mailer               mailerConfig
+	requireVerifiedEmail bool
+	rateLimits           rateLimitConfig
+}
+
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	address := parseDefault("SERVER_ADDRESS", defaultAddress)
-	development := parseDefault("DEVELOPMENT", "true") == "true"
-	databaseURL := parseDefault("DATABASE_URL", testDatabaseURL)
-	migrationsURL := parseDefault("MIGRATIONS_URL", deafultMigrationsURL)
-	secretKey := parseDefault("SECRET_KEY", testSecretKey)
+	if len(os.Args) > 1 && os.Args[1] == "worldgen" {
+		if err := worldgenCommand(ctx, os.Args[2:]); err != nil {
+			log.Panicf("worldgen error: %v", err)
+		}
+		return
+	}
+
+	cfg := config{
+		address:       parseDefault("SERVER_ADDRESS", defaultAddress),
+		development:   parseDefault("DEVELOPMENT", "true") == "true",
+		storage:       parseDefault("STORAGE", storagePostgres),
+		databaseURL:   parseDefault("DATABASE_URL", testDatabaseURL),
+		migrationsURL: parseDefault("MIGRATIONS_URL", deafultMigrationsURL),
+		pool: poolConfig{
+			MaxConns:          parseConns("DATABASE_MAX_CONNS", defaultDatabaseMaxConns),
+			MinConns:          parseConns("DATABASE_MIN_CONNS", defaultDatabaseMinConns),
+			MaxConnLifetime:   parseDuration("DATABASE_MAX_CONN_LIFETIME", defaultDatabaseMaxConnLifetime),
+			MaxConnIdleTime:   parseDuration("DATABASE_MAX_CONN_IDLE_TIME", defaultDatabaseMaxConnIdleTime),
+			HealthCheckPeriod: parseDuration("DATABASE_HEALTH_CHECK_PERIOD", defaultDatabaseHealthCheckPeriod),
+			ConnectTimeout:    parseDuration("DATABASE_CONNECT_TIMEOUT", defaultDatabaseConnectTimeout),
+		},
+		worldData:            os.Getenv("WORLD_DATA"),
+		secretKey:            parseDefault("SECRET_KEY", testSecretKey),
+		tickDuration:         parseDuration("TICK_DURATION", defaultTickDuration),
+		accessTokenDuration:  parseDuration("ACCESS_TOKEN_DURATION", defaultAccessTokenDuration),
+		refreshTokenDuration: parseDuration("REFRESH_TOKEN_DURATION", defaultRefreshTokenDuration),
+		publicURL:            strings.TrimSuffix(parseDefault("PUBLIC_URL", defaultPublicURL), "/"),
+		mailer: mailerConfig{
+			kind:     parseDefault("MAILER", mailerStdout),
+			file:     os.Getenv("MAIL_FILE"),
+			from:     os.Getenv("MAIL_FROM"),
+			address:  os.Getenv("SMTP_ADDRESS"),
+			username: os.Getenv("SMTP_USERNAME"),
+			password: os.Getenv("SMTP_PASSWORD"),
+		},
+		requireVerifiedEmail: parseDefault("REQUIRE_VERIFIED_EMAIL", "false") == "true",
+		rateLimits: rateLimitConfig{
+			ip:         parseLimit("RATE_LIMIT_IP", defaultRateLimitIP),
+			user:       parseLimit("RATE_LIMIT_USER", defaultRateLimitUser),
+			auth:       parseLimit("RATE_LIMIT_AUTH", defaultRateLimitAuth),
+			trustProxy: parseDefault("TRUST_PROXY", "false") == "true",
+		},
+	}
+	if cfg.pool.MaxConns < 1 || cfg.pool.MinConns > cfg.pool.MaxConns {
+		log.Panicf("invalid database pool size: min %d, max %d", cfg.pool.MinConns, cfg.pool.MaxConns)
+	}
+	if cfg.storage != storagePostgres && cfg.storage != storageMemory {
+		log.Panicf("invalid storage: %s", cfg.storage)
+	}
+	if cfg.mailer.kind != mailerStdout && cfg.mailer.kind != mailerFile && cfg.mailer.kind != mailerSMTP {
+		log.Panicf("invalid mailer: %s", cfg.mailer.kind)
+	}
 
-	if (testDatabaseURL == databaseURL || secretKey == testSecretKey) && !development {
+	if (testDatabaseURL == cfg.databaseURL || cfg.secretKey == testSecretKey || cfg.storage == storageMemory || cfg.mailer.kind != mailerSMTP) && !cfg.development {
 		log.Panicf("no.")
 	}
 
-	err := run(ctx, address, databaseURL, migrationsURL, secretKey, development)
+	err := run(ctx, cfg)
 	if err != nil {
 		log.Panicf("server error: %v", err)
 	}
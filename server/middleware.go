@@ -1,15 +1,19 @@
 package main
 
 import (
-	"context"
 	"log"
 	"mime"
+	"net"
 	"net/http"
 	"path/filepath"
 	"runtime/debug"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/luisferreira32/stickian/server/internal/auth"
+	"github.com/luisferreira32/stickian/server/internal/ratelimit"
+	"github.com/luisferreira32/stickian/server/internal/utils"
 )
 
 func chainMiddleware(f http.HandlerFunc, middlewares ...func(http.HandlerFunc) http.HandlerFunc) http.HandlerFunc {
@@ -82,19 +86,31 @@ func compressionMiddleware() func(http.HandlerFunc) http.HandlerFunc {
 var (
 	// noAuthEndpoints is an allowlist for endpoints that do not require authentication
 	noAuthEndpoints = map[string]struct{}{
-		"POST /api/login":  {},
-		"POST /api/signup": {},
+		"POST /api/login":           {},
+		"POST /api/signup":          {},
+		"POST /api/refresh":         {}, // authenticated by the refresh token
+		"POST /api/logout":          {}, // authenticated by the refresh token
+		"GET /api/verify-email":     {}, // authenticated by the verification token
+		"POST /api/password/forgot": {},
+		"POST /api/password/reset":  {}, // authenticated by the password reset token
+	}
+	// streamEndpoints are the endpoints that also take a stream token in the token query parameter instead of the
+	// Authorization header, since browsers cannot set the headers of a stream
+	streamEndpoints = map[string]struct{}{
+		"GET /api/stream": {},
 	}
 )
 
-// authMiddleware validates the JWT in the Authorization header and adds the user ID to the context
+// authMiddleware validates the JWT in the Authorization header, or the stream token of a stream (see
+// auth.AudienceStream), and adds its principal (see auth.Principal) to the context
 //
 // The middleware should be chained for all endpoints per default, and the noAuthEndpoint variable
 // should be used to specify any endpoints that should skip authentication (e.g. login, signup). This ensures a
 // default secure behavior while allowing flexibility for public endpoints.
 //
 // Endpoints are still responsible for implementing the authorization part, i.e., checking if a certain
-// user is allowed to perform a certain action, by using the user ID in the context.
+// user is allowed to perform a certain action, by using the principal in the context, or by chaining
+// auth.RequireRoles for endpoints restricted to some roles.
 func authMiddleware(secretKey string) func(http.HandlerFunc) http.HandlerFunc {
 	return func(f http.HandlerFunc) http.HandlerFunc {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -103,33 +119,87 @@ func authMiddleware(secretKey string) func(http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 
-			tokenString := r.Header.Get("Authorization")
-			if tokenString == "" {
+			// the access tokens are only taken from the header, and the stream tokens only from the query of a stream
+			var tokenString, audience string
+			_, stream := streamEndpoints[r.Method+" "+r.URL.Path]
+			if header := r.Header.Get("Authorization"); header != "" {
+				var ok bool
+				tokenString, ok = strings.CutPrefix(header, "Bearer ")
+				if !ok {
+					http.Error(w, "invalid authorization header format", http.StatusUnauthorized)
+					return
+				}
+			} else if stream && r.URL.Query().Get("token") != "" {
+				tokenString, audience = r.URL.Query().Get("token"), auth.AudienceStream
+			} else {
 				http.Error(w, "missing authorization token", http.StatusUnauthorized)
 				return
 			}
-			tokenString, ok := strings.CutPrefix(tokenString, "Bearer ")
-			if !ok {
-				http.Error(w, "invalid authorization header format", http.StatusUnauthorized)
-				return
+			options := []jwt.ParserOption{jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired()}
+			if audience != "" {
+				options = append(options, jwt.WithAudience(audience))
 			}
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+			claims := &auth.Claims{}
+			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
 				return []byte(secretKey), nil
-			}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+			}, options...)
 			if err != nil || !token.Valid {
 				http.Error(w, "invalid token", http.StatusUnauthorized)
 				return
 			}
-
-			sub, err := token.Claims.GetSubject()
-			if err != nil {
+			if claims.Subject == "" || (audience == "" && len(claims.Audience) > 0) {
 				http.Error(w, "invalid token claims", http.StatusUnauthorized)
 				return
 			}
 
-			// add user ID from token claims to request context for future handlers to use in authorization
-			r = r.WithContext(context.WithValue(r.Context(), "sub", sub))
+			// add the principal from token claims to request context for future handlers to use in authorization
+			r = r.WithContext(auth.WithPrincipal(r.Context(), claims.Principal()))
 			f(w, r)
 		})
 	}
 }
+
+// rateLimitMiddleware limits the requests with a token bucket per key, rejecting the ones over the limit with a
+// 429 and the time to wait in the Retry-After header. Requests without a key (e.g., anonymous ones when limiting
+// per user) are not limited.
+//
+// Every middleware has its own buckets, so the same middleware can be chained to several endpoints to limit them
+// together, or a new one chained to a single endpoint to limit it on its own (e.g., the login).
+func rateLimitMiddleware(limit ratelimit.Limit, key func(r *http.Request) string) func(http.HandlerFunc) http.HandlerFunc {
+	limiter := ratelimit.NewLimiter(limit)
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if k := key(r); k != "" {
+				if ok, retryAfter := limiter.Allow(k); !ok {
+					utils.WithTooManyRequests(w, retryAfter)
+					return
+				}
+			}
+			f(w, r)
+		}
+	}
+}
+
+// clientIP returns the key of the client IP address, from the last address of the X-Forwarded-For header if the
+// server runs behind a trusted proxy, since that is the one added by the proxy and cannot be spoofed by clients.
+func clientIP(trustProxy bool) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); trustProxy && forwardedFor != "" {
+			addresses := strings.Split(forwardedFor, ",")
+			return "ip:" + strings.TrimSpace(addresses[len(addresses)-1])
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return "ip:" + r.RemoteAddr
+		}
+		return "ip:" + host
+	}
+}
+
+// authenticatedUser returns the key of the authenticated user, so it must be chained after the authMiddleware.
+func authenticatedUser(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return "sub:" + principal.UserID
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS game_clock (
+    id          BOOLEAN       PRIMARY KEY DEFAULT TRUE CHECK (id),
+    started_at  TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    last_tick   BIGINT        NOT NULL DEFAULT -1
+);
+
+INSERT INTO game_clock DEFAULT VALUES ON CONFLICT DO NOTHING;
+
+
+CREATE TABLE IF NOT EXISTS game_events (
+    seq         BIGSERIAL     NOT NULL,
+    key         VARCHAR(255)  NOT NULL PRIMARY KEY,
+    tick        BIGINT        NOT NULL,
+    type        VARCHAR(64)   NOT NULL,
+    city_id     UUID          REFERENCES city(id) ON DELETE CASCADE,
+    payload     JSONB         NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS game_events_tick_idx ON game_events (tick, seq);
+-- the ticks load the queues of the cities their events act on
+CREATE INDEX IF NOT EXISTS game_events_city_idx ON game_events (city_id, tick);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS city_units (
+    city_id     UUID          NOT NULL REFERENCES city(id) ON DELETE CASCADE,
+    unit        VARCHAR(64)   NOT NULL,
+    count       INT           NOT NULL DEFAULT 0 CHECK (count >= 0),
+    PRIMARY KEY (city_id, unit)
+);
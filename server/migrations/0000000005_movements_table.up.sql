@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS movements (
+    id              VARCHAR(255)  PRIMARY KEY,
+    city_id         UUID          NOT NULL REFERENCES city(id) ON DELETE CASCADE,
+    player_id       UUID          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mission         VARCHAR(32)   NOT NULL,
+    q               INT           NOT NULL,
+    r               INT           NOT NULL,
+    target_city_id  UUID          REFERENCES city(id) ON DELETE SET NULL,
+    units           JSONB         NOT NULL DEFAULT '{}',
+    start_tick      BIGINT        NOT NULL,
+    arrival_tick    BIGINT        NOT NULL,
+    returning       BOOLEAN       NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS movements_city_idx ON movements (city_id);
+CREATE INDEX IF NOT EXISTS movements_target_city_idx ON movements (target_city_id);
+-- the ticks only load the movements arriving on them
+CREATE INDEX IF NOT EXISTS movements_arrival_tick_idx ON movements (arrival_tick);
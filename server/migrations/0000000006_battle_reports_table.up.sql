@@ -0,0 +1,12 @@
+ALTER TABLE movements ADD COLUMN IF NOT EXISTS resources JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS battle_reports (
+    id              VARCHAR(255)  PRIMARY KEY,
+    tick            BIGINT        NOT NULL,
+    attacker_id     UUID          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    defender_id     UUID          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report          JSONB         NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS battle_reports_attacker_idx ON battle_reports (attacker_id, tick);
+CREATE INDEX IF NOT EXISTS battle_reports_defender_idx ON battle_reports (defender_id, tick);
@@ -0,0 +1,5 @@
+ALTER TABLE city ADD COLUMN IF NOT EXISTS loyalty INT NOT NULL DEFAULT 100 CHECK (loyalty >= 0);
+
+ALTER TABLE movements ADD COLUMN IF NOT EXISTS city_name VARCHAR(128) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS city_player_idx ON city (player_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    seq         BIGSERIAL     PRIMARY KEY,
+    player_id   UUID          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tick        BIGINT        NOT NULL,
+    type        VARCHAR(64)   NOT NULL,
+    city_id     UUID          REFERENCES city(id) ON DELETE SET NULL,
+    payload     JSONB         NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS notifications_player_idx ON notifications (player_id, seq);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id          UUID          PRIMARY KEY,
+    family_id   UUID          NOT NULL,
+    user_id     UUID          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash  BYTEA         NOT NULL UNIQUE,
+    created_at  TIMESTAMPTZ   NOT NULL,
+    expires_at  TIMESTAMPTZ   NOT NULL,
+    rotated_at  TIMESTAMPTZ,
+    revoked_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sessions_family_idx ON sessions (family_id);
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_tokens (
+    token_hash  BYTEA         PRIMARY KEY,
+    user_id     UUID          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose     VARCHAR(64)   NOT NULL,
+    created_at  TIMESTAMPTZ   NOT NULL,
+    expires_at  TIMESTAMPTZ   NOT NULL,
+    used_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_idx ON user_tokens (user_id);
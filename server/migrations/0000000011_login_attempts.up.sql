@@ -0,0 +1,9 @@
+-- the consecutive login attempts on an email from a client, which lock out only that client, and from all the
+-- clients together under the client '*', which lock out the email from every client
+CREATE TABLE IF NOT EXISTS login_attempts (
+    email           VARCHAR(255)  NOT NULL,
+    client          VARCHAR(255)  NOT NULL,
+    attempts        INT           NOT NULL,
+    locked_until    TIMESTAMPTZ   NOT NULL,
+    PRIMARY KEY (email, client)
+);
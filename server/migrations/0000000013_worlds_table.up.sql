@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS worlds (
+    id          UUID          PRIMARY KEY DEFAULT gen_random_uuid(),
+    name        VARCHAR(128)  NOT NULL UNIQUE,
+    size        INT           NOT NULL CHECK (size > 0),
+    speed       INT           NOT NULL DEFAULT 1 CHECK (speed > 0),
+    status      VARCHAR(16)   NOT NULL DEFAULT 'open',
+    starts_at   TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    ends_at     TIMESTAMPTZ,
+    -- the strategy that places the first city of new players, e.g., spawn or diagonal
+    placement   VARCHAR(16)   NOT NULL DEFAULT 'spawn'
+);
+
+-- the classic world, the one of the server before there were several worlds
+INSERT INTO worlds (id, name, size) VALUES ('00000000-0000-0000-0000-000000000001', 'classic', 256)
+    ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE world ADD COLUMN IF NOT EXISTS world_id UUID NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES worlds(id) ON DELETE CASCADE;
+ALTER TABLE world ALTER COLUMN world_id DROP DEFAULT;
+ALTER TABLE world DROP CONSTRAINT IF EXISTS world_pkey;
+ALTER TABLE world ADD PRIMARY KEY (world_id, q, r);
+
+ALTER TABLE city ADD COLUMN IF NOT EXISTS world_id UUID NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES worlds(id) ON DELETE CASCADE;
+ALTER TABLE city ALTER COLUMN world_id DROP DEFAULT;
+ALTER TABLE city DROP CONSTRAINT IF EXISTS city_unique_coords;
+ALTER TABLE city ADD CONSTRAINT city_unique_coords UNIQUE (world_id, q, r);
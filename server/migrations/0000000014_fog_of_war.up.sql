@@ -0,0 +1,24 @@
+-- the tiles each player explored in a world, a bit per tile by q and then r
+CREATE TABLE IF NOT EXISTS explored_tiles (
+    player_id   UUID          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    world_id    UUID          NOT NULL REFERENCES worlds(id) ON DELETE CASCADE,
+    tiles       BIT VARYING   NOT NULL,
+    PRIMARY KEY (player_id, world_id)
+);
+
+-- the cities each player saw, as they were when last seen
+CREATE TABLE IF NOT EXISTS city_sightings (
+    player_id   UUID          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    city_id     UUID          NOT NULL REFERENCES city(id) ON DELETE CASCADE,
+    world_id    UUID          NOT NULL REFERENCES worlds(id) ON DELETE CASCADE,
+    owner_id    UUID          NOT NULL,
+    name        VARCHAR(128)  NOT NULL,
+    q           INT           NOT NULL,
+    r           INT           NOT NULL,
+    biome       INT           NOT NULL,
+    points      INT           NOT NULL,
+    seen_at     TIMESTAMPTZ   NOT NULL,
+    PRIMARY KEY (player_id, city_id)
+);
+
+CREATE INDEX IF NOT EXISTS city_sightings_world_idx ON city_sightings (player_id, world_id, q, r);
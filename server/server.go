@@ -6,51 +6,71 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"time"
 
+	"github.com/luisferreira32/stickian/server/internal/auth"
 	"github.com/luisferreira32/stickian/server/internal/dummy"
 	"github.com/luisferreira32/stickian/server/internal/game"
 	"github.com/luisferreira32/stickian/server/internal/user"
 )
 
-func run(ctx context.Context, address, databaseURL, migrationsURL, secretKey string, development bool) error {
+func run(ctx context.Context, cfg config) error {
+	byIP := clientIP(cfg.rateLimits.trustProxy)
 	middlewares := []func(http.HandlerFunc) http.HandlerFunc{
 		panicMiddleware(), // always chain the panic middleware first to prevent panics in other middlewares from crashing the server
-		authMiddleware(secretKey),
+		rateLimitMiddleware(cfg.rateLimits.ip, byIP), // before the authentication, to reject floods cheaply
+		authMiddleware(cfg.secretKey),
+		rateLimitMiddleware(cfg.rateLimits.user, authenticatedUser),
 	}
-	if development {
+	if cfg.development {
 		middlewares = append(middlewares, loggingMiddleware())
 	}
+	// restrictedMiddlewares only lets through the users with at least one of the roles
+	restrictedMiddlewares := func(roles ...string) []func(http.HandlerFunc) http.HandlerFunc {
+		return append(slices.Clone(middlewares), auth.RequireRoles(roles...))
+	}
+	// authMiddlewares adds a limit of its own to an authentication endpoint, since those can be brute-forced
+	authMiddlewares := func() []func(http.HandlerFunc) http.HandlerFunc {
+		return append(slices.Clone(middlewares), rateLimitMiddleware(cfg.rateLimits.auth, byIP))
+	}
 
-	err := runMigrations(migrationsURL, databaseURL)
+	dbs, err := newDatabases(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+		return err
 	}
-
-	db, err := newDatabaseConnection(ctx, databaseURL)
+	defer dbs.close()
+	mailer, closeMailer, err := newMailer(cfg.mailer)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %v", err)
+		return err
 	}
-	defer func() {
-		if err := db.Close(ctx); err != nil {
-			log.Printf("failed to close database connection: %v", err)
-		}
-	}()
+	defer closeMailer()
 
 	mux := http.NewServeMux()
-	var gameDB game.GameDatabase = &game.PostgresDatabase{DB: db}
-	gameSvc := &game.GameService{Database: gameDB}
+	broker := &game.Broker{}
+	gameSvc := &game.GameService{
+		Database:             dbs.game,
+		TickDuration:         cfg.tickDuration,
+		Broker:               broker,
+		RequireVerifiedEmail: cfg.requireVerifiedEmail,
+	}
 	userSvc := &user.UserService{
-		SecretKey:   secretKey,
-		Database:    &user.PostgresDatabase{DB: db},
-		Development: development,
+		SecretKey:            cfg.secretKey,
+		Database:             dbs.user,
+		Mailer:               mailer,
+		PublicURL:            cfg.publicURL,
+		Development:          cfg.development,
+		AccessTokenDuration:  cfg.accessTokenDuration,
+		RefreshTokenDuration: cfg.refreshTokenDuration,
+		ClientIP:             byIP,
 	}
-	dummySvc := &dummy.DummyService{
-		TickDuration:   time.Second, // 1s
-		DummyDatabase1: &dummy.PosgresDatabase{DB: db},
-		DummyDatabase2: &dummy.InMemoryDatabase{EventQueue: make(map[int64][]dummy.Event)},
+	dummySvc := &dummy.DummyService{Database: dbs.dummy}
+	tickEngine := &game.TickEngine{
+		Database:     dbs.game,
+		TickDuration: cfg.tickDuration,
+		Broker:       broker,
 	}
-	go dummySvc.Run(ctx)
+	go tickEngine.Run(ctx)
 
 	// define all endpoints
 	// serve static files for the client app
@@ -59,29 +79,49 @@ func run(ctx context.Context, address, databaseURL, migrationsURL, secretKey str
 	mux.HandleFunc("/api/echo", chainMiddleware(dummy.Echo, middlewares...))
 	mux.HandleFunc("GET /api/hello", chainMiddleware(dummy.Hello, middlewares...))
 	mux.HandleFunc("POST /api/panic", chainMiddleware(dummy.Panic, middlewares...))
-	mux.HandleFunc("POST /api/foo", chainMiddleware(dummySvc.TrainFoo, middlewares...))
-	mux.HandleFunc("POST /api/bar", chainMiddleware(dummySvc.BuildBar, middlewares...))
-	mux.HandleFunc("GET /api/foobar", chainMiddleware(dummySvc.GetFooBar, middlewares...))
 	mux.HandleFunc("GET /api/select1", chainMiddleware(dummySvc.Select1, middlewares...))
 	// city endpoints
 	mux.HandleFunc("GET /api/cities/{id}", chainMiddleware(gameSvc.GetCity, middlewares...))
 	mux.HandleFunc("GET /api/cities", chainMiddleware(gameSvc.GetCities, middlewares...))
+	mux.HandleFunc("GET /api/players/{id}/cities", chainMiddleware(gameSvc.GetPlayerCities, middlewares...))
+	mux.HandleFunc("POST /api/cities/{id}/buildings/{building}/upgrade", chainMiddleware(gameSvc.UpgradeBuilding, middlewares...))
+	mux.HandleFunc("POST /api/cities/{id}/train", chainMiddleware(gameSvc.TrainUnits, middlewares...))
+	mux.HandleFunc("GET /api/cities/{id}/movements", chainMiddleware(gameSvc.GetMovements, middlewares...))
+	mux.HandleFunc("POST /api/cities/{id}/movements", chainMiddleware(gameSvc.SendUnits, middlewares...))
+	mux.HandleFunc("POST /api/movements/{id}/recall", chainMiddleware(gameSvc.RecallMovement, middlewares...))
+	mux.HandleFunc("GET /api/reports", chainMiddleware(gameSvc.GetBattleReports, middlewares...))
+	mux.HandleFunc("GET /api/reports/{id}", chainMiddleware(gameSvc.GetBattleReport, middlewares...))
 	// user endpoints
-	mux.HandleFunc("POST /api/login", chainMiddleware(userSvc.Login, middlewares...))
-	mux.HandleFunc("POST /api/signup", chainMiddleware(userSvc.Signup, middlewares...))
+	mux.HandleFunc("POST /api/login", chainMiddleware(userSvc.Login, authMiddlewares()...))
+	mux.HandleFunc("POST /api/signup", chainMiddleware(userSvc.Signup, authMiddlewares()...))
+	mux.HandleFunc("POST /api/refresh", chainMiddleware(userSvc.Refresh, authMiddlewares()...))
+	mux.HandleFunc("POST /api/logout", chainMiddleware(userSvc.Logout, authMiddlewares()...))
+	mux.HandleFunc("GET /api/verify-email", chainMiddleware(userSvc.VerifyEmail, authMiddlewares()...))
+	mux.HandleFunc("POST /api/verify-email/resend", chainMiddleware(userSvc.ResendVerificationEmail, authMiddlewares()...))
+	mux.HandleFunc("POST /api/password/forgot", chainMiddleware(userSvc.ForgotPassword, authMiddlewares()...))
+	mux.HandleFunc("POST /api/password/reset", chainMiddleware(userSvc.ResetPassword, authMiddlewares()...))
+	mux.HandleFunc("POST /api/password/change", chainMiddleware(userSvc.ChangePassword, authMiddlewares()...))
+	// admin endpoints
+	mux.HandleFunc("PUT /api/users/{id}/roles", chainMiddleware(userSvc.SetRoles, restrictedMiddlewares(auth.RoleAdmin)...))
 	// map endpoints
 	mux.HandleFunc("GET /api/map", chainMiddleware(gameSvc.GetMapChunk, middlewares...))
+	mux.HandleFunc("GET /api/map/chunks/{cq}/{cr}", chainMiddleware(gameSvc.GetChunk, middlewares...))
 	// game endpoints
+	mux.HandleFunc("GET /api/worlds", chainMiddleware(gameSvc.GetWorlds, middlewares...))
 	mux.HandleFunc("POST /api/joinworld", chainMiddleware(gameSvc.JoinWorld, middlewares...))
+	mux.HandleFunc("GET /api/stream", chainMiddleware(gameSvc.Stream, middlewares...))
+	mux.HandleFunc("POST /api/stream/token", chainMiddleware(userSvc.StreamToken, middlewares...))
 
 	// run the server
-	server := http.Server{Addr: address, Handler: mux}
+	server := http.Server{Addr: cfg.address, Handler: mux}
+	// streams are long-lived requests, so they must be ended for the shutdown to complete
+	server.RegisterOnShutdown(broker.Close)
 	go func() {
 		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Printf("listen and serve err: %v", err)
 		}
 	}()
-	log.Printf("server started on %s\n", address)
+	log.Printf("server started on %s\n", cfg.address)
 
 	<-ctx.Done()
 
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/luisferreira32/stickian/server/internal/game"
+	"github.com/luisferreira32/stickian/server/internal/worldgen"
+)
+
+// worldgenCommand generates a world, i.e., `stickian worldgen`, and writes it to the files of a world in a
+// directory (the WORLD_DATA of the in-memory storage) and/or as a world of the database at DATABASE_URL.
+func worldgenCommand(ctx context.Context, args []string) error {
+	defaults := worldgen.DefaultConfig(0)
+	fs := flag.NewFlagSet("worldgen", flag.ExitOnError)
+	seed := fs.Uint64("seed", 0, "seed of the world, the same seed generates the same world (default random)")
+	size := fs.Int("size", defaults.Size, "number of tiles of both axes of the world")
+	border := fs.Int("border", defaults.Border, "number of tiles of the world edges without islands")
+	candidates := fs.Int("candidates", defaults.Candidates, "number of tries to place each island")
+	islandDistance := fs.Int("island-distance", defaults.IslandDistance, "minimum distance between islands")
+	islandRadius := fs.Int("island-radius", defaults.IslandRadius, "base radius of the islands")
+	out := fs.String("out", "", "directory to write the world files to")
+	database := fs.Bool("database", false, "write the world to DATABASE_URL, unless it already has its tiles")
+	name := fs.String("name", game.DefaultWorld.Name, "name of the world in the database, created if it does not exist")
+	speed := fs.Int("speed", 1, "speed multiplier of a new world in the database")
+	placement := fs.String("placement", game.DefaultWorld.Placement, "strategy that places the first city of new players in the world of the database, i.e., diagonal or spawn")
+	_ = fs.Parse(args) // exits on error
+
+	if *out == "" && !*database {
+		return errors.New("nowhere to write the world to, set -out and/or -database")
+	}
+	if *speed < 1 {
+		return errors.New("speed must be positive")
+	}
+	if _, err := game.ParsePlacementStrategy(*placement); err != nil {
+		return err
+	}
+	seeded, placed := false, false
+	fs.Visit(func(f *flag.Flag) {
+		seeded = seeded || f.Name == "seed"
+		placed = placed || f.Name == "placement"
+	})
+	if !seeded {
+		*seed = rand.Uint64()
+	}
+
+	cfg := worldgen.DefaultConfig(*seed)
+	cfg.Size = *size
+	cfg.Border = *border
+	cfg.Candidates = *candidates
+	cfg.IslandDistance = *islandDistance
+	cfg.IslandRadius = *islandRadius
+	tiles, err := worldgen.Generate(cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("generated a world of %d tiles with seed %d", len(tiles), *seed)
+
+	if *out != "" {
+		if err := worldgen.WriteFiles(*out, tiles); err != nil {
+			return fmt.Errorf("failed to write world files: %w", err)
+		}
+		log.Printf("world written to %s", *out)
+	}
+	if *database {
+		databaseURL := parseDefault("DATABASE_URL", testDatabaseURL)
+		err := runMigrations(parseDefault("MIGRATIONS_URL", deafultMigrationsURL), databaseURL)
+		if err != nil {
+			return fmt.Errorf("failed to run migrations: %w", err)
+		}
+		db, err := pgxpool.New(ctx, databaseURL)
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+		gameDB := &game.PostgresDatabase{DB: db}
+		world, err := findWorld(ctx, gameDB, *name)
+		if err != nil {
+			return err
+		}
+		if world == nil {
+			world = &game.World{
+				ID: uuid.New().String(), Name: *name, Size: cfg.Size, Speed: *speed, Status: game.WorldOpen,
+				StartsAt: time.Now(), Placement: *placement,
+			}
+		}
+		world.Size = cfg.Size
+		// an existing world keeps its placement unless told otherwise
+		if placed {
+			world.Placement = *placement
+		}
+		if err := gameDB.CreateWorld(ctx, world, tiles); err != nil {
+			return fmt.Errorf("failed to write world to database: %w", err)
+		}
+		log.Printf("world %s (%s) written to the database", world.Name, world.ID)
+	}
+	return nil
+}
+
+// findWorld returns the world of the database with the given name, or nil if there is none.
+func findWorld(ctx context.Context, db game.GameDatabase, name string) (*game.World, error) {
+	worlds, err := db.GetWorlds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worlds: %w", err)
+	}
+	for _, w := range worlds {
+		if w.Name == name {
+			return w, nil
+		}
+	}
+	return nil, nil
+}
@@ -7,17 +7,18 @@ import {
 } from 'react-router-dom'
 import './App.css'
 import City from './features/city/City'
-import Dummy from './features/dummy/Dummy'
 import Login from './features/login/Login'
 import Signup from './features/login/Signup'
+import ForgotPassword from './features/login/ForgotPassword'
+import ResetPassword from './features/login/ResetPassword'
 import { isAuthenticated, logout } from './shared/auth'
 import WorldMap from './features/map/WorldMap'
 
 const Navigation = () => {
   const navigate = useNavigate()
 
-  const handleLogout = () => {
-    logout()
+  const handleLogout = async (allDevices: boolean) => {
+    await logout(allDevices)
     navigate('/login')
   }
 
@@ -29,11 +30,14 @@ const Navigation = () => {
       <div className="nav-links">
         {isAuthenticated() ? (
           <>
-            <Link to="/dummy">Home</Link>
+            <Link to="/city">Home</Link>
             <Link to="/map">World</Link>
-            <button onClick={handleLogout} className="logout-btn">
+            <button onClick={() => handleLogout(false)} className="logout-btn">
               Logout
             </button>
+            <button onClick={() => handleLogout(true)} className="logout-btn">
+              Logout all devices
+            </button>
           </>
         ) : (
           <>
@@ -49,7 +53,7 @@ const Navigation = () => {
 const Fallback = () => {
   const navigate = useNavigate()
   if (isAuthenticated()) {
-    navigate('/dummy')
+    navigate('/city')
   } else {
     navigate('/login')
   }
@@ -67,7 +71,8 @@ const App = () => {
           <Routes>
             <Route path="/signup" element={<Signup />} />
             <Route path="/login" element={<Login />} />
-            {authed && <Route path="/dummy" element={<Dummy />} />}
+            <Route path="/forgot-password" element={<ForgotPassword />} />
+            <Route path="/reset-password" element={<ResetPassword />} />
             {authed && <Route path="/city" element={<City />} />}
             {authed && <Route path="/map" element={<WorldMap />} />}
             <Route path="*" element={<Fallback />} />
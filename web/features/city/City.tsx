@@ -1,8 +1,10 @@
 import { useEffect, useState } from 'react'
 import './City.css'
 import { apiRequest } from '../../shared/auth'
+import { openStream } from '../../shared/stream'
 
 type CityData = {
+  id: string
   cityName: string
   buildings: Record<string, number>
   resources: Record<string, number>
@@ -24,6 +26,22 @@ const City = () => {
       .catch(console.error)
   }, [])
 
+  // Keep the resources up to date with the ones of every processed tick
+  const cityID = city?.id
+  useEffect(() => {
+    if (!cityID) {
+      return
+    }
+    return openStream({
+      resources: (update) => {
+        if (update.cityID !== cityID) {
+          return
+        }
+        setCity((city) => city && { ...city, resources: update.resources })
+      },
+    })
+  }, [cityID])
+
   if (!city) {
     return <div>Loading City...</div>
   }
@@ -1,5 +1,6 @@
 import { useState } from 'react'
 import { Link, useNavigate } from 'react-router-dom'
+import { setTokens } from '../../shared/auth'
 import './Login.css'
 
 const Login = () => {
@@ -43,8 +44,8 @@ const Login = () => {
 
       const data = await response.json()
 
-      // Store the access token
-      localStorage.setItem('accessToken', data.accessToken)
+      // Store the access and refresh tokens
+      setTokens(data)
 
       // Redirect to home page or dashboard
       navigate('/')
@@ -94,6 +95,9 @@ const Login = () => {
         <p className="auth-link">
           Don't have an account? <Link to="/signup">Sign up</Link>
         </p>
+        <p className="auth-link">
+          <Link to="/forgot-password">Forgot your password?</Link>
+        </p>
       </div>
     </div>
   )
@@ -0,0 +1,105 @@
+import { useState } from 'react'
+import { Link, useNavigate, useSearchParams } from 'react-router-dom'
+import './Login.css'
+
+const ResetPassword = () => {
+  const [searchParams] = useSearchParams()
+  const [formData, setFormData] = useState({
+    password: '',
+    passwordRepeat: '',
+  })
+  const [error, setError] = useState('')
+  const [loading, setLoading] = useState(false)
+  const navigate = useNavigate()
+
+  const handleChange = (e: React.ChangeEvent<HTMLInputElement>) => {
+    const { name, value } = e.target
+    setFormData((prev) => ({
+      ...prev,
+      [name]: value,
+    }))
+  }
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError('')
+
+    // Validate password repeat
+    if (formData.password !== formData.passwordRepeat) {
+      setError('Passwords do not match')
+      return
+    }
+
+    setLoading(true)
+
+    try {
+      const response = await fetch('/api/password/reset', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({
+          token: searchParams.get('token'),
+          password: formData.password,
+        }),
+      })
+
+      if (!response.ok) {
+        const errorText = await response.text()
+        throw new Error(errorText || 'Password reset failed')
+      }
+
+      // Every session was revoked, so log in again with the new password
+      navigate('/login')
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'An error occurred')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  return (
+    <div className="auth-container">
+      <div className="auth-form">
+        <h2>Reset password</h2>
+        <form onSubmit={handleSubmit}>
+          <div className="form-group">
+            <label htmlFor="password">New password</label>
+            <input
+              type="password"
+              id="password"
+              name="password"
+              value={formData.password}
+              onChange={handleChange}
+              required
+            />
+          </div>
+
+          <div className="form-group">
+            <label htmlFor="passwordRepeat">Repeat new password</label>
+            <input
+              type="password"
+              id="passwordRepeat"
+              name="passwordRepeat"
+              value={formData.passwordRepeat}
+              onChange={handleChange}
+              required
+            />
+          </div>
+
+          {error && <div className="error-message">{error}</div>}
+
+          <button type="submit" disabled={loading}>
+            {loading ? 'Resetting...' : 'Reset password'}
+          </button>
+        </form>
+
+        <p className="auth-link">
+          <Link to="/login">Back to login</Link>
+        </p>
+      </div>
+    </div>
+  )
+}
+
+export default ResetPassword
@@ -1,5 +1,6 @@
 import { useState } from 'react'
 import { Link, useNavigate } from 'react-router-dom'
+import { setTokens } from '../../shared/auth'
 
 const Signup = () => {
   const [formData, setFormData] = useState({
@@ -52,8 +53,8 @@ const Signup = () => {
 
       const data = await response.json()
 
-      // Store the access token
-      localStorage.setItem('accessToken', data.accessToken)
+      // Store the access and refresh tokens
+      setTokens(data)
 
       // Redirect to home page or dashboard
       navigate('/')
@@ -8,6 +8,7 @@ const BIOME_COLORS: Record<number, string> = {
   2: 'sandybrown', // beach
   3: 'forestgreen', // plains
   4: 'dimgray', // mountain
+  255: '#0d1b2a', // unexplored
 }
 const HEX_SIZE = 8
 
@@ -1,5 +1,50 @@
+// Store the tokens returned by the login, signup and refresh endpoints
+export const setTokens = (data: {
+  accessToken: string
+  refreshToken: string
+}) => {
+  localStorage.setItem('accessToken', data.accessToken)
+  localStorage.setItem('refreshToken', data.refreshToken)
+}
+
+const clearTokens = () => {
+  localStorage.removeItem('accessToken')
+  localStorage.removeItem('refreshToken')
+}
+
+// Exchange the refresh token for new tokens, shared by concurrent requests
+// since a refresh token can only be used once
+let refreshing: Promise<boolean> | null = null
+const refreshTokens = (): Promise<boolean> => {
+  if (!refreshing) {
+    refreshing = (async () => {
+      const refreshToken = localStorage.getItem('refreshToken')
+      if (!refreshToken) {
+        return false
+      }
+      const response = await fetch('/api/refresh', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refreshToken }),
+      })
+      if (!response.ok) {
+        return false
+      }
+      setTokens(await response.json())
+      return true
+    })().finally(() => {
+      refreshing = null
+    })
+  }
+  return refreshing
+}
+
 // Utility function for making authenticated API requests
-export const apiRequest = async (url: string, options: RequestInit = {}) => {
+export const apiRequest = async (
+  url: string,
+  options: RequestInit = {},
+  retry = true
+): Promise<Response> => {
   const token = localStorage.getItem('accessToken')
 
   const headers = {
@@ -13,9 +58,13 @@ export const apiRequest = async (url: string, options: RequestInit = {}) => {
     headers,
   })
 
-  // If unauthorized, clear the token and redirect to login
+  // If unauthorized, try to refresh the tokens once, otherwise clear them and
+  // redirect to login
   if (response.status === 401) {
-    localStorage.removeItem('accessToken')
+    if (retry && (await refreshTokens())) {
+      return apiRequest(url, options, false)
+    }
+    clearTokens()
     window.location.href = '/login'
     throw new Error('Authentication failed')
   }
@@ -25,7 +74,7 @@ export const apiRequest = async (url: string, options: RequestInit = {}) => {
 
 // Check if user is authenticated
 export const isAuthenticated = (): boolean => {
-  return !!localStorage.getItem('accessToken')
+  return !!localStorage.getItem('refreshToken')
 }
 
 // Get the stored token
@@ -33,8 +82,17 @@ export const getToken = (): string | null => {
   return localStorage.getItem('accessToken')
 }
 
-// Clear authentication
-export const logout = () => {
-  localStorage.removeItem('accessToken')
+// Clear authentication, revoking the session on the server (or all sessions of
+// the user, with allDevices)
+export const logout = async (allDevices = false) => {
+  const refreshToken = localStorage.getItem('refreshToken')
+  if (refreshToken) {
+    await fetch('/api/logout', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ refreshToken, allDevices }),
+    }).catch(() => {})
+  }
+  clearTokens()
   window.location.href = '/login'
 }
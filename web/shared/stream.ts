@@ -0,0 +1,100 @@
+import { apiRequest } from './auth'
+
+export type ResourceUpdate = {
+  cityID: string
+  tick: number
+  resources: Record<string, number>
+  delta: Record<string, number>
+}
+
+export type Notification = {
+  seq: number
+  tick: number
+  type: string
+  cityID?: string
+  payload?: unknown
+}
+
+export type StreamHandlers = {
+  resources?: (update: ResourceUpdate) => void
+  notification?: (notification: Notification) => void
+}
+
+// The notification types pushed by the stream, see the notifications of the
+// tick engine
+const notificationTypes = [
+  'construction_completed',
+  'units_trained',
+  'incoming_attack',
+  'battle_report',
+  'city_founded',
+]
+
+// Wait before reopening a stream closed by the server, e.g., on a restart
+const reopenDelay = 5000
+
+// Get a short-lived token to open the stream with, since an EventSource cannot
+// set the Authorization header
+const streamToken = async (): Promise<string> => {
+  const response = await apiRequest('/api/stream/token', { method: 'POST' })
+  if (!response.ok) {
+    throw new Error('Failed to get a stream token')
+  }
+  const data = await response.json()
+  return data.streamToken
+}
+
+// Open the stream of the player, returning a function that closes it. The
+// browser reconnects on its own while the token is valid, and once the server
+// closes the stream it is reopened with a new token from the last notification
+// seen.
+export const openStream = (handlers: StreamHandlers): (() => void) => {
+  let source: EventSource | null = null
+  let timeout: ReturnType<typeof setTimeout> | undefined
+  let closed = false
+  let cursor = ''
+
+  const open = async () => {
+    let token: string
+    try {
+      token = await streamToken()
+    } catch (err) {
+      console.error(err)
+      timeout = setTimeout(open, reopenDelay)
+      return
+    }
+    if (closed) {
+      return
+    }
+
+    const params = new URLSearchParams({ token })
+    if (cursor) {
+      params.set('cursor', cursor)
+    }
+    source = new EventSource(`/api/stream?${params}`)
+
+    source.addEventListener('resources', (event) => {
+      handlers.resources?.(JSON.parse(event.data))
+    })
+    for (const type of notificationTypes) {
+      source.addEventListener(type, (event) => {
+        cursor = event.lastEventId || cursor
+        handlers.notification?.(JSON.parse(event.data))
+      })
+    }
+    source.onerror = () => {
+      if (source?.readyState === EventSource.CLOSED && !closed) {
+        source = null
+        timeout = setTimeout(open, reopenDelay)
+      }
+    }
+  }
+
+  open()
+
+  return () => {
+    closed = true
+    clearTimeout(timeout)
+    source?.close()
+  }
+}